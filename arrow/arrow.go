@@ -0,0 +1,65 @@
+// Package arrow converts ClickHouse results and inserts to and from Apache Arrow
+// record batches over any connection, one record batch per block. Unlike
+// QueryFormat with the ArrowStream format it is not limited to HTTP and works
+// on the driver's own column types, see Field for the mapping.
+//
+// Import it under another name to avoid clashing with the Arrow package itself:
+//
+//	import charrow "github.com/ClickHouse/clickhouse-go/v2/arrow"
+package arrow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// QueryArrow executes query on conn and returns a reader yielding a record batch
+// for each block of the result. Releasing the reader closes the rows.
+func QueryArrow(ctx context.Context, conn driver.Conn, query string, args ...any) (array.RecordReader, error) {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecordReader(rows, memory.DefaultAllocator)
+}
+
+// NewRecordReader returns a reader converting the blocks of rows into record
+// batches allocated from mem. Rows must implement driver.BlockRows, as the
+// Rows of the driver do, and must not have been advanced with Next.
+func NewRecordReader(rows driver.Rows, mem memory.Allocator) (array.RecordReader, error) {
+	blocks, ok := rows.(driver.BlockRows)
+	if !ok {
+		rows.Close()
+		return nil, fmt.Errorf("rows of type %T do not implement driver.BlockRows", rows)
+	}
+	var (
+		types   = rows.ColumnTypes()
+		columns = make([]column.Interface, 0, len(types))
+	)
+	for _, t := range types {
+		col, err := column.Type(t.DatabaseTypeName()).Column(t.Name(), &column.ServerContext{})
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		columns = append(columns, col)
+	}
+	schema, err := Schema(columns)
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	reader := &recordReader{
+		rows:    blocks,
+		schema:  schema,
+		builder: array.NewRecordBuilder(mem, schema),
+	}
+	reader.refs.Store(1)
+	return reader, nil
+}
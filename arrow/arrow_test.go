@@ -0,0 +1,263 @@
+package arrow
+
+import (
+	"testing"
+	"time"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/extensions"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+var testColumns = []struct {
+	name, chType string
+	arrowType    arrow.DataType
+	nullable     bool
+}{
+	{"id", "UInt64", arrow.PrimitiveTypes.Uint64, false},
+	{"name", "Nullable(String)", arrow.BinaryTypes.String, true},
+	{"tags", "Array(Nullable(Int32))", arrow.ListOfField(arrow.Field{Name: "item", Type: arrow.PrimitiveTypes.Int32, Nullable: true}), false},
+	{"nested", "Array(Array(String))", arrow.ListOfField(arrow.Field{Name: "item", Type: arrow.ListOfField(arrow.Field{Name: "item", Type: arrow.BinaryTypes.String})}), false},
+	{"lc", "LowCardinality(String)", &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}, false},
+	{"lcn", "LowCardinality(Nullable(String))", &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}, true},
+	{"attrs", "Map(String, UInt8)", func() arrow.DataType {
+		m := arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Uint8)
+		m.SetItemNullable(false)
+		return m
+	}(), false},
+	{"point", "Tuple(x Float64, Int8)", arrow.StructOf(
+		arrow.Field{Name: "x", Type: arrow.PrimitiveTypes.Float64},
+		arrow.Field{Name: "2", Type: arrow.PrimitiveTypes.Int8},
+	), false},
+	{"amount", "Decimal(18, 4)", &arrow.Decimal128Type{Precision: 18, Scale: 4}, false},
+	{"wide", "Decimal(50, 2)", &arrow.Decimal256Type{Precision: 50, Scale: 2}, false},
+	{"ts", "DateTime64(3, 'Europe/Berlin')", &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "Europe/Berlin"}, false},
+	{"dt", "DateTime", &arrow.TimestampType{Unit: arrow.Second, TimeZone: "UTC"}, false},
+	{"day", "Date32", arrow.FixedWidthTypes.Date32, false},
+	{"uid", "UUID", extensions.NewUUIDType(), false},
+	{"code", "FixedString(2)", &arrow.FixedSizeBinaryType{ByteWidth: 2}, false},
+	{"kind", "Enum8('a' = 1, 'b' = 2)", arrow.BinaryTypes.String, false},
+	{"flag", "Bool", arrow.FixedWidthTypes.Boolean, false},
+}
+
+func newTestBlock(t *testing.T) *proto.Block {
+	t.Helper()
+	block := &proto.Block{ServerContext: &column.ServerContext{Timezone: time.UTC}}
+	for _, c := range testColumns {
+		require.NoError(t, block.AddColumn(c.name, column.Type(c.chType)))
+	}
+	return block
+}
+
+// decoded returns block as received from the server, the columns of an appended block are not readable
+// before they are encoded.
+func decoded(t *testing.T, block *proto.Block) *proto.Block {
+	t.Helper()
+	var buffer chproto.Buffer
+	require.NoError(t, block.Encode(&buffer, proto.DBMS_TCP_PROTOCOL_VERSION))
+	result := &proto.Block{ServerContext: block.ServerContext}
+	require.NoError(t, result.Decode(buffer.Reader(), proto.DBMS_TCP_PROTOCOL_VERSION))
+	return result
+}
+
+func testRow(i int) []any {
+	var (
+		name = "name"
+		tag  = int32(i)
+		lcn  *string
+	)
+	if i%2 == 0 {
+		lcn = &name
+	}
+	return []any{
+		uint64(i),
+		map[bool]*string{true: &name, false: nil}[i%3 == 0],
+		[]*int32{&tag, nil},
+		[][]string{{"a", "b"}, {}, {"c"}}[:i%3],
+		[]string{"x", "y"}[i%2],
+		lcn,
+		map[string]uint8{"k": uint8(i)},
+		[]any{float64(i) / 2, int8(-i)},
+		decimal.New(int64(i)*12345, -4),
+		decimal.RequireFromString("123456789012345678901234567890.25"),
+		time.Date(2024, 5, 1, 10, 0, i, 123000000, time.UTC),
+		time.Date(2024, 5, 1, 10, 0, i, 0, time.UTC),
+		time.Date(2024, 5, 1+i, 0, 0, 0, 0, time.UTC),
+		uuid.MustParse("f47ac10b-58cc-4372-a567-0e02b2c3d479"),
+		"ab",
+		[]string{"a", "b"}[i%2],
+		i%2 == 0,
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema(newTestBlock(t).Columns)
+	require.NoError(t, err)
+	require.Len(t, schema.Fields(), len(testColumns))
+	for i, c := range testColumns {
+		field := schema.Field(i)
+		assert.Equal(t, c.name, field.Name)
+		assert.True(t, arrow.TypeEqual(c.arrowType, field.Type), "%s: %s != %s", c.name, c.arrowType, field.Type)
+		assert.Equal(t, c.nullable, field.Nullable, c.name)
+	}
+}
+
+func TestSchemaUnsupported(t *testing.T) {
+	col, err := column.Type("Int128").Column("big", &column.ServerContext{})
+	require.NoError(t, err)
+	_, err = Schema([]column.Interface{col})
+	var colErr *column.Error
+	require.ErrorAs(t, err, &colErr)
+	assert.Equal(t, "Int128", colErr.ColumnType)
+}
+
+type testRows struct {
+	driver.Rows
+	blocks []*proto.Block
+	closed bool
+}
+
+func (r *testRows) ColumnTypes() []driver.ColumnType {
+	return nil
+}
+
+func (r *testRows) NextBlock() (driver.Block, bool) {
+	if len(r.blocks) == 0 {
+		return nil, false
+	}
+	block := r.blocks[0]
+	r.blocks = r.blocks[1:]
+	return testBlock{block}, true
+}
+
+func (r *testRows) Err() error   { return nil }
+func (r *testRows) Close() error { r.closed = true; return nil }
+
+type testBlock struct{ block *proto.Block }
+
+func (b testBlock) Rows() int                   { return b.block.Rows() }
+func (b testBlock) Columns() []column.Interface { return b.block.Columns }
+func (b testBlock) ColumnNames() []string       { return b.block.ColumnsNames() }
+
+type testBatch struct {
+	driver.Batch
+	block *proto.Block
+}
+
+func (b *testBatch) Append(v ...any) error       { return b.block.Append(v...) }
+func (b *testBatch) Columns() []column.Interface { return b.block.Columns }
+
+func TestRoundTrip(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	var blocks []*proto.Block
+	for b := 0; b < 2; b++ {
+		block := newTestBlock(t)
+		for i := 0; i < 5; i++ {
+			require.NoError(t, block.Append(testRow(b*5+i)...))
+		}
+		blocks = append(blocks, decoded(t, block))
+	}
+	schema, err := Schema(blocks[0].Columns)
+	require.NoError(t, err)
+	rows := &testRows{blocks: blocks}
+	reader := &recordReader{rows: rows, schema: schema, builder: array.NewRecordBuilder(mem, schema)}
+	reader.refs.Store(1)
+
+	target := &testBatch{block: newTestBlock(t)}
+	var records int
+	for reader.Next() {
+		record := reader.RecordBatch()
+		require.EqualValues(t, 5, record.NumRows())
+		require.NoError(t, AppendRecord(target, record))
+		records++
+	}
+	require.NoError(t, reader.Err())
+	reader.Release()
+	assert.Equal(t, 2, records)
+	assert.True(t, rows.closed)
+
+	require.Equal(t, 10, target.block.Rows())
+	result := decoded(t, target.block)
+	for i := 0; i < 10; i++ {
+		source := blocks[i/5]
+		for c := range testColumns {
+			assert.Equal(t, source.Columns[c].Row(i%5, false), result.Columns[c].Row(i, false), "row %d column %s", i, testColumns[c].name)
+		}
+	}
+}
+
+func TestRecordValues(t *testing.T) {
+	block := newTestBlock(t)
+	require.NoError(t, block.Append(testRow(0)...))
+	require.NoError(t, block.Append(testRow(1)...))
+	block = decoded(t, block)
+	schema, err := Schema(block.Columns)
+	require.NoError(t, err)
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+	for i, col := range block.Columns {
+		require.NoError(t, appendColumn(builder.Field(i), col))
+	}
+	record := builder.NewRecordBatch()
+	defer record.Release()
+
+	name := record.Column(1).(*array.String)
+	assert.True(t, name.IsValid(0))
+	assert.True(t, name.IsNull(1))
+
+	lcn := record.Column(5).(*array.Dictionary)
+	assert.True(t, lcn.IsValid(0))
+	assert.True(t, lcn.IsNull(1))
+	assert.Equal(t, "name", lcn.Dictionary().(*array.String).Value(lcn.GetValueIndex(0)))
+
+	amount := record.Column(8).(*array.Decimal128)
+	assert.Equal(t, "1.2345", amount.Value(1).ToString(4))
+
+	ts := record.Column(10).(*array.Timestamp)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 1, 123000000, time.UTC), ts.Value(1).ToTime(arrow.Millisecond))
+
+	nested := record.Column(3).(*array.List)
+	start, end := nested.ValueOffsets(1)
+	assert.Equal(t, int64(1), end-start)
+}
+
+// plainRows does not implement driver.BlockRows.
+type plainRows struct {
+	driver.Rows
+	closed bool
+}
+
+func (r *plainRows) Close() error { r.closed = true; return nil }
+
+func TestNewRecordReaderBlockRows(t *testing.T) {
+	rows := &plainRows{}
+	_, err := NewRecordReader(rows, memory.DefaultAllocator)
+	assert.ErrorContains(t, err, "driver.BlockRows")
+	assert.True(t, rows.closed)
+}
+
+func TestAppendRecordFieldMismatch(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{{Name: "other", Type: arrow.PrimitiveTypes.Int64}}, nil)
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+	builder.Field(0).(*array.Int64Builder).Append(1)
+	record := builder.NewRecordBatch()
+	defer record.Release()
+
+	block := &proto.Block{ServerContext: &column.ServerContext{}}
+	require.NoError(t, block.AddColumn("id", "Int64"))
+	err := AppendRecord(&testBatch{block: block}, record)
+	assert.ErrorContains(t, err, `no single field "id"`)
+}
@@ -0,0 +1,326 @@
+package arrow
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/decimal256"
+	"github.com/apache/arrow-go/v18/arrow/extensions"
+	"github.com/shopspring/decimal"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// recordReader converts each block of a result into a record batch.
+type recordReader struct {
+	refs    atomic.Int64
+	rows    driver.BlockRows
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+	current arrow.RecordBatch
+	err     error
+}
+
+func (r *recordReader) Retain() {
+	r.refs.Add(1)
+}
+
+func (r *recordReader) Release() {
+	if r.refs.Add(-1) != 0 {
+		return
+	}
+	if r.current != nil {
+		r.current.Release()
+		r.current = nil
+	}
+	r.builder.Release()
+	r.rows.Close()
+}
+
+func (r *recordReader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+func (r *recordReader) Next() bool {
+	if r.current != nil {
+		r.current.Release()
+		r.current = nil
+	}
+	if r.err != nil {
+		return false
+	}
+	block, ok := r.rows.NextBlock()
+	if !ok {
+		r.err = r.rows.Err()
+		return false
+	}
+	if r.current, r.err = r.record(block); r.err != nil {
+		r.rows.Close()
+		return false
+	}
+	return true
+}
+
+func (r *recordReader) record(block driver.Block) (arrow.RecordBatch, error) {
+	columns := block.Columns()
+	if len(columns) != len(r.schema.Fields()) {
+		return nil, fmt.Errorf("block has %d columns, schema has %d", len(columns), len(r.schema.Fields()))
+	}
+	r.builder.Reserve(block.Rows())
+	for i, col := range columns {
+		if err := appendColumn(r.builder.Field(i), col); err != nil {
+			// drop the rows appended to the other fields so far
+			r.builder.NewRecordBatch().Release()
+			return nil, fmt.Errorf("column %s: %w", r.schema.Field(i).Name, err)
+		}
+	}
+	return r.builder.NewRecordBatch(), nil
+}
+
+func (r *recordReader) RecordBatch() arrow.RecordBatch {
+	return r.current
+}
+
+// Deprecated: Use RecordBatch instead.
+func (r *recordReader) Record() arrow.RecordBatch {
+	return r.current
+}
+
+func (r *recordReader) Err() error {
+	return r.err
+}
+
+// appendColumn appends all rows of col to b, bulk copying the values of flat numeric and String columns.
+func appendColumn(b array.Builder, col column.Interface) error {
+	var valid []bool
+	if c, ok := col.(*column.Nullable); ok {
+		nulls := c.Nulls()
+		valid = make([]bool, len(nulls))
+		for i, null := range nulls {
+			valid[i] = null == 0
+		}
+		col = c.Base()
+	}
+	switch c := col.(type) {
+	case *column.Int8:
+		return appendValues(b, c.Data(), valid)
+	case *column.Int16:
+		return appendValues(b, c.Data(), valid)
+	case *column.Int32:
+		return appendValues(b, c.Data(), valid)
+	case *column.Int64:
+		return appendValues(b, c.Data(), valid)
+	case *column.UInt8:
+		return appendValues(b, c.Data(), valid)
+	case *column.UInt16:
+		return appendValues(b, c.Data(), valid)
+	case *column.UInt32:
+		return appendValues(b, c.Data(), valid)
+	case *column.UInt64:
+		return appendValues(b, c.Data(), valid)
+	case *column.Float32:
+		return appendValues(b, c.Data(), valid)
+	case *column.Float64:
+		return appendValues(b, c.Data(), valid)
+	case *column.Bool:
+		return appendValues(b, c.Data(), valid)
+	case *column.String:
+		return appendValues(b, c.Data(), valid)
+	case *column.UUID:
+		return appendValues(b, c.Data(), valid)
+	case *column.Array:
+		offsets := c.Offsets()
+		for i := 0; i < c.Rows(); i++ {
+			if err := appendList(b, offsets, 0, c.Base(), i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := 0; i < col.Rows(); i++ {
+		if valid != nil && !valid[i] {
+			b.AppendNull()
+			continue
+		}
+		if err := appendRow(b, col, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendValues[T any](b array.Builder, values []T, valid []bool) error {
+	builder, ok := b.(interface{ AppendValues([]T, []bool) })
+	if !ok {
+		return fmt.Errorf("cannot append %T to %s", values, b.Type())
+	}
+	builder.AppendValues(values, valid)
+	return nil
+}
+
+// appendRow appends a single row of col to b.
+func appendRow(b array.Builder, col column.Interface, row int) error {
+	switch c := col.(type) {
+	case *column.Nullable:
+		if c.Nulls()[row] == 1 {
+			b.AppendNull()
+			return nil
+		}
+		return appendRow(b, c.Base(), row)
+	case *column.Array:
+		return appendList(b, c.Offsets(), 0, c.Base(), row)
+	case *column.Map:
+		builder, ok := b.(*array.MapBuilder)
+		if !ok {
+			return fmt.Errorf("cannot append %s to %s", col.Type(), b.Type())
+		}
+		var (
+			offsets = c.Offsets()
+			start   int64
+		)
+		if row > 0 {
+			start = offsets[row-1]
+		}
+		builder.Append(true)
+		for i := start; i < offsets[row]; i++ {
+			if err := appendRow(builder.KeyBuilder(), c.Keys(), int(i)); err != nil {
+				return err
+			}
+			if err := appendRow(builder.ItemBuilder(), c.Values(), int(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *column.Tuple:
+		builder, ok := b.(*array.StructBuilder)
+		if !ok {
+			return fmt.Errorf("cannot append %s to %s", col.Type(), b.Type())
+		}
+		builder.Append(true)
+		for i, element := range c.Columns() {
+			if err := appendRow(builder.FieldBuilder(i), element, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return appendScalar(b, col.Row(row, false))
+}
+
+// appendList appends row of the given array nesting level, recursing into the
+// next level until the elements of the innermost level are appended from base.
+func appendList(b array.Builder, offsets [][]uint64, level int, base column.Interface, row int) error {
+	builder, ok := b.(*array.ListBuilder)
+	if !ok {
+		return fmt.Errorf("cannot append Array to %s", b.Type())
+	}
+	var start uint64
+	if row > 0 {
+		start = offsets[level][row-1]
+	}
+	builder.Append(true)
+	for i := start; i < offsets[level][row]; i++ {
+		var err error
+		switch {
+		case level+1 < len(offsets):
+			err = appendList(builder.ValueBuilder(), offsets, level+1, base, int(i))
+		default:
+			err = appendRow(builder.ValueBuilder(), base, int(i))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendScalar appends a value as returned by column.Interface.Row to b.
+func appendScalar(b array.Builder, v any) error {
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Pointer {
+		// LowCardinality(Nullable(T)) rows are pointers
+		if value.IsNil() {
+			v = nil
+		} else {
+			v = value.Elem().Interface()
+		}
+	}
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	var ok bool
+	switch b := b.(type) {
+	case *array.Int8Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Int16Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Int32Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Int64Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Uint8Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Uint16Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Uint32Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Uint64Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Float32Builder:
+		ok = appendAs(b.Append, v)
+	case *array.Float64Builder:
+		ok = appendAs(b.Append, v)
+	case *array.BooleanBuilder:
+		ok = appendAs(b.Append, v)
+	case *array.StringBuilder:
+		ok = appendAs(b.Append, v)
+	case *array.FixedSizeBinaryBuilder:
+		ok = appendAs(func(v string) { b.Append([]byte(v)) }, v)
+	case *extensions.UUIDBuilder:
+		ok = appendAs(b.Append, v)
+	case *array.Date32Builder:
+		ok = appendAs(func(v time.Time) {
+			b.Append(arrow.Date32FromTime(v))
+		}, v)
+	case *array.TimestampBuilder:
+		ok = appendAs(b.AppendTime, v)
+	case *array.Decimal128Builder:
+		scale := b.Type().(*arrow.Decimal128Type).Scale
+		ok = appendAs(func(v decimal.Decimal) {
+			b.Append(decimal128.FromBigInt(v.Shift(scale).BigInt()))
+		}, v)
+	case *array.Decimal256Builder:
+		scale := b.Type().(*arrow.Decimal256Type).Scale
+		ok = appendAs(func(v decimal.Decimal) {
+			b.Append(decimal256.FromBigInt(v.Shift(scale).BigInt()))
+		}, v)
+	case *array.BinaryDictionaryBuilder:
+		var err error
+		if ok = appendAs(func(v string) { err = b.AppendString(v) }, v); err != nil {
+			return err
+		}
+	case *array.FixedSizeBinaryDictionaryBuilder:
+		var err error
+		if ok = appendAs(func(v string) { err = b.Append([]byte(v)) }, v); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return fmt.Errorf("cannot append %T to %s", v, b.Type())
+	}
+	return nil
+}
+
+func appendAs[T any](append func(T), v any) bool {
+	value, ok := v.(T)
+	if ok {
+		append(value)
+	}
+	return ok
+}
@@ -0,0 +1,177 @@
+package arrow
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/extensions"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+)
+
+// Schema returns the Arrow schema of a result or table with the given columns, see Field.
+func Schema(columns []column.Interface) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, 0, len(columns))
+	for _, col := range columns {
+		field, err := Field(col.Name(), col)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// Field maps a ClickHouse column to an Arrow field:
+//
+//	Int8 … Int64, UInt8 … UInt64    int8 … int64, uint8 … uint64
+//	Float32, Float64                float32, float64
+//	Bool                            bool
+//	String, Enum8, Enum16           utf8
+//	FixedString(N)                  fixed_size_binary[N]
+//	UUID                            arrow.uuid extension
+//	Date, Date32                    date32
+//	DateTime                        timestamp[s] with the column timezone, UTC if unset
+//	DateTime64(P)                   timestamp[s|ms|us|ns] depending on P
+//	Decimal(P, S)                   decimal128(P, S), decimal256(P, S) for P > 38
+//	Nullable(T)                     T, nullable
+//	LowCardinality(T)               dictionary<int32, T> for String and FixedString, T otherwise
+//	Array(T)                        list<T>
+//	Map(K, V)                       map<K, V>
+//	Tuple(T1, T2, …)                struct, unnamed elements are named "1", "2", …
+func Field(name string, col column.Interface) (arrow.Field, error) {
+	dataType, nullable, err := dataType(col)
+	if err != nil {
+		return arrow.Field{}, err
+	}
+	return arrow.Field{Name: name, Type: dataType, Nullable: nullable}, nil
+}
+
+func dataType(col column.Interface) (arrow.DataType, bool, error) {
+	switch c := col.(type) {
+	case *column.Nullable:
+		base, _, err := dataType(c.Base())
+		return base, true, err
+	case *column.LowCardinality:
+		base, nullable, err := dataType(c.Base())
+		if err != nil {
+			return nil, false, err
+		}
+		switch base.(type) {
+		case *arrow.StringType, *arrow.FixedSizeBinaryType:
+			return &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: base}, nullable, nil
+		}
+		return base, nullable, nil
+	case *column.Array:
+		base, nullable, err := dataType(c.Base())
+		if err != nil {
+			return nil, false, err
+		}
+		for range c.Offsets() {
+			base, nullable = arrow.ListOfField(arrow.Field{Name: "item", Type: base, Nullable: nullable}), false
+		}
+		return base, false, nil
+	case *column.Map:
+		key, _, err := dataType(c.Keys())
+		if err != nil {
+			return nil, false, err
+		}
+		value, nullable, err := dataType(c.Values())
+		if err != nil {
+			return nil, false, err
+		}
+		mapType := arrow.MapOf(key, value)
+		mapType.SetItemNullable(nullable)
+		return mapType, false, nil
+	case *column.Tuple:
+		fields := make([]arrow.Field, 0, len(c.Columns()))
+		for i, element := range c.Columns() {
+			name := element.Name()
+			if name == "" {
+				name = strconv.Itoa(i + 1)
+			}
+			field, err := Field(name, element)
+			if err != nil {
+				return nil, false, err
+			}
+			fields = append(fields, field)
+		}
+		return arrow.StructOf(fields...), false, nil
+	case *column.Int8:
+		return arrow.PrimitiveTypes.Int8, false, nil
+	case *column.Int16:
+		return arrow.PrimitiveTypes.Int16, false, nil
+	case *column.Int32:
+		return arrow.PrimitiveTypes.Int32, false, nil
+	case *column.Int64:
+		return arrow.PrimitiveTypes.Int64, false, nil
+	case *column.UInt8:
+		return arrow.PrimitiveTypes.Uint8, false, nil
+	case *column.UInt16:
+		return arrow.PrimitiveTypes.Uint16, false, nil
+	case *column.UInt32:
+		return arrow.PrimitiveTypes.Uint32, false, nil
+	case *column.UInt64:
+		return arrow.PrimitiveTypes.Uint64, false, nil
+	case *column.Float32:
+		return arrow.PrimitiveTypes.Float32, false, nil
+	case *column.Float64:
+		return arrow.PrimitiveTypes.Float64, false, nil
+	case *column.Bool:
+		return arrow.FixedWidthTypes.Boolean, false, nil
+	case *column.String, *column.Enum8, *column.Enum16:
+		return arrow.BinaryTypes.String, false, nil
+	case *column.FixedString:
+		var size int
+		if _, err := fmt.Sscanf(string(c.Type()), "FixedString(%d)", &size); err != nil {
+			return nil, false, err
+		}
+		return &arrow.FixedSizeBinaryType{ByteWidth: size}, false, nil
+	case *column.UUID:
+		return extensions.NewUUIDType(), false, nil
+	case *column.Date, *column.Date32:
+		return arrow.FixedWidthTypes.Date32, false, nil
+	case *column.DateTime:
+		return &arrow.TimestampType{Unit: arrow.Second, TimeZone: timezone(c.Type())}, false, nil
+	case *column.DateTime64:
+		precision, _ := c.Precision()
+		return &arrow.TimestampType{Unit: timeUnit(precision), TimeZone: timezone(c.Type())}, false, nil
+	case *column.Decimal:
+		precision, scale := int32(c.Precision()), int32(c.Scale())
+		if precision > 38 {
+			return &arrow.Decimal256Type{Precision: precision, Scale: scale}, false, nil
+		}
+		return &arrow.Decimal128Type{Precision: precision, Scale: scale}, false, nil
+	}
+	return nil, false, &column.Error{
+		ColumnType: string(col.Type()),
+		Err:        errors.New("no Arrow data type for column"),
+	}
+}
+
+// timeUnit returns the coarsest Arrow unit that holds a DateTime64 precision without losing digits.
+func timeUnit(precision int64) arrow.TimeUnit {
+	switch {
+	case precision <= 0:
+		return arrow.Second
+	case precision <= 3:
+		return arrow.Millisecond
+	case precision <= 6:
+		return arrow.Microsecond
+	}
+	return arrow.Nanosecond
+}
+
+// timezone returns the timezone of a DateTime or DateTime64 type, UTC when it has none.
+func timezone(t column.Type) string {
+	value := string(t)
+	if start := strings.IndexByte(value, '\''); start != -1 {
+		if end := strings.LastIndexByte(value, '\''); end > start {
+			return value[start+1 : end]
+		}
+	}
+	return "UTC"
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	charrow "github.com/ClickHouse/clickhouse-go/v2/arrow"
+	clickhouse_tests "github.com/ClickHouse/clickhouse-go/v2/tests"
+)
+
+func TestArrow(t *testing.T) {
+	clickhouse_tests.TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetArrowConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		ctx := context.Background()
+		require.NoError(t, err)
+		const ddl = `
+		CREATE TABLE %s (
+			  Col1 UInt64
+			, Col2 Nullable(String)
+			, Col3 LowCardinality(String)
+			, Col4 Array(Nullable(Int32))
+			, Col5 Map(String, UInt16)
+			, Col6 Tuple(a Float64, b String)
+			, Col7 Decimal(18, 4)
+			, Col8 DateTime64(3, 'UTC')
+			, Col9 UUID
+		) Engine MergeTree() ORDER BY Col1
+		`
+		defer func() {
+			conn.Exec(ctx, "DROP TABLE IF EXISTS test_arrow_source")
+			conn.Exec(ctx, "DROP TABLE IF EXISTS test_arrow_target")
+		}()
+		for _, table := range []string{"test_arrow_source", "test_arrow_target"} {
+			require.NoError(t, conn.Exec(ctx, "DROP TABLE IF EXISTS "+table))
+			require.NoError(t, conn.Exec(ctx, fmt.Sprintf(ddl, table)))
+		}
+		require.NoError(t, conn.Exec(ctx, `
+			INSERT INTO test_arrow_source
+			SELECT
+				  number
+				, if(number % 3 = 0, NULL, toString(number))
+				, ['a', 'b', 'c'][number % 3 + 1]
+				, [toInt32(number), NULL]
+				, map('k', toUInt16(number))
+				, tuple(number / 2, toString(number))
+				, toDecimal64(number / 8, 4)
+				, toDateTime64(1700000000 + number, 3, 'UTC')
+				, generateUUIDv4()
+			FROM numbers(50000)
+		`))
+
+		reader, err := charrow.QueryArrow(ctx, conn, "SELECT * FROM test_arrow_source ORDER BY Col1")
+		require.NoError(t, err)
+		defer reader.Release()
+		assert.Equal(t, 9, reader.Schema().NumFields())
+		assert.IsType(t, &arrow.DictionaryType{}, reader.Schema().Field(2).Type)
+
+		batch, err := conn.PrepareBatch(ctx, "INSERT INTO test_arrow_target")
+		require.NoError(t, err)
+		var count int64
+		for reader.Next() {
+			record := reader.RecordBatch()
+			ids := record.Column(0).(*array.Uint64)
+			for i := 0; i < ids.Len(); i++ {
+				require.Equal(t, uint64(count)+uint64(i), ids.Value(i))
+			}
+			require.NoError(t, charrow.AppendRecord(batch, record))
+			count += record.NumRows()
+		}
+		require.NoError(t, reader.Err())
+		assert.Equal(t, int64(50000), count)
+		require.NoError(t, batch.Send())
+
+		var diff uint64
+		require.NoError(t, conn.QueryRow(ctx, `
+			SELECT count() FROM (
+				SELECT * FROM test_arrow_source
+				EXCEPT
+				SELECT * FROM test_arrow_target
+			)
+		`).Scan(&diff))
+		assert.Zero(t, diff)
+	})
+}
@@ -0,0 +1,23 @@
+package tests
+
+import (
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	clickhouse_tests "github.com/ClickHouse/clickhouse-go/v2/tests"
+)
+
+const testSet string = "arrow"
+
+func TestMain(m *testing.M) {
+	os.Exit(clickhouse_tests.Runtime(m, testSet))
+}
+
+func GetArrowConnection(t *testing.T, protocol clickhouse.Protocol, settings clickhouse.Settings, tlsConfig *tls.Config, compression *clickhouse.Compression) (driver.Conn, error) {
+	conn, err := clickhouse_tests.GetConnection(testSet, t, protocol, settings, tlsConfig, compression)
+	clickhouse_tests.CleanupNativeConn(t, conn)
+	return conn, err
+}
@@ -0,0 +1,214 @@
+package arrow
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/extensions"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// AppendRecord appends all rows of record to batch. Record fields are matched to
+// the batch columns by name and must use the Arrow types Field maps the columns
+// to, except that plain fixed_size_binary[16] is accepted for UUID and the
+// dictionary encoding of LowCardinality columns is optional.
+func AppendRecord(batch driver.Batch, record arrow.RecordBatch) error {
+	var (
+		columns = batch.Columns()
+		schema  = record.Schema()
+		arrays  = make([]arrow.Array, len(columns))
+	)
+	if int(record.NumCols()) != len(columns) {
+		return fmt.Errorf("record has %d fields, batch has %d columns", record.NumCols(), len(columns))
+	}
+	for i, col := range columns {
+		indices := schema.FieldIndices(col.Name())
+		if len(indices) != 1 {
+			return fmt.Errorf("record has no single field %q for column", col.Name())
+		}
+		arrays[i] = record.Column(indices[0])
+	}
+	values := make([]any, len(columns))
+	for row := 0; row < int(record.NumRows()); row++ {
+		for i, arr := range arrays {
+			value, err := arrowValue(arr, row, columns[i])
+			if err != nil {
+				return fmt.Errorf("column %s: %w", columns[i].Name(), err)
+			}
+			values[i] = value
+		}
+		if err := batch.Append(values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arrowValue returns row of arr as a value col can append.
+func arrowValue(arr arrow.Array, row int, col column.Interface) (any, error) {
+	if arr.IsNull(row) {
+		return nil, nil
+	}
+	col = valueColumn(col)
+	switch a := arr.(type) {
+	case *array.Int8:
+		return a.Value(row), nil
+	case *array.Int16:
+		return a.Value(row), nil
+	case *array.Int32:
+		return a.Value(row), nil
+	case *array.Int64:
+		return a.Value(row), nil
+	case *array.Uint8:
+		return a.Value(row), nil
+	case *array.Uint16:
+		return a.Value(row), nil
+	case *array.Uint32:
+		return a.Value(row), nil
+	case *array.Uint64:
+		return a.Value(row), nil
+	case *array.Float32:
+		return a.Value(row), nil
+	case *array.Float64:
+		return a.Value(row), nil
+	case *array.Boolean:
+		return a.Value(row), nil
+	case *array.String:
+		return a.Value(row), nil
+	case *array.LargeString:
+		return a.Value(row), nil
+	case *array.Binary:
+		return string(a.Value(row)), nil
+	case *array.FixedSizeBinary:
+		if _, ok := col.(*column.UUID); ok {
+			return uuid.FromBytes(a.Value(row))
+		}
+		return string(a.Value(row)), nil
+	case *extensions.UUIDArray:
+		return a.Value(row), nil
+	case *array.Date32:
+		return a.Value(row).ToTime(), nil
+	case *array.Timestamp:
+		return a.Value(row).ToTime(a.DataType().(*arrow.TimestampType).Unit), nil
+	case *array.Decimal128:
+		scale := a.DataType().(*arrow.Decimal128Type).Scale
+		return decimal.NewFromBigInt(a.Value(row).BigInt(), -scale), nil
+	case *array.Decimal256:
+		scale := a.DataType().(*arrow.Decimal256Type).Scale
+		return decimal.NewFromBigInt(a.Value(row).BigInt(), -scale), nil
+	case *array.Dictionary:
+		return arrowValue(a.Dictionary(), a.GetValueIndex(row), col)
+	case *array.Map:
+		var (
+			m            = &orderedMap{}
+			start, end   = a.ValueOffsets(row)
+			keys, values column.Interface
+		)
+		if c, ok := col.(*column.Map); ok {
+			keys, values = c.Keys(), c.Values()
+		}
+		for i := int(start); i < int(end); i++ {
+			key, err := arrowValue(a.Keys(), i, keys)
+			if err != nil {
+				return nil, err
+			}
+			value, err := arrowValue(a.Items(), i, values)
+			if err != nil {
+				return nil, err
+			}
+			m.Put(key, value)
+		}
+		return m, nil
+	case *array.List:
+		var (
+			start, end = a.ValueOffsets(row)
+			elements   = make([]any, 0, end-start)
+			base       column.Interface
+		)
+		if c, ok := col.(*column.Array); ok {
+			base = c.Base()
+		}
+		for i := int(start); i < int(end); i++ {
+			element, err := arrowValue(a.ListValues(), i, base)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return elements, nil
+	case *array.Struct:
+		var elements []column.Interface
+		if c, ok := col.(*column.Tuple); ok && len(c.Columns()) == a.NumField() {
+			elements = c.Columns()
+		}
+		tuple := make([]any, a.NumField())
+		for i := range tuple {
+			var element column.Interface
+			if elements != nil {
+				element = elements[i]
+			}
+			value, err := arrowValue(a.Field(i), row, element)
+			if err != nil {
+				return nil, err
+			}
+			tuple[i] = value
+		}
+		return tuple, nil
+	}
+	return nil, &column.Error{
+		ColumnType: arr.DataType().String(),
+		Err:        errors.New("Arrow data type is not supported"),
+	}
+}
+
+// valueColumn strips the Nullable and LowCardinality wrappers, which do not change the Go values of col.
+func valueColumn(col column.Interface) column.Interface {
+	for {
+		switch c := col.(type) {
+		case *column.Nullable:
+			col = c.Base()
+		case *column.LowCardinality:
+			col = c.Base()
+		default:
+			return col
+		}
+	}
+}
+
+// orderedMap keeps the entry order of an Arrow map when appending it to a Map column.
+type orderedMap struct {
+	keys, values []any
+}
+
+func (m *orderedMap) Put(key, value any) {
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+}
+
+func (m *orderedMap) Iterator() column.MapIterator {
+	return &orderedMapIterator{m: m, i: -1}
+}
+
+type orderedMapIterator struct {
+	m *orderedMap
+	i int
+}
+
+func (it *orderedMapIterator) Next() bool {
+	it.i++
+	return it.i < len(it.m.keys)
+}
+
+func (it *orderedMapIterator) Key() any {
+	return it.m.keys[it.i]
+}
+
+func (it *orderedMapIterator) Value() any {
+	return it.m.values[it.i]
+}
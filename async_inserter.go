@@ -0,0 +1,555 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+var ErrAsyncInserterClosed = errors.New("clickhouse: async inserter is closed")
+
+const (
+	defaultAsyncInsertMaxRows       = 100_000
+	defaultAsyncInsertMaxBytes      = 16 << 20
+	defaultAsyncInsertFlushInterval = time.Second
+	defaultAsyncInsertFlushes       = 4
+)
+
+// AsyncInserterOptions configures NewAsyncInserter. A table is flushed once
+// it buffers MaxRows rows or MaxBytes bytes, and at least every FlushInterval.
+type AsyncInserterOptions struct {
+	MaxRows       int           // default 100000
+	MaxBytes      int           // approximate uncompressed size, default 16 MiB
+	FlushInterval time.Duration // default 1s
+	// MemoryBudget bounds the bytes buffered and being flushed across tables,
+	// appends block while it is used up. Default 4 * MaxBytes, at least MaxBytes.
+	MemoryBudget int
+	// MaxConcurrentFlushes bounds the flushes in flight, each of them takes a
+	// connection of the pool. Default 4.
+	MaxConcurrentFlushes int
+	// FlushTimeout bounds every flush, unbounded when 0 (default).
+	FlushTimeout time.Duration
+	// Wait sets wait_for_async_insert, flushes then end once the server
+	// inserted their rows rather than once it buffered them.
+	Wait bool
+	// Settings are sent with every flush along with async_insert.
+	Settings Settings
+	// OnFlush is called with the outcome of every flush, from the goroutine
+	// of the flush. Failed flushes are not retried beyond Options.RetryPolicy.
+	OnFlush func(AsyncInsertFlush)
+}
+
+// AsyncInsertFlush is a flush of the rows buffered for a table.
+type AsyncInsertFlush struct {
+	Table    string
+	QueryID  string
+	Rows     int
+	Bytes    int // approximate uncompressed size
+	Duration time.Duration
+	Err      error
+}
+
+// AsyncInserter buffers the rows appended from many goroutines in a block per
+// table, and sends them as INSERTs with async_insert over the native protocol,
+// so that the server batches them further. It replaces building VALUES of
+// statements sent with WithAsync.
+type AsyncInserter struct {
+	opts      AsyncInserterOptions
+	settings  Settings
+	header    func(ctx context.Context, query string) (*proto.Block, error)
+	send      func(ctx context.Context, query string, block *proto.Block) (string, error)
+	structMap structMap
+
+	ctx    context.Context // of the flushes, cancelled once Close is done
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	tables  map[string]*asyncTable
+	used    int           // bytes buffered or being flushed
+	freed   chan struct{} // closed when used decreases
+	closed  bool
+	appends sync.WaitGroup
+
+	flushes sync.WaitGroup
+	running chan struct{} // a token by flush in flight
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// asyncTable is the buffer of a table, the rows are appended to block.
+type asyncTable struct {
+	name  string
+	query string
+
+	mu      sync.Mutex
+	header  *proto.Block // the columns of the INSERT, nil until the first append
+	scratch *proto.Block // checks the rows before they are appended to block
+	block   *proto.Block
+	bytes   int
+}
+
+// asyncFlush is done once its rows are sent.
+type asyncFlush struct {
+	done chan struct{}
+	err  error
+}
+
+// NewAsyncInserter starts an AsyncInserter on conn, a Conn of Open with the
+// native protocol. It must be closed to flush the rows it buffers.
+func NewAsyncInserter(conn driver.Conn, opts AsyncInserterOptions) (*AsyncInserter, error) {
+	ch, ok := conn.(*clickhouse)
+	if !ok || ch.opt.Protocol != Native {
+		return nil, errors.New("clickhouse: AsyncInserter requires a native protocol Conn of Open")
+	}
+	return newAsyncInserter(opts, ch.insertHeader, ch.insertBlock), nil
+}
+
+func newAsyncInserter(opts AsyncInserterOptions,
+	header func(context.Context, string) (*proto.Block, error),
+	send func(context.Context, string, *proto.Block) (string, error),
+) *AsyncInserter {
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = defaultAsyncInsertMaxRows
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultAsyncInsertMaxBytes
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultAsyncInsertFlushInterval
+	}
+	if opts.MemoryBudget <= 0 {
+		opts.MemoryBudget = 4 * opts.MaxBytes
+	}
+	opts.MemoryBudget = max(opts.MemoryBudget, opts.MaxBytes)
+	if opts.MaxConcurrentFlushes <= 0 {
+		opts.MaxConcurrentFlushes = defaultAsyncInsertFlushes
+	}
+	settings := maps.Clone(opts.Settings)
+	if settings == nil {
+		settings = make(Settings)
+	}
+	settings["async_insert"] = 1
+	settings["wait_for_async_insert"] = 0
+	if opts.Wait {
+		settings["wait_for_async_insert"] = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &AsyncInserter{
+		opts:     opts,
+		settings: settings,
+		header:   header,
+		send:     send,
+		ctx:      ctx,
+		cancel:   cancel,
+		tables:   make(map[string]*asyncTable),
+		freed:    make(chan struct{}),
+		running:  make(chan struct{}, opts.MaxConcurrentFlushes),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Append buffers a row for table, in the column order of the INSERT INTO
+// table statement, e.g. "events" or "events (ts, name)". It blocks while the
+// memory budget is used up, until ctx is done. A row the columns reject
+// returns an error and leaves the buffered rows untouched.
+func (a *AsyncInserter) Append(ctx context.Context, table string, v ...any) error {
+	return a.append(ctx, table, func([]string) ([]any, error) {
+		return v, nil
+	})
+}
+
+// AppendStruct buffers the fields of struct v mapped to the columns of table,
+// like Batch.AppendStruct.
+func (a *AsyncInserter) AppendStruct(ctx context.Context, table string, v any) error {
+	return a.append(ctx, table, func(columns []string) ([]any, error) {
+		return a.structMap.Map("AppendStruct", columns, v)
+	})
+}
+
+func (a *AsyncInserter) append(ctx context.Context, table string, values func(columns []string) ([]any, error)) error {
+	t, err := a.table(table)
+	if err != nil {
+		return err
+	}
+	defer a.appends.Done()
+
+	t.mu.Lock()
+	v, err := t.check(ctx, a.header, values)
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	size := rowSize(v)
+	if err := a.reserve(ctx, size); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.block.Append(v...); err != nil {
+		// unexpected, the check converted the same values to the same columns
+		a.release(size)
+		return &OpError{Op: "AsyncInserter.Append", Err: err}
+	}
+	t.bytes += size
+	if t.block.Rows() >= a.opts.MaxRows || t.bytes >= a.opts.MaxBytes {
+		if _, err := a.flushTable(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// table returns the buffer of table, counting an append until it is done.
+func (a *AsyncInserter) table(table string) (*asyncTable, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil, ErrAsyncInserterClosed
+	}
+	t, ok := a.tables[table]
+	if !ok {
+		t = &asyncTable{name: table, query: "INSERT INTO " + table}
+		a.tables[table] = t
+	}
+	a.appends.Add(1)
+	return t, nil
+}
+
+// check returns the values of a row once it appended them to scratch, the
+// columns are read from the server on the first append. t.mu is held.
+func (t *asyncTable) check(ctx context.Context, header func(context.Context, string) (*proto.Block, error), values func([]string) ([]any, error)) ([]any, error) {
+	if t.header == nil {
+		block, err := header(ctx, t.query)
+		if err != nil {
+			return nil, err
+		}
+		scratch, err := emptyBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		if t.block, err = emptyBlock(block); err != nil {
+			return nil, err
+		}
+		t.header, t.scratch = block, scratch
+	}
+	v, err := values(t.header.ColumnsNames())
+	if err != nil {
+		return nil, err
+	}
+	defer t.scratch.Reset()
+	if err := t.scratch.Append(v...); err != nil {
+		return nil, &OpError{Op: "AsyncInserter.Append", Err: err}
+	}
+	return v, nil
+}
+
+// emptyBlock returns a block with the columns of header.
+func emptyBlock(header *proto.Block) (*proto.Block, error) {
+	block := &proto.Block{ServerContext: header.ServerContext}
+	for _, col := range header.Columns {
+		if err := block.AddColumn(col.Name(), col.Type()); err != nil {
+			return nil, err
+		}
+	}
+	return block, nil
+}
+
+// reserve takes size bytes of the memory budget, it waits for flushes to free
+// enough of it. A row is let in when nothing else is buffered, whatever its size.
+func (a *AsyncInserter) reserve(ctx context.Context, size int) error {
+	a.mu.Lock()
+	for a.used > 0 && a.used+size > a.opts.MemoryBudget {
+		if a.closed {
+			a.mu.Unlock()
+			return ErrAsyncInserterClosed
+		}
+		freed := a.freed
+		a.mu.Unlock()
+		// buffers below MaxBytes would otherwise hold the budget until the next interval
+		a.flushLargest()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		a.mu.Lock()
+	}
+	a.used += size
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *AsyncInserter) release(size int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.used -= size
+	close(a.freed)
+	a.freed = make(chan struct{})
+}
+
+func (a *AsyncInserter) snapshot() []*asyncTable {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Collect(maps.Values(a.tables))
+}
+
+func (a *AsyncInserter) flushLargest() {
+	var largest *asyncTable
+	for _, t := range a.snapshot() {
+		t.mu.Lock()
+		if t.bytes > 0 && (largest == nil || t.bytes > largest.bytes) {
+			largest = t
+		}
+		t.mu.Unlock()
+	}
+	if largest != nil {
+		largest.mu.Lock()
+		a.flushTable(largest)
+		largest.mu.Unlock()
+	}
+}
+
+// flushTable starts the flush of the rows of t, if any, and swaps in an
+// empty block for the next ones. t.mu is held.
+func (a *AsyncInserter) flushTable(t *asyncTable) (*asyncFlush, error) {
+	if t.block == nil || t.block.Rows() == 0 {
+		return nil, nil
+	}
+	next, err := emptyBlock(t.header)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		block = t.block
+		bytes = t.bytes
+		f     = &asyncFlush{done: make(chan struct{})}
+	)
+	t.block, t.bytes = next, 0
+	a.flushes.Add(1)
+	go a.flush(t, block, bytes, f)
+	return f, nil
+}
+
+func (a *AsyncInserter) flush(t *asyncTable, block *proto.Block, bytes int, f *asyncFlush) {
+	defer a.flushes.Done()
+	defer close(f.done)
+	select {
+	case a.running <- struct{}{}:
+		defer func() { <-a.running }()
+	case <-a.ctx.Done():
+	}
+	ctx := Context(a.ctx, WithSettings(maps.Clone(a.settings)))
+	if a.opts.FlushTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.opts.FlushTimeout)
+		defer cancel()
+	}
+	var (
+		start      = time.Now()
+		rows       = block.Rows()
+		queryID, e = a.send(ctx, t.query, block)
+	)
+	a.release(bytes)
+	f.err = e
+	if a.opts.OnFlush != nil {
+		a.opts.OnFlush(AsyncInsertFlush{
+			Table:    t.name,
+			QueryID:  queryID,
+			Rows:     rows,
+			Bytes:    bytes,
+			Duration: time.Since(start),
+			Err:      e,
+		})
+	}
+}
+
+// run flushes the buffers every FlushInterval until Close.
+func (a *AsyncInserter) run() {
+	defer close(a.stopped)
+	ticker := time.NewTicker(a.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.flushAll()
+		}
+	}
+}
+
+func (a *AsyncInserter) flushAll() ([]*asyncFlush, error) {
+	var (
+		flushes []*asyncFlush
+		errs    []error
+	)
+	for _, t := range a.snapshot() {
+		t.mu.Lock()
+		f, err := a.flushTable(t)
+		t.mu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if f != nil {
+			flushes = append(flushes, f)
+		}
+	}
+	return flushes, errors.Join(errs...)
+}
+
+// Flush sends the rows buffered for every table and waits for them to be
+// sent, or for ctx to be done. It returns the errors of these flushes.
+func (a *AsyncInserter) Flush(ctx context.Context) error {
+	flushes, err := a.flushAll()
+	errs := []error{err}
+	for _, f := range flushes {
+		select {
+		case <-f.done:
+			errs = append(errs, f.err)
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the appends, flushes the buffered rows and waits for every
+// flush to end. Once ctx is done the flushes in flight are cancelled.
+func (a *AsyncInserter) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	// wake up the appends waiting for the budget
+	close(a.freed)
+	a.freed = make(chan struct{})
+	a.mu.Unlock()
+
+	close(a.done)
+	<-a.stopped
+	a.appends.Wait()
+	defer a.cancel()
+
+	err := a.Flush(ctx)
+	flushed := make(chan struct{})
+	go func() {
+		a.flushes.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		return err
+	case <-ctx.Done():
+		a.cancel()
+		<-flushed
+		return context.Cause(ctx)
+	}
+}
+
+// rowSize approximates the bytes of row once appended to a block.
+func rowSize(row []any) int {
+	var size int
+	for _, v := range row {
+		size += valueSize(v)
+	}
+	return size
+}
+
+func valueSize(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 1
+	case string:
+		return len(v) + 1
+	case []byte:
+		return len(v) + 1
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, int64, uint, uint64, float64, time.Time:
+		return 8
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return 1
+		}
+		return valueSize(rv.Elem().Interface())
+	case reflect.String:
+		return rv.Len() + 1
+	case reflect.Slice, reflect.Array:
+		size := 8
+		for i := range rv.Len() {
+			size += valueSize(rv.Index(i).Interface())
+		}
+		return size
+	case reflect.Map:
+		size := 8
+		for iter := rv.MapRange(); iter.Next(); {
+			size += valueSize(iter.Key().Interface()) + valueSize(iter.Value().Interface())
+		}
+		return size
+	}
+	return int(rv.Type().Size())
+}
+
+// insertHeader returns the columns of the INSERT query, without inserting.
+func (ch *clickhouse) insertHeader(ctx context.Context, query string) (*proto.Block, error) {
+	b, err := ch.PrepareBatch(ctx, query, driver.WithReleaseConnection())
+	if err != nil {
+		return nil, err
+	}
+	return nativeBatch(b).block, nil
+}
+
+// insertBlock sends block, with the columns of insertHeader, with an INSERT
+// query and returns its query ID.
+func (ch *clickhouse) insertBlock(ctx context.Context, query string, block *proto.Block) (string, error) {
+	b, err := ch.PrepareBatch(ctx, query)
+	if err != nil {
+		var opErr *OpError
+		if errors.As(err, &opErr) {
+			return opErr.QueryID, err
+		}
+		return "", err
+	}
+	nb := nativeBatch(b)
+	if !sameColumns(nb.block, block) {
+		b.Abort()
+		return nb.queryID, fmt.Errorf("clickhouse: the columns of %q changed while its rows were buffered", query)
+	}
+	nb.block = block
+	return nb.queryID, b.Send()
+}
+
+func nativeBatch(b driver.Batch) *batch {
+	if traced, ok := b.(*tracedBatch); ok {
+		b = traced.Batch
+	}
+	return b.(*batch)
+}
+
+func sameColumns(a, b *proto.Block) bool {
+	return slices.EqualFunc(a.Columns, b.Columns, func(x, y column.Interface) bool {
+		return x.Name() == y.Name() && x.Type() == y.Type()
+	})
+}
@@ -0,0 +1,187 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// asyncServer stands for the server of an AsyncInserter, it records the
+// blocks sent and fails or blocks the sends on demand.
+type asyncServer struct {
+	mu      sync.Mutex
+	headers int
+	rows    map[string][][]any // by query
+	err     error
+	block   chan struct{} // sends wait for it to be closed, if set
+}
+
+func (s *asyncServer) header(ctx context.Context, query string) (*proto.Block, error) {
+	s.mu.Lock()
+	s.headers++
+	s.mu.Unlock()
+	block := &proto.Block{ServerContext: &column.ServerContext{Timezone: time.UTC}}
+	if err := block.AddColumn("id", "UInt64"); err != nil {
+		return nil, err
+	}
+	if err := block.AddColumn("name", "String"); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (s *asyncServer) send(ctx context.Context, query string, block *proto.Block) (string, error) {
+	if settings := queryOptions(ctx).settings; settings["async_insert"] != 1 {
+		return "", errors.New("async_insert is not set")
+	}
+	s.mu.Lock()
+	wait := s.block
+	s.mu.Unlock()
+	if wait != nil {
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "q", s.err
+	}
+	if s.rows == nil {
+		s.rows = make(map[string][][]any)
+	}
+	for i := range block.Rows() {
+		s.rows[query] = append(s.rows[query], []any{block.Columns[0].Row(i, false), block.Columns[1].Row(i, false)})
+	}
+	return "q", nil
+}
+
+func (s *asyncServer) sent(query string) [][]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rows[query]
+}
+
+func TestAsyncInserterFlushPolicies(t *testing.T) {
+	ctx := context.Background()
+	server := &asyncServer{}
+	var (
+		mu      sync.Mutex
+		flushes []AsyncInsertFlush
+	)
+	inserter := newAsyncInserter(AsyncInserterOptions{
+		MaxRows:       2,
+		FlushInterval: 50 * time.Millisecond,
+		OnFlush: func(f AsyncInsertFlush) {
+			mu.Lock()
+			flushes = append(flushes, f)
+			mu.Unlock()
+		},
+	}, server.header, server.send)
+
+	require.NoError(t, inserter.Append(ctx, "events", uint64(1), "a"))
+	require.NoError(t, inserter.Append(ctx, "events", uint64(2), "b"))
+	require.Eventually(t, func() bool { return len(server.sent("INSERT INTO events")) == 2 }, time.Second, 5*time.Millisecond,
+		"MaxRows rows are flushed")
+
+	require.NoError(t, inserter.AppendStruct(ctx, "events", &struct {
+		ID   uint64 `ch:"id"`
+		Name string `ch:"name"`
+	}{ID: 3, Name: "c"}))
+	require.Eventually(t, func() bool { return len(server.sent("INSERT INTO events")) == 3 }, time.Second, 5*time.Millisecond,
+		"the rows are flushed every FlushInterval")
+	assert.Equal(t, 1, server.headers, "the columns are read once by table")
+
+	require.NoError(t, inserter.Close(ctx))
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, flushes, 2)
+	assert.Equal(t, AsyncInsertFlush{Table: "events", QueryID: "q", Rows: 2, Bytes: 20, Duration: flushes[0].Duration}, flushes[0])
+}
+
+func TestAsyncInserterRejectedRow(t *testing.T) {
+	ctx := context.Background()
+	server := &asyncServer{}
+	inserter := newAsyncInserter(AsyncInserterOptions{FlushInterval: time.Hour}, server.header, server.send)
+
+	require.NoError(t, inserter.Append(ctx, "events", uint64(1), "a"))
+	assert.Error(t, inserter.Append(ctx, "events", "not a number", "b"))
+	assert.Error(t, inserter.Append(ctx, "events", uint64(2)))
+	require.NoError(t, inserter.Append(ctx, "events", uint64(3), "c"))
+
+	require.NoError(t, inserter.Flush(ctx))
+	assert.Equal(t, [][]any{{uint64(1), "a"}, {uint64(3), "c"}}, server.sent("INSERT INTO events"),
+		"rejected rows leave the buffered rows untouched")
+	require.NoError(t, inserter.Close(ctx))
+}
+
+func TestAsyncInserterBackPressure(t *testing.T) {
+	ctx := context.Background()
+	server := &asyncServer{block: make(chan struct{})}
+	inserter := newAsyncInserter(AsyncInserterOptions{
+		MaxBytes:      10,
+		MemoryBudget:  20,
+		FlushInterval: time.Hour,
+	}, server.header, server.send)
+
+	// a row is 10 bytes, each of them is flushed and held by the server
+	require.NoError(t, inserter.Append(ctx, "events", uint64(1), "a"))
+	require.NoError(t, inserter.Append(ctx, "events", uint64(2), "b"))
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, inserter.Append(timeout, "events", uint64(3), "c"), context.DeadlineExceeded,
+		"appends wait while the memory budget is used up")
+
+	appended := make(chan error, 1)
+	go func() { appended <- inserter.Append(ctx, "events", uint64(3), "c") }()
+	close(server.block)
+	require.NoError(t, <-appended, "flushes free the budget")
+	require.NoError(t, inserter.Close(ctx))
+	assert.Len(t, server.sent("INSERT INTO events"), 3)
+}
+
+func TestAsyncInserterClose(t *testing.T) {
+	ctx := context.Background()
+	server := &asyncServer{err: errors.New("table is read only")}
+	var failed []AsyncInsertFlush
+	inserter := newAsyncInserter(AsyncInserterOptions{
+		FlushInterval: time.Hour,
+		OnFlush: func(f AsyncInsertFlush) {
+			if f.Err != nil {
+				failed = append(failed, f)
+			}
+		},
+	}, server.header, server.send)
+
+	require.NoError(t, inserter.Append(ctx, "events", uint64(1), "a"))
+	require.NoError(t, inserter.Append(ctx, "logs (id, name)", uint64(2), "b"))
+	err := inserter.Close(ctx)
+	assert.ErrorContains(t, err, "table is read only", "Close flushes the buffered rows")
+	assert.Len(t, failed, 2)
+	assert.ErrorIs(t, inserter.Append(ctx, "events", uint64(3), "c"), ErrAsyncInserterClosed)
+	assert.NoError(t, inserter.Close(ctx))
+
+	server = &asyncServer{block: make(chan struct{})}
+	inserter = newAsyncInserter(AsyncInserterOptions{FlushInterval: time.Hour}, server.header, server.send)
+	require.NoError(t, inserter.Append(ctx, "events", uint64(1), "a"))
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, inserter.Close(timeout), context.DeadlineExceeded, "Close cancels the flushes once ctx is done")
+}
+
+func TestValueSize(t *testing.T) {
+	name := "abc"
+	assert.Equal(t, 8+4+4+1, rowSize([]any{uint64(1), name, &name, nil}))
+	assert.Equal(t, 8+2*4, valueSize([]int32{1, 2}))
+	assert.Equal(t, 8+2+8, valueSize(map[string]int64{"a": 1}))
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"math/rand"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -37,14 +38,16 @@ var (
 	ErrAcquireConnNoAddress      = errors.New("clickhouse: no valid address supplied")
 	ErrServerUnexpectedData      = errors.New("code: 101, message: Unexpected packet Data received from client")
 	ErrConnectionClosed          = errors.New("clickhouse: connection is closed")
-	ErrFormatNativeUnsupported   = errors.New("clickhouse: QueryFormat and InsertFormat are only supported over the HTTP protocol, where the server converts every format; connect with Options{Protocol: clickhouse.HTTP} or an http:// DSN")
+	ErrFormatNativeUnsupported   = errors.New("clickhouse: over the native protocol QueryFormat and InsertFormat only support the client-side formats CSV, TSV, TSVWithNames, JSONEachRow, JSONCompactEachRow and RowBinary; for other formats connect with Options{Protocol: clickhouse.HTTP} or an http:// DSN, where the server converts every format")
 
 	errConnMaxLifetimeExceeded = errors.New("clickhouse: connection max lifetime exceeded")
+	errCredentialsExpired      = errors.New("clickhouse: connection credentials expired")
 )
 
 type OpError struct {
 	Op         string
 	ColumnName string
+	QueryID    string // query ID of the operation, if it failed on a query
 	Err        error
 }
 
@@ -72,9 +75,11 @@ func Open(opt *Options) (driver.Conn, error) {
 	}
 	o := opt.setDefaults()
 
+	idle := newConnPool(o.ConnMaxLifetime, o.MaxIdleConns)
 	conn := &clickhouse{
 		opt:       o,
-		idle:      newConnPool(o.ConnMaxLifetime, o.MaxIdleConns),
+		idle:      idle,
+		metrics:   idle.metrics,
 		open:      make(chan struct{}, o.MaxOpenConns),
 		closeOnce: &sync.Once{},
 		closed:    &atomic.Bool{},
@@ -95,10 +100,14 @@ type nativeTransport interface {
 	exec(ctx context.Context, query string, args ...any) error
 	asyncInsert(ctx context.Context, query string, wait bool, args ...any) error
 	ping(context.Context) error
+	tablesStatus(ctx context.Context, tables []proto.QualifiedTableName) (map[proto.QualifiedTableName]proto.TableStatus, error)
 	// healthCheck reports why the connection is unusable; nil means healthy.
 	healthCheck() error
 	connID() int
 	connectedAtTime() time.Time
+	// credentialsRefreshTime is when the credentials of the connection are
+	// due for refresh, zero if they never are.
+	credentialsRefreshTime() time.Time
 	isReleased() bool
 	setReleased(released bool)
 	getLogger() *slog.Logger
@@ -123,11 +132,17 @@ type clickhouse struct {
 	opt    *Options
 	connID atomic.Int64
 
-	idle connectionPooler
-	open chan struct{}
+	idle    connectionPooler
+	open    chan struct{}
+	metrics *poolMetrics
 
 	closeOnce *sync.Once
 	closed    *atomic.Bool
+
+	// hosts maps the ID of open connections to the address they were dialed to.
+	hosts sync.Map
+	// queries holds the query IDs of the running queries.
+	queries queryRegistry
 }
 
 // Contributors always returns an empty slice.
@@ -152,58 +167,123 @@ func (ch *clickhouse) ServerVersion() (*driver.ServerVersion, error) {
 	return conn.serverVersion()
 }
 
-func (ch *clickhouse) Query(ctx context.Context, query string, args ...any) (rows driver.Rows, err error) {
-	conn, err := ch.acquire(ctx)
+func (ch *clickhouse) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	ctx, _ = ch.withQueryID(ctx)
+	ctx, span := ch.startSpan(ctx, "clickhouse.query", query)
+	var r *rows
+	err := ch.withRetry(ctx, span, true, func(ctx context.Context, conn nativeTransport) (err error) {
+		conn.getLogger().Debug("executing query", slog.String("sql", query), slog.String("query_id", queryOptions(ctx).queryID))
+		span.connected(ch, conn)
+		r, err = conn.query(ctx, ch.trackQuery(ctx, span.release(ch.release)), query, args...)
+		return err
+	})
 	if err != nil {
+		span.end(err)
 		return nil, err
 	}
-	conn.getLogger().Debug("executing query", slog.String("sql", query))
-	return conn.query(ctx, ch.release, query, args...)
+	span.done()
+	return r, nil
 }
 
 func (ch *clickhouse) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
-	conn, err := ch.acquire(ctx)
+	ctx, _ = ch.withQueryID(ctx)
+	ctx, span := ch.startSpan(ctx, "clickhouse.query", query)
+	var r *row
+	err := ch.withRetry(ctx, span, true, func(ctx context.Context, conn nativeTransport) error {
+		conn.getLogger().Debug("executing query row", slog.String("sql", query), slog.String("query_id", queryOptions(ctx).queryID))
+		span.connected(ch, conn)
+		r = conn.queryRow(ctx, ch.trackQuery(ctx, span.release(ch.release)), query, args...)
+		return r.err
+	})
 	if err != nil {
+		span.end(err)
+	} else {
+		span.done()
+	}
+	if r == nil {
 		return &row{
 			err: err,
 		}
 	}
-
-	conn.getLogger().Debug("executing query row", slog.String("sql", query))
-	return conn.queryRow(ctx, ch.release, query, args...)
+	return r
 }
 
 func (ch *clickhouse) Exec(ctx context.Context, query string, args ...any) error {
-	conn, err := ch.acquire(ctx)
-	if err != nil {
-		return err
-	}
-	conn.getLogger().Debug("executing statement", slog.String("sql", query))
+	ctx, _ = ch.withQueryID(ctx)
+	ctx, span := ch.startSpan(ctx, "clickhouse.exec", query)
+	err := ch.withRetry(ctx, span, idempotentStatement(ctx, query), func(ctx context.Context, conn nativeTransport) (err error) {
+		conn.getLogger().Debug("executing statement", slog.String("sql", query), slog.String("query_id", queryOptions(ctx).queryID))
+		span.connected(ch, conn)
+		release := ch.trackQuery(ctx, ch.release)
+
+		if asyncOpt := queryOptionsAsync(ctx); asyncOpt.ok {
+			err = conn.asyncInsert(ctx, query, asyncOpt.wait, args...)
+		} else {
+			err = conn.exec(ctx, query, args...)
+		}
 
-	if asyncOpt := queryOptionsAsync(ctx); asyncOpt.ok {
-		err = conn.asyncInsert(ctx, query, asyncOpt.wait, args...)
-	} else {
-		err = conn.exec(ctx, query, args...)
-	}
+		if err != nil {
+			release(conn, err)
+			return err
+		}
 
-	if err != nil {
-		ch.release(conn, err)
-		return err
-	}
+		release(conn, nil)
+		return nil
+	})
+	span.end(err)
+	return err
+}
 
-	ch.release(conn, nil)
-	return nil
+// withRetry acquires a connection and runs op on it, retrying failures the
+// RetryPolicy in effect for ctx classifies as transient. op releases the connection.
+// Failures to acquire a connection never reached the server and count as idempotent.
+// Every attempt runs with a new query ID unless the caller set one, the error
+// returned carries the one of the last attempt.
+func (ch *clickhouse) withRetry(ctx context.Context, span *clientSpan, idempotent bool, op func(ctx context.Context, conn nativeTransport) error) error {
+	policy := retryPolicy(ctx, ch.opt)
+	for retry := 0; ; retry++ {
+		if retry != 0 {
+			var queryID string
+			ctx, queryID = retryQueryID(ctx, ch.opt)
+			span.setQueryID(queryID)
+		}
+		conn, err := ch.acquireReplica(ctx)
+		acquired := err == nil
+		if acquired {
+			err = op(ctx, conn)
+		}
+		if err == nil || policy == nil || retry >= policy.MaxRetries || !policy.retryable(err, idempotent || !acquired) {
+			return withQueryIDError(err, queryOptions(ctx).queryID)
+		}
+		backoff := policy.backoff(retry)
+		ch.opt.logger().Debug("retrying after transient error",
+			slog.String("query_id", queryOptions(ctx).queryID),
+			slog.Int("retry", retry+1),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+		if sleepContext(ctx, backoff) != nil {
+			return err
+		}
+	}
 }
 
 func (ch *clickhouse) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
-	conn, err := ch.acquire(ctx)
+	ctx, queryID := ch.withQueryID(ctx)
+	spanCtx, span := ch.startSpan(ctx, "clickhouse.batch.prepare", query)
+	conn, err := ch.acquire(spanCtx)
 	if err != nil {
+		span.end(err)
 		return nil, err
 	}
-	conn.getLogger().Debug("preparing batch", slog.String("sql", query))
-	batch, err := conn.prepareBatch(ctx, ch.release, ch.acquire, query, getPrepareBatchOptions(opts...))
+	conn.getLogger().Debug("preparing batch", slog.String("sql", query), slog.String("query_id", queryID))
+	span.connected(ch, conn)
+	batch, err := conn.prepareBatch(spanCtx, ch.trackQuery(spanCtx, ch.release), ch.acquire, query, getPrepareBatchOptions(opts...))
+	span.end(err)
 	if err != nil {
-		return nil, err
+		return nil, withQueryIDError(err, queryID)
+	}
+	if span != nil {
+		return &tracedBatch{Batch: batch, ch: ch, ctx: ctx, query: query}, nil
 	}
 	return batch, nil
 }
@@ -248,12 +328,26 @@ func (ch *clickhouse) Ping(ctx context.Context) (err error) {
 }
 
 func (ch *clickhouse) Stats() driver.Stats {
+	openByAddr := make(map[string]int)
+	ch.hosts.Range(func(_, addr any) bool {
+		openByAddr[addr.(string)]++
+		return true
+	})
 	return driver.Stats{
 		Open:         len(ch.open),
 		MaxOpenConns: cap(ch.open),
 
 		Idle:         ch.idle.Len(),
 		MaxIdleConns: ch.idle.Cap(),
+
+		AcquireCount:      ch.metrics.acquired.Load(),
+		WaitCount:         ch.metrics.waits.Load(),
+		WaitDuration:      time.Duration(ch.metrics.waitDuration.Load()),
+		DialErrors:        ch.metrics.dialErrors.Load(),
+		MaxIdleClosed:     ch.metrics.maxIdleClosed.Load(),
+		MaxLifetimeClosed: ch.metrics.maxLifetimeClosed.Load(),
+		BadConnClosed:     ch.metrics.badConnClosed.Load(),
+		OpenByAddr:        openByAddr,
 	}
 }
 
@@ -261,9 +355,26 @@ func (ch *clickhouse) dial(ctx context.Context) (conn nativeTransport, err error
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	ctx, span := ch.startSpan(ctx, "clickhouse.dial", "")
+	defer func() { span.end(err) }()
+
+	opt := ch.opt
+	if excluded := excludedHosts(ctx); len(excluded) != 0 {
+		addrs := slices.DeleteFunc(slices.Clone(opt.Addr), func(addr string) bool {
+			_, ok := excluded[addr]
+			return ok
+		})
+		if len(addrs) == 0 {
+			return nil, ErrAcquireConnNoAddress
+		}
+		filtered := *opt
+		filtered.Addr = addrs
+		opt = &filtered
+	}
 
 	connID := int(ch.connID.Add(1))
 
+	var dialedAddr string
 	dialFunc := func(ctx context.Context, addr string, opt *Options) (DialResult, error) {
 		var conn nativeTransport
 		var err error
@@ -273,22 +384,53 @@ func (ch *clickhouse) dial(ctx context.Context) (conn nativeTransport, err error
 		default:
 			conn, err = dial(ctx, addr, connID, opt)
 		}
+		if err == nil {
+			dialedAddr = addr
+		}
 
 		return DialResult{conn}, err
 	}
 
-	dialStrategy := DefaultDialStrategy
-	if ch.opt.DialStrategy != nil {
-		dialStrategy = ch.opt.DialStrategy
+	var result DialResult
+	if selector := opt.HostSelector; selector != nil && opt.DialStrategy == nil {
+		result, _, err = dialSelectedHost(ctx, selector, opt.Addr, func(ctx context.Context, addr string) (DialResult, error) {
+			return dialFunc(ctx, addr, opt)
+		})
+	} else {
+		dialStrategy := DefaultDialStrategy
+		if opt.DialStrategy != nil {
+			dialStrategy = opt.DialStrategy
+		}
+		result, err = dialStrategy(ctx, connID, opt, dialFunc)
 	}
-
-	result, err := dialStrategy(ctx, connID, ch.opt, dialFunc)
 	if err != nil {
 		return nil, err
 	}
+	ch.trackConn(connID, dialedAddr, result.conn)
+	span.connected(ch, result.conn)
 	return result.conn, nil
 }
 
+// trackConn records the address of a new connection until it is closed, and
+// counts it as open with Options.HostSelector.
+func (ch *clickhouse) trackConn(connID int, addr string, conn nativeTransport) {
+	hooker, ok := conn.(closeHooker)
+	if !ok || addr == "" {
+		return
+	}
+	ch.hosts.Store(connID, addr)
+	selector := ch.opt.HostSelector
+	if selector != nil {
+		selector.Connected(addr)
+	}
+	hooker.setCloseHook(func() {
+		ch.hosts.Delete(connID)
+		if selector != nil {
+			selector.Disconnected(addr)
+		}
+	})
+}
+
 func DefaultDialStrategy(ctx context.Context, connID int, opt *Options, dial Dial) (r DialResult, err error) {
 	for i := range opt.Addr {
 		var num int
@@ -318,6 +460,8 @@ func (ch *clickhouse) acquire(ctx context.Context) (conn nativeTransport, err er
 	if ch.closed.Load() {
 		return nil, ErrConnectionClosed
 	}
+	ctx, span := ch.startSpan(ctx, "clickhouse.acquire", "")
+	defer func() { span.end(err) }()
 
 	ctx, cancel := context.WithTimeoutCause(ctx, ch.opt.DialTimeout, ErrAcquireConnTimeout)
 	defer cancel()
@@ -335,8 +479,17 @@ func (ch *clickhouse) acquire(ctx context.Context) (conn nativeTransport, err er
 
 	select {
 	case ch.open <- struct{}{}:
-	case <-ctx.Done():
-		return nil, context.Cause(ctx)
+	default:
+		// every connection is in use, wait for one to be released
+		start := time.Now()
+		ch.metrics.waits.Add(1)
+		select {
+		case ch.open <- struct{}{}:
+			ch.metrics.waitDuration.Add(int64(time.Since(start)))
+		case <-ctx.Done():
+			ch.metrics.waitDuration.Add(int64(time.Since(start)))
+			return nil, context.Cause(ctx)
+		}
 	}
 
 	conn, err = ch.idle.Get(ctx)
@@ -352,9 +505,11 @@ func (ch *clickhouse) acquire(ctx context.Context) (conn nativeTransport, err er
 		if badErr := conn.healthCheck(); badErr == nil {
 			conn.setReleased(false)
 			conn.getLogger().Debug("connection acquired from pool")
+			ch.metrics.acquired.Add(1)
 			return conn, nil
 		} else {
 			conn.getLogger().Debug("closing bad connection from pool", slog.Any("reason", badErr))
+			ch.metrics.badConnClosed.Add(1)
 			conn.close()
 		}
 	}
@@ -365,10 +520,12 @@ func (ch *clickhouse) acquire(ctx context.Context) (conn nativeTransport, err er
 		default:
 		}
 
+		ch.metrics.dialErrors.Add(1)
 		return nil, err
 	}
 
 	conn.getLogger().Debug("new connection established")
+	ch.metrics.acquired.Add(1)
 	return conn, nil
 
 }
@@ -390,14 +547,24 @@ func (ch *clickhouse) release(conn nativeTransport, err error) {
 	default:
 	}
 
+	var cancelled *cancelledError
+	if errors.As(err, &cancelled) {
+		// the connection of the cancelled query is drained
+		err = nil
+	}
 	if err != nil {
+		if addr, ok := ch.hosts.Load(conn.connID()); ok && ch.opt.HostSelector != nil && isConnBrokenError(err) {
+			ch.opt.HostSelector.Report(addr.(string), 0, err)
+		}
 		conn.getLogger().Debug("connection closed due to error", slog.Any("error", err))
+		ch.metrics.badConnClosed.Add(1)
 		conn.close()
 		return
 	} else if time.Since(conn.connectedAtTime()) >= ch.opt.ConnMaxLifetime {
 		conn.getLogger().Debug("connection closed: lifetime expired",
 			slog.Duration("age", time.Since(conn.connectedAtTime())),
 			slog.Duration("max_lifetime", ch.opt.ConnMaxLifetime))
+		ch.metrics.maxLifetimeClosed.Add(1)
 		conn.close()
 		return
 	}
@@ -0,0 +1,160 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// QueryIter executes query on conn and returns an iterator over the result
+// rows scanned into T.
+//
+// T is either a struct, whose fields are matched to the result columns the
+// same way as ScanStruct does, or any type a single column result can be
+// scanned into. The query is only sent when the iterator is ranged over.
+// Breaking out of the loop closes the rows, which releases the connection
+// back to the pool. A non-nil error is always the last value yielded.
+//
+//	for user, err := range clickhouse.QueryIter[User](ctx, conn, "SELECT id, name FROM users") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func QueryIter[T any](ctx context.Context, conn driver.Conn, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		rows, err := conn.Query(ctx, query, args...)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		for value, err := range RowsIter[T](rows) {
+			if !yield(value, err) {
+				return
+			}
+		}
+	}
+}
+
+// RowsIter returns an iterator over rows scanned into T, see QueryIter.
+// The rows are closed once the iteration stops.
+func RowsIter[T any](rows driver.Rows) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		defer rows.Close()
+		scanner, err := newRowsScanner[T](rows)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		for rows.Next() {
+			var value T
+			if err := scanner.scan(&value); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(value, nil) {
+				return
+			}
+		}
+		if err := rows.Close(); err != nil {
+			yield(zero, err)
+			return
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// rowsScanner scans rows into T, resolving struct fields once per query instead of once per row.
+type rowsScanner[T any] struct {
+	rows      driver.Rows
+	fields    []structField
+	dest      []any
+	converted []func() error
+}
+
+func newRowsScanner[T any](src driver.Rows) (*rowsScanner[T], error) {
+	var (
+		t       = reflect.TypeFor[T]()
+		columns = src.Columns()
+		scanner = &rowsScanner[T]{rows: src, dest: make([]any, len(columns))}
+	)
+	if t.Kind() == reflect.Struct {
+		var (
+			index map[string]structField
+			err   error
+		)
+		switch r, ok := src.(*rows); {
+		case ok && r.structMap != nil:
+			index, err = r.structMap.index(t)
+		default:
+			index, err = structIdx(t)
+		}
+		if err != nil {
+			return nil, &OpError{
+				Op:  "RowsIter",
+				Err: err,
+			}
+		}
+		fields := make([]structField, 0, len(columns))
+		for _, name := range columns {
+			if field, found := index[name]; found {
+				fields = append(fields, field)
+			}
+		}
+		switch {
+		case len(fields) == len(columns):
+			scanner.fields = fields
+			return scanner, nil
+		case len(columns) != 1:
+			for _, name := range columns {
+				if _, found := index[name]; !found {
+					return nil, &OpError{
+						Op:  "RowsIter",
+						Err: fmt.Errorf("missing destination name %q in %s", name, t),
+					}
+				}
+			}
+		}
+	}
+	if len(columns) != 1 {
+		return nil, &OpError{
+			Op:  "RowsIter",
+			Err: fmt.Errorf("expected 1 column to scan into %s, got %d", t, len(columns)),
+		}
+	}
+	return scanner, nil
+}
+
+func (s *rowsScanner[T]) scan(value *T) error {
+	if s.fields == nil {
+		return s.rows.Scan(value)
+	}
+	v := reflect.ValueOf(value).Elem()
+	s.converted = s.converted[:0]
+	for i := range s.fields {
+		dest, convert := s.fields[i].scanDest(v)
+		if convert != nil {
+			s.converted = append(s.converted, convert)
+		}
+		s.dest[i] = dest
+	}
+	if err := s.rows.Scan(s.dest...); err != nil {
+		return err
+	}
+	for _, convert := range s.converted {
+		if err := convert(); err != nil {
+			return &OpError{
+				Op:  "RowsIter",
+				Err: err,
+			}
+		}
+	}
+	return nil
+}
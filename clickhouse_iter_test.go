@@ -0,0 +1,123 @@
+package clickhouse
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+func newIterTestRows(t *testing.T, blocks, rowsPerBlock int) *rows {
+	t.Helper()
+	newBlock := func(start int) *proto.Block {
+		block := &proto.Block{ServerContext: &column.ServerContext{}}
+		require.NoError(t, block.AddColumn("id", "Int64"))
+		require.NoError(t, block.AddColumn("name", "String"))
+		for i := start; i < start+rowsPerBlock; i++ {
+			require.NoError(t, block.Append(int64(i), strconv.Itoa(i)))
+		}
+		return block
+	}
+	var (
+		first  = newBlock(0)
+		stream = make(chan *proto.Block)
+	)
+	go func() {
+		for i := 1; i < blocks; i++ {
+			stream <- newBlock(i * rowsPerBlock)
+		}
+		close(stream)
+	}()
+	return &rows{
+		block:     first,
+		stream:    stream,
+		columns:   first.ColumnsNames(),
+		structMap: &structMap{},
+	}
+}
+
+func TestRowsIterStruct(t *testing.T) {
+	type record struct {
+		ID   int64  `ch:"id"`
+		Name string `ch:"name"`
+	}
+	var ids []int64
+	for rec, err := range RowsIter[record](newIterTestRows(t, 3, 4)) {
+		require.NoError(t, err)
+		assert.Equal(t, strconv.FormatInt(rec.ID, 10), rec.Name)
+		ids = append(ids, rec.ID)
+	}
+	assert.Len(t, ids, 12)
+	assert.Equal(t, int64(11), ids[11])
+}
+
+func TestRowsIterBreakClosesRows(t *testing.T) {
+	type record struct {
+		ID   int64  `ch:"id"`
+		Name string `ch:"name"`
+	}
+	r := newIterTestRows(t, 5, 10)
+	var count int
+	for _, err := range RowsIter[record](r) {
+		require.NoError(t, err)
+		if count++; count == 15 {
+			break
+		}
+	}
+	assert.Equal(t, 15, count)
+	assert.True(t, r.closed)
+	_, open := <-r.stream
+	assert.False(t, open, "stream should be drained")
+}
+
+func TestRowsIterErrors(t *testing.T) {
+	t.Run("missing field", func(t *testing.T) {
+		type record struct {
+			ID int64 `ch:"id"`
+		}
+		var calls int
+		for _, err := range RowsIter[record](newIterTestRows(t, 1, 2)) {
+			calls++
+			var opErr *OpError
+			require.True(t, errors.As(err, &opErr))
+			assert.Contains(t, err.Error(), `missing destination name "name"`)
+		}
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("scalar with many columns", func(t *testing.T) {
+		for _, err := range RowsIter[int64](newIterTestRows(t, 1, 2)) {
+			assert.ErrorContains(t, err, "expected 1 column")
+		}
+	})
+	t.Run("scan error", func(t *testing.T) {
+		type record struct {
+			ID   string `ch:"id"`
+			Name string `ch:"name"`
+		}
+		var calls int
+		for _, err := range RowsIter[record](newIterTestRows(t, 2, 2)) {
+			calls++
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestRowsIterScalar(t *testing.T) {
+	block := &proto.Block{ServerContext: &column.ServerContext{}}
+	require.NoError(t, block.AddColumn("name", "String"))
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, block.Append(name))
+	}
+	var names []string
+	for name, err := range RowsIter[string](&rows{block: block, columns: block.ColumnsNames()}) {
+		require.NoError(t, err)
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
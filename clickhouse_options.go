@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/ClickHouse/ch-go/compress"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/churl"
 )
@@ -66,6 +68,10 @@ type Auth struct { // has_control_character
 
 	Username string
 	Password string
+
+	// SSHKey signs the challenge of users IDENTIFIED WITH ssh_key, in place
+	// of Password, see SSHKeyFromFile. Native protocol only.
+	SSHKey ssh.Signer
 }
 
 type Compression struct {
@@ -124,6 +130,9 @@ type Options struct {
 	Auth         Auth
 	DialContext  func(ctx context.Context, addr string) (net.Conn, error)
 	DialStrategy func(ctx context.Context, connID int, options *Options, dial Dial) (DialResult, error)
+	// HostSelector picks the address of each new connection from Addr and tracks
+	// the health of the hosts, replacing ConnOpenStrategy. See NewHostHealthSelector.
+	HostSelector HostSelector
 
 	// Deprecated: Use Logger instead. Debug enables legacy debug logging to stdout.
 	// For structured logging with levels, use the Logger field.
@@ -171,6 +180,15 @@ type Options struct {
 	// Use this instead of Auth.Username and Auth.Password if you're using JWT auth.
 	GetJWT GetJWTFunc
 
+	// CredentialsProvider returns the Username, Password or SSHKey of each new
+	// native connection and HTTP request in place of those of Auth, and when
+	// they expire. Credentials are refreshed ahead of their expiry, and pooled
+	// native connections dialed with them are retired before it. Credentials
+	// without expiry are reused until the server rejects them. The Database
+	// of Auth is kept.
+	CredentialsProvider CredentialsProviderFunc
+	credentialsCache    *credentialsCache
+
 	scheme string
 
 	// ReadTimeout is the maximum duration the client will wait for ClickHouse
@@ -178,6 +196,41 @@ type Options struct {
 	// Can be overridden with context.WithDeadline.
 	ReadTimeout time.Duration
 
+	// CancelDrainTimeout enables the graceful cancellation of native queries:
+	// once their context is done, the client sends a cancel request and reads
+	// the rest of the stream for up to this duration, after which the
+	// connection returns to the pool instead of being closed. Context deadlines
+	// then cancel queries rather than interrupting reads. Disabled when 0 (default).
+	CancelDrainTimeout time.Duration
+
+	// KillQueryOnCancel kills HTTP queries with KILL QUERY on another
+	// connection when their context is done. Disabled by default.
+	KillQueryOnCancel bool
+
+	// QueryIDPrefix prefixes the UUID query ID generated for operations
+	// without one from WithQueryID, e.g. the name of the service.
+	QueryIDPrefix string
+
+	// RetryPolicy retries transient failures of Exec, Query, QueryRow and Batch.Send.
+	// Disabled when nil (default), see RetryPolicy for which failures are retried.
+	RetryPolicy *RetryPolicy
+
+	// TracerProvider creates client spans for queries, statements, batches,
+	// dials and pool acquisitions. No spans are created when nil (default).
+	TracerProvider trace.TracerProvider
+
+	// TraceServerEvents adds a "clickhouse.server" child span to query spans,
+	// timed by the server elapsed time and carrying the profile events and
+	// server logs (see send_logs_level) of the query. Native protocol only.
+	TraceServerEvents bool
+
+	// Transactions maps database/sql transactions to ClickHouse transactions:
+	// BeginTx issues BEGIN TRANSACTION and Commit/Rollback COMMIT/ROLLBACK on
+	// the connection of the transaction. The server must allow experimental
+	// transactions. By default (false) a database/sql transaction only sends
+	// the batch of its prepared INSERT on Commit.
+	Transactions bool
+
 	// Set a custom transport for the http client.
 	// The default transport configured by the library is passed in as an argument.
 	TransportFunc func(*http.Transport) (http.RoundTripper, error)
@@ -202,10 +255,12 @@ func (o *Options) fromDSN(in string) error {
 	}
 	o.Addr = append(o.Addr, strings.Split(dsn.Host, ",")...)
 	var (
-		secure        bool
-		params        = dsn.Query()
-		skipVerify    bool
-		tlsServerName string
+		secure           bool
+		params           = dsn.Query()
+		skipVerify       bool
+		tlsServerName    string
+		sshKeyPath       string
+		sshKeyPassphrase string
 	)
 	o.Auth.Database = strings.TrimPrefix(dsn.Path, "/")
 
@@ -333,6 +388,10 @@ func (o *Options) fromDSN(in string) error {
 			o.Auth.Password = params.Get(v)
 		case "database":
 			o.Auth.Database = params.Get(v)
+		case "ssh_key_path":
+			sshKeyPath = params.Get(v)
+		case "ssh_key_passphrase":
+			sshKeyPassphrase = params.Get(v)
 		case "client_info_product":
 			chunks := strings.Split(params.Get(v), ",")
 
@@ -356,6 +415,26 @@ func (o *Options) fromDSN(in string) error {
 				path = "/" + path
 			}
 			o.HttpUrlPath = path
+		case "cancel_drain_timeout":
+			duration, err := time.ParseDuration(params.Get(v))
+			if err != nil {
+				return fmt.Errorf("clickhouse [dsn parse]: cancel drain timeout: %s", err)
+			}
+			o.CancelDrainTimeout = duration
+		case "query_id_prefix":
+			o.QueryIDPrefix = params.Get(v)
+		case "kill_query_on_cancel":
+			kill, err := strconv.ParseBool(params.Get(v))
+			if err != nil {
+				return fmt.Errorf("clickhouse [dsn parse]: kill_query_on_cancel: %s", err)
+			}
+			o.KillQueryOnCancel = kill
+		case "transactions":
+			transactions, err := strconv.ParseBool(params.Get(v))
+			if err != nil {
+				return fmt.Errorf("clickhouse [dsn parse]: transactions: %s", err)
+			}
+			o.Transactions = transactions
 		default:
 			switch p := strings.ToLower(params.Get(v)); p {
 			case "true":
@@ -371,6 +450,11 @@ func (o *Options) fromDSN(in string) error {
 			}
 		}
 	}
+	if sshKeyPath != "" {
+		if o.Auth.SSHKey, err = SSHKeyFromFile(sshKeyPath, sshKeyPassphrase); err != nil {
+			return fmt.Errorf("clickhouse [dsn parse]: ssh_key_path: %w", err)
+		}
+	}
 	if tlsServerName != "" && !secure {
 		return fmt.Errorf("clickhouse [dsn parse]: tls_server_name requires secure=true")
 	}
@@ -424,6 +508,15 @@ func (o Options) setDefaults() *Options {
 	if o.MaxCompressionBuffer <= 0 {
 		o.MaxCompressionBuffer = 10485760
 	}
+	if o.RetryPolicy != nil {
+		o.RetryPolicy = o.RetryPolicy.setDefaults()
+	}
+	if o.CredentialsProvider != nil {
+		o.credentialsCache = &credentialsCache{
+			provider: o.CredentialsProvider,
+			timeout:  o.DialTimeout,
+		}
+	}
 	if len(o.Addr) == 0 {
 		switch o.Protocol {
 		case Native:
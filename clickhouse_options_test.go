@@ -548,6 +548,46 @@ func TestParseDSN(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"database/sql transactions",
+			"clickhouse://127.0.0.1:9000?transactions=true",
+			&Options{
+				Protocol:     Native,
+				TLS:          nil,
+				Addr:         []string{"127.0.0.1:9000"},
+				Settings:     Settings{},
+				Transactions: true,
+				scheme:       "clickhouse",
+			},
+			"",
+		},
+		{
+			"query cancellation",
+			"clickhouse://127.0.0.1:9000?cancel_drain_timeout=5s&kill_query_on_cancel=true",
+			&Options{
+				Protocol:           Native,
+				TLS:                nil,
+				Addr:               []string{"127.0.0.1:9000"},
+				Settings:           Settings{},
+				CancelDrainTimeout: 5 * time.Second,
+				KillQueryOnCancel:  true,
+				scheme:             "clickhouse",
+			},
+			"",
+		},
+		{
+			"query id prefix",
+			"clickhouse://127.0.0.1:9000?query_id_prefix=billing-",
+			&Options{
+				Protocol:      Native,
+				TLS:           nil,
+				Addr:          []string{"127.0.0.1:9000"},
+				Settings:      Settings{},
+				QueryIDPrefix: "billing-",
+				scheme:        "clickhouse",
+			},
+			"",
+		},
 		{
 			"multiple hosts in HA mode",
 			"clickhouse://127.0.0.1:9440,127.0.0.2:9440/test_database",
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"io"
 
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
 )
 
@@ -17,6 +19,7 @@ type rows struct {
 	columns   []string
 	structMap *structMap
 	closed    bool
+	queryID   string
 }
 
 func (r *rows) Next() (result bool) {
@@ -55,19 +58,49 @@ next:
 	return r.row <= r.block.Rows()
 }
 
+func (r *rows) NextBlock() (_ driver.Block, result bool) {
+	defer func() {
+		if !result {
+			r.Close()
+		}
+	}()
+	for r.block != nil {
+		if r.row == 0 && r.block.Rows() != 0 {
+			r.row = r.block.Rows()
+			return &resultBlock{block: r.block}, true
+		}
+		if r.stream == nil {
+			return nil, false
+		}
+		select {
+		case err := <-r.errors:
+			if err != nil {
+				r.err = err
+				return nil, false
+			}
+		case block := <-r.stream:
+			if block == nil {
+				return nil, false
+			}
+			if block.Packet == proto.ServerTotals {
+				r.row, r.block, r.totals = 0, nil, block
+				return nil, false
+			}
+			r.row, r.block = 0, block
+		}
+	}
+	return nil, false
+}
+
 func (r *rows) Scan(dest ...any) error {
 	if r.block == nil || (r.row == 0 && r.row >= r.block.Rows()) { // call without next when result is empty
 		return io.EOF
 	}
-	return scan(r.block, r.row, dest...)
+	return withQueryIDError(scan(r.block, r.row, dest...), r.queryID)
 }
 
 func (r *rows) ScanStruct(dest any) error {
-	values, err := r.structMap.Map("ScanStruct", r.columns, dest, true)
-	if err != nil {
-		return err
-	}
-	return r.Scan(values...)
+	return withQueryIDError(r.structMap.Scan("ScanStruct", r.columns, dest, r.Scan), r.queryID)
 }
 
 func (r *rows) Totals(dest ...any) error {
@@ -82,6 +115,10 @@ func (r *rows) Columns() []string {
 }
 
 func (r *rows) Close() error {
+	return withQueryIDError(r.close(), r.queryID)
+}
+
+func (r *rows) close() error {
 	r.closed = true
 	if r.errors == nil && r.stream == nil {
 		return r.err
@@ -124,9 +161,18 @@ func (r *rows) Close() error {
 }
 
 func (r *rows) Err() error {
-	return r.err
+	return withQueryIDError(r.err, r.queryID)
+}
+
+func (r *rows) QueryID() string {
+	return r.queryID
 }
 
+var (
+	_ driver.BlockRows       = (*rows)(nil)
+	_ driver.QueryIDProvider = (*rows)(nil)
+)
+
 func (r *rows) HasData() bool {
 	if r.closed {
 		return false
@@ -158,6 +204,22 @@ func (r *rows) HasData() bool {
 	}
 }
 
+type resultBlock struct {
+	block *proto.Block
+}
+
+func (b *resultBlock) Rows() int {
+	return b.block.Rows()
+}
+
+func (b *resultBlock) Columns() []column.Interface {
+	return b.block.Columns
+}
+
+func (b *resultBlock) ColumnNames() []string {
+	return b.block.ColumnsNames()
+}
+
 type row struct {
 	err  error
 	rows *rows
@@ -171,11 +233,7 @@ func (r *row) ScanStruct(dest any) error {
 	if r.err != nil {
 		return r.err
 	}
-	values, err := r.rows.structMap.Map("ScanStruct", r.rows.columns, dest, true)
-	if err != nil {
-		return err
-	}
-	return r.Scan(values...)
+	return r.rows.structMap.Scan("ScanStruct", r.rows.columns, dest, r.Scan)
 }
 
 func (r *row) Scan(dest ...any) error {
@@ -117,3 +117,40 @@ func TestReadWithEmptyBlock(t *testing.T) {
 		})
 	}
 }
+
+func TestRowsNextBlock(t *testing.T) {
+	newBlock := func(start, n int) *proto.Block {
+		block := &proto.Block{ServerContext: &column.ServerContext{}}
+		block.AddColumn("id", "Int64")
+		block.AddColumn("tags", "Array(String)")
+		for i := start; i < start+n; i++ {
+			block.Append(int64(i), []string{strconv.Itoa(i), "tag"})
+		}
+		return block
+	}
+	stream := make(chan *proto.Block, 3)
+	stream <- newBlock(2, 0)
+	stream <- newBlock(2, 3)
+	stream <- newBlock(5, 2)
+	close(stream)
+	r := &rows{block: newBlock(0, 2), stream: stream}
+
+	// consume the first block row by row, NextBlock continues with the next one
+	assert.True(t, r.Next())
+	var ids []int64
+	for {
+		block, ok := r.NextBlock()
+		if !ok {
+			break
+		}
+		assert.Equal(t, []string{"id", "tags"}, block.ColumnNames())
+		ids = append(ids, block.Columns()[0].(*column.Int64).Data()...)
+		tags := block.Columns()[1].(*column.Array)
+		assert.Equal(t, block.Rows(), len(tags.Offsets()[0]))
+		assert.Len(t, tags.Base().(*column.String).Data(), 2*block.Rows())
+	}
+	assert.Equal(t, []int64{2, 3, 4, 5, 6}, ids)
+	assert.NoError(t, r.Err())
+	assert.True(t, r.closed)
+	assert.False(t, r.Next())
+}
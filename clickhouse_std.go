@@ -8,12 +8,17 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"math/rand"
 	"net"
 	"reflect"
+	"regexp"
+	"slices"
 	"sync/atomic"
 	"syscall"
 
+	"github.com/google/uuid"
+
 	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
 	chdriver "github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
@@ -59,6 +64,22 @@ func (o *stdConnOpener) Connect(ctx context.Context) (_ driver.Conn, err error)
 		return nil, ErrAcquireConnNoAddress
 	}
 
+	if selector := o.opt.HostSelector; selector != nil {
+		conn, addr, err := dialSelectedHost(ctx, selector, o.opt.Addr, func(ctx context.Context, addr string) (stdConnect, error) {
+			return dialFunc(ctx, addr, connID, o.opt)
+		})
+		if err != nil {
+			o.logger.Error("connection error", slog.Int("conn_id", connID), slog.Any("error", err))
+			return nil, err
+		}
+		trackHost(selector, addr, conn)
+		return &stdDriver{
+			opt:    o.opt,
+			conn:   conn,
+			logger: o.logger.With(slog.String("addr", addr)),
+		}, nil
+	}
+
 	for i := range o.opt.Addr {
 		var num int
 		switch o.opt.ConnOpenStrategy {
@@ -77,6 +98,7 @@ func (o *stdConnOpener) Connect(ctx context.Context) (_ driver.Conn, err error)
 				slog.String("addr", o.opt.Addr[num]),
 			)
 			return &stdDriver{
+				opt:    o.opt,
 				conn:   conn,
 				logger: connLogger,
 			}, nil
@@ -156,10 +178,12 @@ type stdConnect interface {
 }
 
 type stdDriver struct {
-	opt    *Options
-	conn   stdConnect
-	commit func() error
-	logger *slog.Logger
+	opt      *Options
+	conn     stdConnect
+	commit   func() error
+	logger   *slog.Logger
+	tx       bool // in a ClickHouse transaction, see Options.Transactions
+	readOnly bool // the transaction is read only
 }
 
 var _ driver.Conn = (*stdDriver)(nil)
@@ -204,12 +228,7 @@ func (std *stdDriver) Ping(ctx context.Context) error {
 var _ driver.Pinger = (*stdDriver)(nil)
 
 func (std *stdDriver) Begin() (driver.Tx, error) {
-	if err := std.conn.healthCheck(); err != nil {
-		std.logger.Debug("begin: connection is bad", slog.Any("reason", err))
-		return nil, driver.ErrBadConn
-	}
-
-	return std, nil
+	return std.BeginTx(context.Background(), driver.TxOptions{})
 }
 
 func (std *stdDriver) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
@@ -217,11 +236,37 @@ func (std *stdDriver) BeginTx(ctx context.Context, opts driver.TxOptions) (drive
 		std.logger.Debug("begin tx: connection is bad", slog.Any("reason", err))
 		return nil, driver.ErrBadConn
 	}
+	if std.opt == nil || !std.opt.Transactions {
+		return std, nil
+	}
 
+	// ClickHouse transactions have snapshot isolation
+	switch level := sql.IsolationLevel(opts.Isolation); level {
+	case sql.LevelDefault, sql.LevelSnapshot:
+	default:
+		return nil, fmt.Errorf("clickhouse: isolation level %s is not supported by transactions", level)
+	}
+	if h, ok := std.conn.(*httpConnect); ok {
+		// the statements of the transaction share a server session
+		h.session = &chdriver.SessionOptions{ID: uuid.NewString()}
+	}
+	if err := std.conn.exec(ctx, "BEGIN TRANSACTION"); err != nil {
+		std.endTx()
+		if isConnBrokenError(err) {
+			std.logger.Error("begin transaction got a fatal error, resetting connection", slog.Any("error", err))
+			return nil, driver.ErrBadConn
+		}
+		std.logger.Error("begin transaction error", slog.Any("error", err))
+		return nil, err
+	}
+	std.tx, std.readOnly = true, opts.ReadOnly
 	return std, nil
 }
 
 func (std *stdDriver) Commit() error {
+	if std.tx {
+		return std.commitTx()
+	}
 	if std.commit == nil {
 		return nil
 	}
@@ -240,12 +285,77 @@ func (std *stdDriver) Commit() error {
 	return nil
 }
 
+// commitTx sends the batch of the transaction, if any, and commits it.
+func (std *stdDriver) commitTx() error {
+	if commit := std.commit; commit != nil {
+		std.commit = nil
+		if err := commit(); err != nil {
+			std.logger.Error("commit error", slog.Any("error", err))
+			if rErr := std.Rollback(); errors.Is(rErr, driver.ErrBadConn) || isConnBrokenError(err) {
+				return driver.ErrBadConn
+			}
+			return err
+		}
+	}
+	defer std.endTx()
+	if err := std.conn.exec(context.Background(), "COMMIT"); err != nil {
+		if isConnBrokenError(err) {
+			std.logger.Error("commit transaction got a fatal error, resetting connection", slog.Any("error", err))
+			return driver.ErrBadConn
+		}
+		std.logger.Error("commit transaction error", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
 func (std *stdDriver) Rollback() error {
+	if !std.tx {
+		std.commit = nil
+		std.conn.close()
+		return nil
+	}
+	defer std.endTx()
+	if _, ok := std.conn.(*httpConnect); !ok && std.commit != nil {
+		// the INSERT of the batch is open on the connection, closing it
+		// rolls back the transaction
+		std.commit = nil
+		std.conn.close()
+		return nil
+	}
 	std.commit = nil
-	std.conn.close()
+	if err := std.conn.exec(context.Background(), "ROLLBACK"); err != nil {
+		std.logger.Error("rollback transaction error, closing connection", slog.Any("error", err))
+		std.conn.close()
+		return driver.ErrBadConn
+	}
 	return nil
 }
 
+// endTx ends the ClickHouse transaction on the connection.
+func (std *stdDriver) endTx() {
+	std.tx, std.readOnly = false, false
+	if h, ok := std.conn.(*httpConnect); ok {
+		h.session = nil
+	}
+}
+
+// txContext applies the transaction options to the statements in it.
+func (std *stdDriver) txContext(ctx context.Context) context.Context {
+	if !std.readOnly {
+		return ctx
+	}
+	return Context(ctx, func(o *QueryOptions) error {
+		o.settings = maps.Clone(o.settings)
+		if o.settings == nil {
+			o.settings = make(Settings)
+		}
+		// allows settings, unlike readonly = 1
+		o.settings["readonly"] = 2
+		return nil
+	})
+}
+
 var _ driver.Tx = (*stdDriver)(nil)
 
 func (std *stdDriver) CheckNamedValue(nv *driver.NamedValue) error { return nil }
@@ -257,6 +367,7 @@ func (std *stdDriver) ExecContext(ctx context.Context, query string, args []driv
 		std.logger.Debug("exec context: connection is bad", slog.Any("reason", err))
 		return nil, driver.ErrBadConn
 	}
+	ctx, queryID := contextWithQueryID(std.txContext(ctx), std.opt)
 
 	var err error
 	if asyncOpt := queryOptionsAsync(ctx); asyncOpt.ok {
@@ -270,8 +381,8 @@ func (std *stdDriver) ExecContext(ctx context.Context, query string, args []driv
 			std.logger.Error("exec context got a fatal error, resetting connection", slog.Any("error", err))
 			return nil, driver.ErrBadConn
 		}
-		std.logger.Error("exec context error", slog.Any("error", err))
-		return nil, err
+		std.logger.Error("exec context error", slog.Any("error", err), slog.String("query_id", queryID))
+		return nil, withQueryIDError(err, queryID)
 	}
 	return driver.RowsAffected(0), nil
 }
@@ -281,6 +392,7 @@ func (std *stdDriver) QueryContext(ctx context.Context, query string, args []dri
 		std.logger.Debug("query context: connection is bad", slog.Any("reason", err))
 		return nil, driver.ErrBadConn
 	}
+	ctx, queryID := contextWithQueryID(std.txContext(ctx), std.opt)
 
 	r, err := std.conn.query(ctx, func(nativeTransport, error) {}, query, rebind(args)...)
 	if isConnBrokenError(err) {
@@ -288,8 +400,8 @@ func (std *stdDriver) QueryContext(ctx context.Context, query string, args []dri
 		return nil, driver.ErrBadConn
 	}
 	if err != nil {
-		std.logger.Error("query context error", slog.Any("error", err))
-		return nil, err
+		std.logger.Error("query context error", slog.Any("error", err), slog.String("query_id", queryID))
+		return nil, withQueryIDError(err, queryID)
 	}
 	return &stdRows{
 		rows:   r,
@@ -306,8 +418,12 @@ func (std *stdDriver) PrepareContext(ctx context.Context, query string) (driver.
 		std.logger.Debug("prepare context: connection is bad", slog.Any("reason", err))
 		return nil, driver.ErrBadConn
 	}
+	if !insertQueryMatch.MatchString(query) {
+		return newStdStmt(std, query), nil
+	}
+	ctx = std.txContext(ctx)
 
-	batch, err := std.conn.prepareBatch(ctx, func(nativeTransport, error) {}, func(context.Context) (nativeTransport, error) { return nil, nil }, query, chdriver.PrepareBatchOptions{})
+	batch, err := std.conn.prepareBatch(ctx, func(nativeTransport, error) {}, nil, query, chdriver.PrepareBatchOptions{})
 	if err != nil {
 		if isConnBrokenError(err) {
 			std.logger.Error("prepare context got a fatal error, resetting connection", slog.Any("error", err))
@@ -369,6 +485,97 @@ func (s *stdBatch) Query(args []driver.Value) (driver.Rows, error) {
 
 func (s *stdBatch) Close() error { return nil }
 
+// insertQueryMatch matches INSERT queries, prepared as batches. Leading line
+// and block comments are skipped, as by insertMatch.
+var insertQueryMatch = regexp.MustCompile(`(?i)^\s*(?:(?:--[^\n]*|#![^\n]*|#\s[^\n]*)\n\s*|/\*(?s:.*?)\*/\s*)*INSERT\s`)
+
+// queryParamMatch matches the {name:Type} query parameters of a query.
+var queryParamMatch = regexp.MustCompile(`\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*:[^{}]+\}`)
+
+// stdStmt is a prepared statement other than an INSERT, run by each Exec
+// and Query. Unnamed args are bound to the {name:Type} query parameters of
+// the statement in order of first appearance, the names are parsed once.
+type stdStmt struct {
+	std    *stdDriver
+	query  string
+	params []string
+}
+
+func newStdStmt(std *stdDriver, query string) *stdStmt {
+	s := &stdStmt{
+		std:   std,
+		query: query,
+	}
+	for _, match := range queryParamMatch.FindAllStringSubmatch(query, -1) {
+		if !slices.Contains(s.params, match[1]) {
+			s.params = append(s.params, match[1])
+		}
+	}
+	return s
+}
+
+// NumInput returns -1, query parameters may also be set by WithParameters.
+func (s *stdStmt) NumInput() int { return -1 }
+
+// args names the unnamed args after the query parameters of the statement.
+func (s *stdStmt) args(args []driver.NamedValue) ([]driver.NamedValue, error) {
+	if len(s.params) == 0 {
+		return args, nil
+	}
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		switch arg.Value.(type) {
+		case chdriver.NamedValue, chdriver.NamedDateValue:
+		default:
+			if arg.Name == "" {
+				if i >= len(s.params) {
+					return nil, fmt.Errorf("clickhouse: %d args for the %d query parameters of the statement", len(args), len(s.params))
+				}
+				arg.Name = s.params[i]
+			}
+		}
+		named[i] = arg
+	}
+	return named, nil
+}
+
+func (s *stdStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	args, err := s.args(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.std.ExecContext(ctx, s.query, args)
+}
+
+func (s *stdStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	args, err := s.args(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.std.QueryContext(ctx, s.query, args)
+}
+
+func (s *stdStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stdStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stdStmt) Close() error { return nil }
+
+var _ driver.StmtExecContext = (*stdStmt)(nil)
+var _ driver.StmtQueryContext = (*stdStmt)(nil)
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
 type stdRows struct {
 	rows   *rows
 	logger *slog.Logger
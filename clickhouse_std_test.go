@@ -0,0 +1,116 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	chdriver "github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// txTransport records the statements and their readonly setting.
+type txTransport struct {
+	*mockTransport
+	statements []string
+	readOnly   []any
+	args       [][]any
+}
+
+func (tx *txTransport) exec(ctx context.Context, query string, args ...any) error {
+	tx.statements = append(tx.statements, query)
+	tx.args = append(tx.args, args)
+	tx.readOnly = append(tx.readOnly, queryOptions(ctx).settings["readonly"])
+	return nil
+}
+
+func TestStdTransactions(t *testing.T) {
+	transport := &txTransport{mockTransport: newMockTransport(1)}
+	std := &stdDriver{
+		opt:    &Options{},
+		conn:   transport,
+		logger: slog.New(slog.DiscardHandler),
+	}
+	ctx := context.Background()
+
+	tx, err := std.BeginTx(ctx, driver.TxOptions{})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.Empty(t, transport.statements, "transactions are opt-in")
+
+	std.opt.Transactions = true
+	tx, err = std.BeginTx(ctx, driver.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	_, err = std.ExecContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	_, err = std.ExecContext(ctx, "SELECT 2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN TRANSACTION", "SELECT 1", "COMMIT", "SELECT 2"}, transport.statements)
+	assert.Equal(t, []any{nil, 2, nil, nil}, transport.readOnly)
+
+	transport.statements = nil
+	tx, err = std.BeginTx(ctx, driver.TxOptions{})
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, []string{"BEGIN TRANSACTION", "ROLLBACK"}, transport.statements)
+	assert.False(t, transport.closed)
+
+	tx, err = std.BeginTx(ctx, driver.TxOptions{})
+	require.NoError(t, err)
+	std.commit = func() error { return nil }
+	require.NoError(t, tx.Rollback())
+	assert.True(t, transport.closed, "an open INSERT is rolled back with the connection")
+
+	_, err = std.BeginTx(ctx, driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)})
+	assert.ErrorContains(t, err, "isolation level Serializable is not supported")
+}
+
+func TestStdStmt(t *testing.T) {
+	transport := &txTransport{mockTransport: newMockTransport(1)}
+	std := &stdDriver{
+		opt:    &Options{},
+		conn:   transport,
+		logger: slog.New(slog.DiscardHandler),
+	}
+	ctx := context.Background()
+
+	const query = "ALTER TABLE events DELETE WHERE id = {id:UInt64} AND name != {name:String} OR id = {id:UInt64}"
+	stmt, err := std.PrepareContext(ctx, query)
+	require.NoError(t, err)
+	defer stmt.Close()
+	require.IsType(t, &stdStmt{}, stmt)
+	assert.Equal(t, []string{"id", "name"}, stmt.(*stdStmt).params)
+	assert.Nil(t, std.commit, "not a batch")
+
+	for i := range 2 {
+		_, err = stmt.(driver.StmtExecContext).ExecContext(ctx, []driver.NamedValue{
+			{Ordinal: 1, Value: uint64(i)},
+			{Ordinal: 2, Value: "a"},
+		})
+		require.NoError(t, err)
+	}
+	_, err = stmt.(driver.StmtExecContext).ExecContext(ctx, []driver.NamedValue{
+		{Ordinal: 1, Name: "name", Value: "b"},
+		{Ordinal: 2, Value: chdriver.NamedValue{Name: "id", Value: uint64(3)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{query, query, query}, transport.statements)
+	assert.Equal(t, [][]any{
+		{chdriver.NamedValue{Name: "id", Value: uint64(0)}, chdriver.NamedValue{Name: "name", Value: "a"}},
+		{chdriver.NamedValue{Name: "id", Value: uint64(1)}, chdriver.NamedValue{Name: "name", Value: "a"}},
+		{chdriver.NamedValue{Name: "name", Value: "b"}, chdriver.NamedValue{Name: "id", Value: uint64(3)}},
+	}, transport.args)
+
+	_, err = stmt.Exec([]driver.Value{1, "a", 2})
+	assert.ErrorContains(t, err, "3 args for the 2 query parameters")
+
+	assert.True(t, insertQueryMatch.MatchString("-- comment\n insert into events VALUES"))
+	assert.True(t, insertQueryMatch.MatchString("/* comment\n */ INSERT INTO events VALUES"))
+	assert.True(t, insertQueryMatch.MatchString("/* a */ -- b\n/* c */INSERT INTO events VALUES"))
+	assert.False(t, insertQueryMatch.MatchString("SELECT * FROM events WHERE name = 'INSERT '"))
+}
@@ -85,7 +85,12 @@ func dial(ctx context.Context, addr string, num int, opt *Options) (*connect, er
 		}
 	)
 
-	auth := opt.Auth
+	creds, err := opt.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connect.credentialsRefresh = creds.refresh
+	auth := creds.auth
 	if useJWTAuth(opt) {
 		jwt, err := opt.GetJWT(ctx)
 		if err != nil {
@@ -94,9 +99,15 @@ func dial(ctx context.Context, addr string, num int, opt *Options) (*connect, er
 
 		auth.Username = jwtAuthMarker
 		auth.Password = jwt
+		auth.SSHKey = nil
+	}
+	if auth.SSHKey != nil {
+		// the first protocol version with the SSH challenge
+		connect.revision = proto.DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION
 	}
 
 	if err := connect.handshake(auth); err != nil {
+		opt.credentialsRejected(err)
 		return nil, err
 	}
 
@@ -131,12 +142,18 @@ type connect struct {
 	structMap            *structMap
 	compression          CompressionMethod
 	connectedAt          time.Time
+	credentialsRefresh   time.Time
 	compressor           *compress.Writer
 	readTimeout          time.Duration
 	blockBufferSize      uint8
 	maxCompressionBuffer int
 	readerMutex          sync.Mutex
 	closeMutex           sync.Mutex
+	closeHook            func()
+}
+
+func (c *connect) setCloseHook(hook func()) {
+	c.closeHook = hook
 }
 
 func (c *connect) connID() int {
@@ -151,6 +168,10 @@ func (c *connect) connectedAtTime() time.Time {
 	return c.connectedAt
 }
 
+func (c *connect) credentialsRefreshTime() time.Time {
+	return c.credentialsRefresh
+}
+
 func (c *connect) serverVersion() (*ServerVersion, error) {
 	return &c.server, nil
 }
@@ -193,6 +214,10 @@ func (c *connect) healthCheck() error {
 			errConnMaxLifetimeExceeded, age.Round(time.Millisecond), c.opt.ConnMaxLifetime)
 	}
 
+	if r := c.credentialsRefresh; !r.IsZero() && !time.Now().Before(r) {
+		return errCredentialsExpired
+	}
+
 	if err := c.connCheck(); err != nil {
 		return fmt.Errorf("clickhouse: connection check failed: %w", err)
 	}
@@ -231,6 +256,10 @@ func (c *connect) close() error {
 	c.closed = true
 	c.closeMutex.Unlock()
 
+	if c.closeHook != nil {
+		c.closeHook()
+	}
+
 	if err := c.conn.Close(); err != nil {
 		return err
 	}
@@ -11,6 +11,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
@@ -25,7 +27,19 @@ func (c *connect) prepareBatch(ctx context.Context, release nativeTransportRelea
 		return nil, verr
 	}
 
-	options := queryOptions(ctx)
+	var (
+		options    = queryOptions(ctx)
+		retry      = insertRetryPolicy(ctx, c.opt)
+		dedupToken string
+	)
+	if acquire == nil {
+		// the batch can't send its rows again on another connection
+		retry = nil
+	}
+	dedupToken = deduplicationToken(retry, &options)
+	if dedupToken != "" {
+		options.settings["insert_deduplication_token"] = dedupToken
+	}
 	if deadline, ok := ctx.Deadline(); ok {
 		c.conn.SetDeadline(deadline)
 		defer c.conn.SetDeadline(time.Time{})
@@ -52,6 +66,9 @@ func (c *connect) prepareBatch(ctx context.Context, release nativeTransportRelea
 		release(conn, err)
 	}
 	connAcquire := func(ctx context.Context) (*connect, error) {
+		if acquire == nil {
+			return nil, errors.New("clickhouse: the connection of the batch was released")
+		}
 		conn, err := acquire(ctx)
 		if err != nil {
 			return nil, err
@@ -69,6 +86,10 @@ func (c *connect) prepareBatch(ctx context.Context, release nativeTransportRelea
 		connAcquire:  connAcquire,
 		onProcess:    onProcess,
 		closeOnFlush: opts.CloseOnFlush,
+		retry:        retry,
+		dedupToken:   dedupToken,
+		queryID:      options.queryID,
+		newQueryID:   options.generatedQueryID,
 	}
 
 	if opts.ReleaseConnection {
@@ -86,10 +107,15 @@ type batch struct {
 	sent         bool // sent signalize that batch is send to ClickHouse.
 	released     bool // released signalize that conn was returned to pool and can't be used.
 	closeOnFlush bool // closeOnFlush signalize that batch should close query and release conn when use Flush
+	flushed      bool // flushed signalize that the open INSERT has rows that are no longer buffered.
 	block        *proto.Block
 	connRelease  func(*connect, error)
 	connAcquire  func(context.Context) (*connect, error)
 	onProcess    *onProcess
+	retry        *RetryPolicy
+	dedupToken   string // insert_deduplication_token of the open INSERT, set when retry is.
+	queryID      string // sent with every INSERT of the batch
+	newQueryID   bool   // queryID was generated, a retry of Send generates a new one
 }
 
 func (b *batch) release(err error) {
@@ -99,6 +125,15 @@ func (b *batch) release(err error) {
 	}
 }
 
+func (b *batch) setSendContext(ctx context.Context) nativeTransport {
+	options := queryOptions(ctx)
+	b.ctx, b.onProcess = ctx, options.onProcess()
+	if b.released {
+		return nil
+	}
+	return b.conn
+}
+
 func (b *batch) Abort() error {
 	defer func() {
 		b.sent = true
@@ -166,9 +201,12 @@ func (b *batch) AppendStruct(v any) error {
 	if b.err != nil {
 		return b.err
 	}
-	values, err := b.conn.structMap.Map("AppendStruct", b.block.ColumnsNames(), v, false)
+	if err := b.conn.structMap.omitDefaults("AppendStruct", b.block, v); err != nil {
+		return withQueryIDError(err, b.queryID)
+	}
+	values, err := b.conn.structMap.Map("AppendStruct", b.block.ColumnsNames(), v)
 	if err != nil {
-		return err
+		return withQueryIDError(err, b.queryID)
 	}
 	return b.Append(values...)
 }
@@ -177,6 +215,12 @@ func (b *batch) IsSent() bool {
 	return b.sent
 }
 
+func (b *batch) QueryID() string {
+	return b.queryID
+}
+
+var _ driver.QueryIDProvider = (*batch)(nil)
+
 func (b *batch) Column(idx int) driver.BatchColumn {
 	if len(b.block.Columns) <= idx {
 		err := &OpError{
@@ -213,17 +257,45 @@ func (b *batch) Send() (err error) {
 		stopCW()
 		b.sent = true
 		b.release(err)
+		err = withQueryIDError(err, b.queryID)
 	}()
 	if b.err != nil {
 		return b.err
 	}
+	for retry := 0; ; retry++ {
+		if err = b.send(); err == nil {
+			return nil
+		}
+		// rows flushed on the failed INSERT can't be sent again
+		if b.retry == nil || b.flushed || retry >= b.retry.MaxRetries || !b.retry.retryable(err, true) {
+			return err
+		}
+		b.release(err)
+		if b.newQueryID {
+			// the server may still run the failed INSERT
+			b.queryID = b.conn.opt.newQueryID()
+		}
+		backoff := b.retry.backoff(retry)
+		b.conn.logger.Debug("batch: retrying send after transient error",
+			slog.String("query_id", b.queryID),
+			slog.Int("retry", retry+1),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+		if sleepContext(b.ctx, backoff) != nil {
+			return err
+		}
+	}
+}
+
+// send sends the buffered block and ends the INSERT, on a new connection if the previous one was released.
+func (b *batch) send() error {
 	if b.sent || b.released {
-		if err = b.resetConnection(); err != nil {
+		if err := b.resetConnection(); err != nil {
 			return err
 		}
 	}
 	if b.block.Rows() != 0 {
-		if err = b.conn.sendData(b.block, ""); err != nil {
+		if err := b.conn.sendData(b.block, ""); err != nil {
 			// there might be an error caused by context cancellation
 			// in this case we should return context error instead of net.OpError
 			if ctxErr := b.ctx.Err(); ctxErr != nil {
@@ -233,23 +305,22 @@ func (b *batch) Send() (err error) {
 			return err
 		}
 	}
-	if err = b.closeQuery(); err != nil {
-		return err
-	}
-	return nil
+	return b.closeQuery()
 }
 
-func (b *batch) resetConnection() (err error) {
+func (b *batch) resetConnection() error {
 	// acquire a new conn
-	if b.conn, err = b.connAcquire(b.ctx); err != nil {
+	conn, err := b.connAcquire(b.ctx)
+	if err != nil {
 		return err
 	}
-
-	defer func() {
-		b.released = false
-	}()
+	b.conn, b.released, b.flushed = conn, false, false
 
 	options := queryOptions(b.ctx)
+	options.queryID = b.queryID
+	if b.dedupToken != "" {
+		options.settings["insert_deduplication_token"] = b.dedupToken
+	}
 	if deadline, ok := b.ctx.Deadline(); ok {
 		b.conn.conn.SetDeadline(deadline)
 		defer b.conn.conn.SetDeadline(time.Time{})
@@ -269,6 +340,10 @@ func (b *batch) resetConnection() (err error) {
 }
 
 func (b *batch) Flush() error {
+	return withQueryIDError(b.flush(), b.queryID)
+}
+
+func (b *batch) flush() error {
 	if b.sent {
 		return ErrBatchAlreadySent
 	}
@@ -290,6 +365,12 @@ func (b *batch) Flush() error {
 		}
 		if b.closeOnFlush {
 			b.release(b.closeQuery())
+			// the next INSERT carries other rows, which must not be deduplicated against these
+			if b.dedupToken != "" {
+				b.dedupToken = uuid.NewString()
+			}
+		} else {
+			b.flushed = true
 		}
 	}
 	b.block.Reset()
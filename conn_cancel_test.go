@@ -0,0 +1,144 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// cancelServer answers a ClientCancel with end, nil for no answer.
+func cancelServer(t *testing.T, conn net.Conn, end []byte) {
+	packet := make([]byte, 1)
+	if _, err := conn.Read(packet); err != nil {
+		return
+	}
+	assert.Equal(t, byte(proto.ClientCancel), packet[0])
+	if end != nil {
+		conn.Write(end)
+	}
+}
+
+func newCancelConnect(conn net.Conn, drainTimeout time.Duration) *connect {
+	return &connect{
+		id:          1,
+		conn:        conn,
+		buffer:      new(chproto.Buffer),
+		reader:      chproto.NewReader(conn),
+		connectedAt: time.Now(),
+		readTimeout: 10 * time.Second,
+		logger:      newNoopLogger(),
+		opt:         &Options{CancelDrainTimeout: drainTimeout},
+		revision:    ClientTCPProtocolVersion,
+	}
+}
+
+func TestCancelDrain(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go cancelServer(t, server, []byte{proto.ServerEndOfStream})
+	c := newCancelConnect(client, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := c.process(ctx, &onProcess{})
+	assert.ErrorIs(t, err, context.Canceled)
+	var cancelled *cancelledError
+	assert.True(t, errors.As(err, &cancelled), "the connection is drained")
+	assert.False(t, c.isClosed())
+}
+
+func TestCancelDrainTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go cancelServer(t, server, nil)
+	c := newCancelConnect(client, 20*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := c.process(ctx, &onProcess{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var cancelled *cancelledError
+	assert.False(t, errors.As(err, &cancelled))
+	assert.True(t, c.isClosed(), "a connection that isn't drained is closed")
+}
+
+func TestCancelWithoutDrain(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go cancelServer(t, server, []byte{proto.ServerEndOfStream})
+	c := newCancelConnect(client, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := c.firstBlock(ctx, &onProcess{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, c.isClosed())
+}
+
+func TestReleaseCancelled(t *testing.T) {
+	var mock *mockTransport
+	ch := openSessionConn(t, func(connID int) nativeTransport {
+		mock = newMockTransport(connID)
+		return mock
+	})
+	conn, err := ch.acquire(context.Background())
+	require.NoError(t, err)
+	ch.release(conn, &cancelledError{err: context.Canceled})
+	assert.False(t, mock.closed)
+	assert.Equal(t, 1, ch.Stats().Idle)
+}
+
+func TestKillQueryOnCancel(t *testing.T) {
+	killed := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(string(body), "KILL QUERY") {
+			killed <- string(body)
+			return
+		}
+		// the query runs until it is cancelled
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	h := newTestHTTPConnect(t, srv.URL)
+	h.opt.KillQueryOnCancel = true
+	h.opt.DialTimeout = time.Second
+	ctx, cancel := context.WithTimeout(Context(context.Background(), WithQueryID("query-1")), 20*time.Millisecond)
+	defer cancel()
+	require.Error(t, h.exec(ctx, "SELECT sleep(3)"))
+	select {
+	case query := <-killed:
+		assert.Equal(t, "KILL QUERY WHERE query_id = 'query-1' ASYNC", query)
+	case <-time.After(time.Second):
+		t.Fatal("the query isn't killed")
+	}
+
+	// a finished query isn't killed
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(string(body), "KILL QUERY") {
+			killed <- string(body)
+		}
+	})
+	ctx, cancel = context.WithCancel(context.Background())
+	require.NoError(t, h.exec(ctx, "SELECT 1"))
+	cancel()
+	select {
+	case query := <-killed:
+		t.Fatalf("unexpected %s", query)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
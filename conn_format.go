@@ -1,24 +1,176 @@
 package clickhouse
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
+
+	"github.com/ClickHouse/ch-go/proto"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
 
-// The native protocol server only exchanges Native blocks: producing or
-// parsing any other format would have to happen client-side. Client-side
-// codecs are deliberately out of scope for the experimental release, so both
-// methods return ErrFormatNativeUnsupported; the intended direction for
-// native-protocol support is server-side formatting, not client codecs.
+// The native protocol server only exchanges Native blocks, so over it the
+// client-side formats (see clientFormats) are encoded from the result blocks
+// and parsed into a batch by the driver itself. Every other format returns
+// ErrFormatNativeUnsupported before a connection is acquired.
+
+// formatInsertBlockRows is the number of parsed rows sent as one block by insertFormat.
+const formatInsertBlockRows = 1 << 16
+
+func (c *connect) queryFormat(ctx context.Context, release nativeTransportRelease, formatName string, query string, args ...any) (io.ReadCloser, error) {
+	format, ok := clientFormats[formatName]
+	if !ok {
+		// The connection is healthy and unused - release it back to the pool.
+		release(c, nil)
+		return nil, ErrFormatNativeUnsupported
+	}
+	rows, err := c.query(ctx, release, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	stream := &nativeFormatStream{
+		rows:   rows,
+		format: format,
+		names:  rows.Columns(),
+	}
+	for _, col := range rows.block.Columns {
+		codec, err := newValueCodec(col, rows.block.ServerContext)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stream.codecs = append(stream.codecs, codec)
+	}
+	if format == formatTSVWithNames {
+		writeTSVNames(&stream.buf, stream.names)
+	}
+	return stream, nil
+}
+
+// nativeFormatStream is the io.ReadCloser returned by queryFormat over the
+// native protocol. It encodes one block at a time as the caller reads and
+// holds the connection until the result is exhausted or the stream closed.
+type nativeFormatStream struct {
+	rows   *rows
+	format clientFormat
+	names  []string
+	codecs []*valueCodec
+	buf    bytes.Buffer
+	binary proto.Buffer
+	err    error
+	closed bool
+}
+
+func (s *nativeFormatStream) Read(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("clickhouse: read on closed format stream")
+	}
+	for s.buf.Len() == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.err = s.encodeBlock()
+	}
+	return s.buf.Read(p)
+}
+
+func (s *nativeFormatStream) encodeBlock() error {
+	block, ok := s.rows.NextBlock()
+	if !ok {
+		if err := s.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	var (
+		columns = block.Columns()
+		values  = make([]any, len(columns))
+	)
+	for row := 0; row < block.Rows(); row++ {
+		for i, col := range columns {
+			values[i] = rowValue(col, row)
+		}
+		if s.format != formatRowBinary {
+			if err := writeTextRow(&s.buf, s.format, s.names, s.codecs, values); err != nil {
+				return err
+			}
+			continue
+		}
+		for i, codec := range s.codecs {
+			if err := codec.writeBinary(&s.binary, values[i]); err != nil {
+				return err
+			}
+		}
+	}
+	s.buf.Write(s.binary.Buf)
+	s.binary.Reset()
+	return nil
+}
+
+func (s *nativeFormatStream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.rows.Close()
+}
 
-func (c *connect) queryFormat(_ context.Context, release nativeTransportRelease, _ string, _ string, _ ...any) (io.ReadCloser, error) {
-	// The connection is healthy and unused - release it back to the pool.
-	release(c, nil)
-	return nil, ErrFormatNativeUnsupported
+func (c *connect) insertFormat(ctx context.Context, release nativeTransportRelease, formatName string, query string, data io.Reader) error {
+	format, ok := clientFormats[formatName]
+	if !ok {
+		// The connection is healthy and unused - release it back to the pool.
+		release(c, nil)
+		return ErrFormatNativeUnsupported
+	}
+	insertStmt, _, _, err := extractInsertQueryComponents(query)
+	if err != nil {
+		release(c, nil)
+		return err
+	}
+	// the batch never releases the connection before Send, so it needs no acquire and is not retried
+	prepared, err := c.prepareBatch(ctx, release, nil, insertStmt, driver.PrepareBatchOptions{})
+	if err != nil {
+		return err
+	}
+	b := prepared.(*batch)
+	if err := b.appendFormat(format, data); err != nil {
+		b.Abort()
+		return err
+	}
+	return b.Send()
 }
 
-func (c *connect) insertFormat(_ context.Context, release nativeTransportRelease, _ string, _ string, _ io.Reader) error {
-	// The connection is healthy and unused - release it back to the pool.
-	release(c, nil)
-	return ErrFormatNativeUnsupported
+// appendFormat parses data in a client-side format and appends its rows, flushing full blocks.
+func (b *batch) appendFormat(format clientFormat, data io.Reader) error {
+	codecs := make([]*valueCodec, 0, len(b.block.Columns))
+	for _, col := range b.block.Columns {
+		codec, err := newValueCodec(col, b.block.ServerContext)
+		if err != nil {
+			return err
+		}
+		codecs = append(codecs, codec)
+	}
+	decoder, err := newRowDecoder(format, data, b.block.ColumnsNames(), codecs)
+	if err != nil {
+		return err
+	}
+	for {
+		values, err := decoder.next()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		if err := b.Append(values...); err != nil {
+			return err
+		}
+		if b.block.Rows() >= formatInsertBlockRows {
+			if err := b.Flush(); err != nil {
+				return err
+			}
+		}
+	}
 }
@@ -12,7 +12,7 @@ import (
 func (c *connect) handshake(auth Auth) error {
 	defer c.buffer.Reset()
 	c.logger.Debug("handshake: sending client hello",
-		slog.Int("protocol_version", ClientTCPProtocolVersion),
+		slog.Uint64("protocol_version", c.revision),
 		slog.String("client_name", c.opt.ClientInfo.String()))
 	// set a read deadline - alternative to context.Read operation will fail if no data is received after deadline.
 	c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
@@ -23,20 +23,29 @@ func (c *connect) handshake(auth Auth) error {
 	{
 		c.buffer.PutByte(proto.ClientHello)
 		handshake := &proto.ClientHandshake{
-			ProtocolVersion: ClientTCPProtocolVersion,
+			ProtocolVersion: c.revision,
 			ClientName:      c.opt.ClientInfo.String(),
 			ClientVersion:   proto.Version{ClientVersionMajor, ClientVersionMinor, ClientVersionPatch}, //nolint:govet
 		}
 		handshake.Encode(c.buffer)
 		{
 			c.buffer.PutString(auth.Database)
-			c.buffer.PutString(auth.Username)
+			if auth.SSHKey != nil {
+				c.buffer.PutString(sshKeyAuthMarker + auth.Username)
+			} else {
+				c.buffer.PutString(auth.Username)
+			}
 			c.buffer.PutString(auth.Password)
 		}
 		if err := c.flush(); err != nil {
 			return fmt.Errorf("handshake: failed to send hello to %s (conn_id=%d): %w",
 				c.conn.RemoteAddr(), c.id, err)
 		}
+		if auth.SSHKey != nil {
+			if err := c.sshChallenge(auth); err != nil {
+				return err
+			}
+		}
 	}
 	{
 		packet, err := c.reader.ReadByte()
@@ -48,7 +57,7 @@ func (c *connect) handshake(auth Auth) error {
 		case proto.ServerException:
 			return c.exception()
 		case proto.ServerHello:
-			if err := c.server.Decode(c.reader); err != nil {
+			if err := c.server.DecodeRevision(c.reader, c.revision); err != nil {
 				return fmt.Errorf("handshake: failed to decode server hello from %s (conn_id=%d): %w",
 					c.conn.RemoteAddr(), c.id, err)
 			}
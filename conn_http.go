@@ -24,6 +24,7 @@ import (
 	chproto "github.com/ClickHouse/ch-go/proto"
 	"github.com/andybalholm/brotli"
 
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/timezone"
 )
@@ -109,6 +110,18 @@ func applyOptionsToRequest(ctx context.Context, req *http.Request, opt *Options)
 	jwt := queryOpt.jwt
 	useJWT := jwt != "" || useJWTAuth(opt)
 
+	auth := opt.Auth
+	if !useJWT {
+		creds, err := opt.credentials(ctx)
+		if err != nil {
+			return err
+		}
+		if creds.auth.SSHKey != nil {
+			return errors.New("clickhouse: ssh key authentication requires the native protocol")
+		}
+		auth = creds.auth
+	}
+
 	switch {
 	case opt.TLS != nil && useJWT:
 		if jwt == "" {
@@ -120,20 +133,20 @@ func applyOptionsToRequest(ctx context.Context, req *http.Request, opt *Options)
 		}
 
 		req.Header.Set("Authorization", "Bearer "+jwt)
-	case opt.TLS != nil && len(opt.Auth.Username) > 0:
-		req.Header.Set("X-ClickHouse-User", opt.Auth.Username)
-		if len(opt.Auth.Password) > 0 {
-			req.Header.Set("X-ClickHouse-Key", opt.Auth.Password)
+	case opt.TLS != nil && len(auth.Username) > 0:
+		req.Header.Set("X-ClickHouse-User", auth.Username)
+		if len(auth.Password) > 0 {
+			req.Header.Set("X-ClickHouse-Key", auth.Password)
 			req.Header.Set("X-ClickHouse-SSL-Certificate-Auth", "off")
 		} else {
 			req.Header.Set("X-ClickHouse-SSL-Certificate-Auth", "on")
 		}
-	case opt.TLS == nil && len(opt.Auth.Username) > 0:
-		if len(opt.Auth.Password) > 0 {
-			req.URL.User = url.UserPassword(opt.Auth.Username, opt.Auth.Password)
+	case opt.TLS == nil && len(auth.Username) > 0:
+		if len(auth.Password) > 0 {
+			req.URL.User = url.UserPassword(auth.Username, auth.Password)
 
 		} else {
-			req.URL.User = url.User(opt.Auth.Username)
+			req.URL.User = url.User(auth.Username)
 		}
 	}
 
@@ -152,6 +165,9 @@ func applyOptionsToRequest(ctx context.Context, req *http.Request, opt *Options)
 
 func dialHttp(ctx context.Context, addr string, num int, opt *Options) (*httpConnect, error) {
 	// Get base logger and enrich with connection-specific context
+	if opt.Auth.SSHKey != nil {
+		return nil, errors.New("clickhouse: ssh key authentication requires the native protocol")
+	}
 	baseLogger := opt.logger()
 	logger := prepareConnLogger(baseLogger, num, addr, "http")
 	scheme := opt.scheme
@@ -286,6 +302,13 @@ type httpConnect struct {
 	blockBufferSize uint8
 	handshake       proto.ServerHandshake
 	waitEndOfQuery  bool // connection-level wait_end_of_query from Options.Settings
+	closeHook       func()
+	// session is the server session of every request while pinned to a Session.
+	session *driver.SessionOptions
+}
+
+func (h *httpConnect) setCloseHook(hook func()) {
+	h.closeHook = hook
 }
 
 func (h *httpConnect) serverVersion() (*ServerVersion, error) {
@@ -315,6 +338,11 @@ func (h *httpConnect) setReleased(released bool) {
 func (h *httpConnect) freeBuffer() {
 }
 
+// credentialsRefreshTime is zero, the credentials are those of each request.
+func (h *httpConnect) credentialsRefreshTime() time.Time {
+	return time.Time{}
+}
+
 func (h *httpConnect) healthCheck() error {
 	if h.client == nil {
 		return ErrConnectionClosed
@@ -675,10 +703,17 @@ func (h *httpConnect) createRequest(ctx context.Context, requestUrl string, read
 
 	var query url.Values
 	if options != nil {
+		if span := options.span; span.IsValid() {
+			req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-%s", span.TraceID(), span.SpanID(), span.TraceFlags()))
+			if state := span.TraceState().String(); state != "" {
+				req.Header.Set("tracestate", state)
+			}
+		}
 		query = req.URL.Query()
-		if options.queryID != "" {
-			query.Set(queryIDParamName, options.queryID)
+		if options.queryID == "" {
+			options.queryID = h.opt.newQueryID()
 		}
+		query.Set(queryIDParamName, options.queryID)
 		if options.quotaKey != "" {
 			query.Set(quotaKeyParamName, options.quotaKey)
 		}
@@ -697,6 +732,14 @@ func (h *httpConnect) createRequest(ctx context.Context, requestUrl string, read
 		}
 		req.URL.RawQuery = query.Encode()
 	}
+	if h.session != nil {
+		query = req.URL.Query()
+		query.Set("session_id", h.session.ID)
+		if h.session.Timeout > 0 {
+			query.Set("session_timeout", strconv.Itoa(int(h.session.Timeout.Seconds())))
+		}
+		req.URL.RawQuery = query.Encode()
+	}
 	return req, nil
 }
 
@@ -754,12 +797,15 @@ func (h *httpConnect) executeRequest(req *http.Request) (*http.Response, error)
 	if h.client == nil {
 		return nil, sqldriver.ErrBadConn
 	}
+	stop := h.killQueryOnCancel(req)
 	resp, err := h.client.Do(req)
 	if err != nil {
+		stop()
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		stop()
 		defer discardAndClose(resp.Body)
 		msgBytes, err := h.readRawResponse(resp)
 		if err != nil {
@@ -768,16 +814,77 @@ func (h *httpConnect) executeRequest(req *http.Request) (*http.Response, error)
 			// header can still yield a typed *Exception, with a degraded message.
 			if ex := parseHTTPException("", resp.Header.Get(exceptionCodeHeader), resp.Header.Get(exceptionNameHeader)); ex != nil {
 				ex.Message = fmt.Sprintf("failed to read response body: %v", err)
-				return nil, &HTTPError{StatusCode: resp.StatusCode, Err: ex}
+				err := &HTTPError{StatusCode: resp.StatusCode, Err: ex}
+				h.opt.credentialsRejected(err)
+				return nil, err
 			}
 			return nil, &HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to read response: %w", err)}
 		}
 
-		return nil, newHTTPError(resp.StatusCode, resp.Header, msgBytes)
+		err = newHTTPError(resp.StatusCode, resp.Header, msgBytes)
+		h.opt.credentialsRejected(err)
+		return nil, err
 	}
+	resp.Body = &stopOnClose{ReadCloser: resp.Body, stop: stop}
 	return resp, nil
 }
 
+// killQueryOnCancel kills the query of req with Options.KillQueryOnCancel
+// once its context is done, until the returned stop is called.
+func (h *httpConnect) killQueryOnCancel(req *http.Request) (stop func() bool) {
+	queryID := req.URL.Query().Get(queryIDParamName)
+	if !h.opt.KillQueryOnCancel || queryID == "" {
+		return func() bool { return false }
+	}
+	return context.AfterFunc(req.Context(), func() {
+		if err := h.killQuery(queryID); err != nil {
+			h.logger.Debug("kill cancelled query failed", slog.String("query_id", queryID), slog.Any("error", err))
+		}
+	})
+}
+
+// killQuery kills a query with KILL QUERY, sent on another connection of the
+// client than the one of the query.
+func (h *httpConnect) killQuery(queryID string) error {
+	client := h.client
+	if client == nil {
+		return sqldriver.ErrBadConn
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.opt.DialTimeout)
+	defer cancel()
+	query := killQueryStatement(queryID, driver.KillOptions{Mode: driver.KillAsync})
+	req, err := h.createRequest(ctx, h.url.String(), strings.NewReader(query), nil, nil)
+	if err != nil {
+		return err
+	}
+	// the session, if any, is busy with the query
+	values := req.URL.Query()
+	values.Del("session_id")
+	values.Del("session_timeout")
+	req.URL.RawQuery = values.Encode()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer discardAndClose(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kill query: %s", resp.Status)
+	}
+	h.logger.Debug("killed cancelled query", slog.String("query_id", queryID))
+	return nil
+}
+
+// stopOnClose stops the kill of a query once its response is closed.
+type stopOnClose struct {
+	io.ReadCloser
+	stop func() bool
+}
+
+func (s *stopOnClose) Close() error {
+	s.stop()
+	return s.ReadCloser.Close()
+}
+
 func (h *httpConnect) ping(ctx context.Context) error {
 	ctx = Context(ctx, ignoreExternalTables())
 	// release func is called by connection pool
@@ -800,6 +907,9 @@ func (h *httpConnect) close() error {
 	}
 	h.client.CloseIdleConnections()
 	h.client = nil
+	if h.closeHook != nil {
+		h.closeHook()
+	}
 	return nil
 }
 
@@ -15,7 +15,8 @@ import (
 
 func fetchColumnNamesAndTypesForInsert(h *httpConnect, release nativeTransportRelease, ctx context.Context, tableName string, requestedColumnNames []string) ([]ColumnNameAndType, error) {
 	describeTableQuery := fmt.Sprintf("DESCRIBE TABLE %s", tableName)
-	r, err := h.query(ctx, release, describeTableQuery)
+	// the query ID of the context is the one of the INSERT
+	r, err := h.query(Context(ctx, WithQueryID("")), release, describeTableQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +113,13 @@ func (h *httpConnect) prepareBatch(ctx context.Context, release nativeTransportR
 		return nil, err
 	}
 
+	var (
+		queryID    = queryOptions(ctx).queryID
+		newQueryID = queryOptions(ctx).generatedQueryID
+	)
+	if queryID == "" {
+		queryID, newQueryID = h.opt.newQueryID(), true
+	}
 	return &httpBatch{
 		ctx:         ctx,
 		conn:        h,
@@ -119,6 +127,8 @@ func (h *httpConnect) prepareBatch(ctx context.Context, release nativeTransportR
 		structMap:   &structMap{},
 		block:       block,
 		query:       query,
+		queryID:     queryID,
+		newQueryID:  newQueryID,
 	}, nil
 }
 
@@ -132,6 +142,8 @@ type httpBatch struct {
 	structMap   *structMap
 	sent        bool
 	block       *proto.Block
+	queryID     string
+	newQueryID  bool // queryID was generated, a retry of Send generates a new one
 }
 
 func (b *httpBatch) release(err error) {
@@ -141,6 +153,14 @@ func (b *httpBatch) release(err error) {
 	}
 }
 
+func (b *httpBatch) setSendContext(ctx context.Context) nativeTransport {
+	b.ctx = ctx
+	if b.released {
+		return nil
+	}
+	return b.conn
+}
+
 func (b *httpBatch) Flush() error {
 	// Flush and Send are effectively the same for HTTP, but users should just use Send until we
 	// figure out a way to do proper streaming.
@@ -190,9 +210,12 @@ func (b *httpBatch) AppendStruct(v any) error {
 	if b.err != nil {
 		return b.err
 	}
-	values, err := b.structMap.Map("AppendStruct", b.block.ColumnsNames(), v, false)
+	if err := b.structMap.omitDefaults("AppendStruct", b.block, v); err != nil {
+		return withQueryIDError(err, b.queryID)
+	}
+	values, err := b.structMap.Map("AppendStruct", b.block.ColumnsNames(), v)
 	if err != nil {
-		return err
+		return withQueryIDError(err, b.queryID)
 	}
 	return b.Append(values...)
 }
@@ -219,10 +242,15 @@ func (b *httpBatch) IsSent() bool {
 	return b.sent
 }
 
+func (b *httpBatch) QueryID() string {
+	return b.queryID
+}
+
 func (b *httpBatch) Send() (err error) {
 	defer func() {
 		b.sent = true
 		b.release(err)
+		err = withQueryIDError(err, b.queryID)
 	}()
 	if b.sent {
 		return ErrBatchAlreadySent
@@ -234,7 +262,15 @@ func (b *httpBatch) Send() (err error) {
 		return nil
 	}
 
-	options := queryOptions(b.ctx)
+	var (
+		options    = queryOptions(b.ctx)
+		retry      = insertRetryPolicy(b.ctx, b.conn.opt)
+		dedupToken = deduplicationToken(retry, &options)
+	)
+	options.queryID = b.queryID
+	if dedupToken != "" {
+		options.settings["insert_deduplication_token"] = dedupToken
+	}
 	headers := make(map[string]string)
 	switch b.conn.compression {
 	case CompressionGZIP, CompressionDeflate, CompressionBrotli:
@@ -243,14 +279,54 @@ func (b *httpBatch) Send() (err error) {
 		options.settings["decompress"] = "1"
 		options.settings["compress"] = "1"
 	}
+	options.settings["query"] = b.query
+	headers["Content-Type"] = "application/octet-stream"
 
+	for attempt := 0; ; attempt++ {
+		if err = b.send(&options, headers); err == nil {
+			break
+		}
+		if retry == nil || attempt >= retry.MaxRetries || !retry.retryable(err, true) {
+			return err
+		}
+		if b.newQueryID {
+			// the server may still run the failed INSERT
+			b.queryID = b.conn.opt.newQueryID()
+			options.queryID = b.queryID
+		}
+		backoff := retry.backoff(attempt)
+		b.conn.logger.Debug("batch: retrying send after transient error",
+			slog.String("query_id", b.queryID),
+			slog.Int("retry", attempt+1),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+		if sleepContext(b.ctx, backoff) != nil {
+			return err
+		}
+	}
+
+	b.conn.logger.Debug("batch: send complete")
+	b.block.Reset()
+
+	return nil
+}
+
+// send posts the buffered block as one INSERT request.
+func (b *httpBatch) send(options *QueryOptions, headers map[string]string) error {
 	compressionWriter := b.conn.compressionPool.Get()
 	defer b.conn.compressionPool.Put(compressionWriter)
 	pipeReader, pipeWriter := io.Pipe()
 	connWriter := compressionWriter.reset(pipeWriter)
 
+	// the writer must be done with the shared buffer before a retry resets it
+	written := make(chan struct{})
+	defer func() {
+		pipeReader.Close()
+		<-written
+	}()
 	go func() {
 		var err error
+		defer close(written)
 		defer pipeWriter.CloseWithError(err)
 		defer connWriter.Close()
 		b.conn.buffer.Reset()
@@ -262,13 +338,10 @@ func (b *httpBatch) Send() (err error) {
 		}
 	}()
 
-	options.settings["query"] = b.query
-	headers["Content-Type"] = "application/octet-stream"
-
 	b.conn.logger.Debug("batch: sending via HTTP",
 		slog.Int("columns", len(b.block.Columns)),
 		slog.Int("rows", b.block.Rows()))
-	res, err := b.conn.sendStreamQuery(b.ctx, pipeReader, &options, headers) //nolint:bodyclose // false positive
+	res, err := b.conn.sendStreamQuery(b.ctx, pipeReader, options, headers) //nolint:bodyclose // false positive
 	if err != nil {
 		return fmt.Errorf("batch sendStreamQuery: %w", err)
 	}
@@ -277,10 +350,6 @@ func (b *httpBatch) Send() (err error) {
 	if err := b.conn.insertResponseError(res); err != nil {
 		return fmt.Errorf("batch: %w", err)
 	}
-
-	b.conn.logger.Debug("batch: send complete")
-	b.block.Reset()
-
 	return nil
 }
 
@@ -293,3 +362,4 @@ func (b *httpBatch) Columns() []column.Interface {
 }
 
 var _ driver.Batch = (*httpBatch)(nil)
+var _ driver.QueryIDProvider = (*httpBatch)(nil)
@@ -71,6 +71,7 @@ func (h *httpConnect) query(ctx context.Context, release nativeTransportRelease,
 			block:     block,
 			columns:   block.ColumnsNames(),
 			structMap: &structMap{},
+			queryID:   options.queryID,
 		}, nil
 	}
 
@@ -141,6 +142,7 @@ func (h *httpConnect) query(ctx context.Context, release nativeTransportRelease,
 		errors:    errCh,
 		columns:   block.ColumnsNames(),
 		structMap: &structMap{},
+		queryID:   options.queryID,
 	}, nil
 }
 
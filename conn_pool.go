@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/internal/circular"
@@ -21,6 +22,19 @@ type connPool struct {
 	finished chan struct{}
 
 	maxConnLifetime time.Duration
+
+	metrics *poolMetrics
+}
+
+// poolMetrics counts pool events for Stats.
+type poolMetrics struct {
+	acquired          atomic.Int64
+	waits             atomic.Int64
+	waitDuration      atomic.Int64 // nanoseconds
+	dialErrors        atomic.Int64
+	maxIdleClosed     atomic.Int64
+	maxLifetimeClosed atomic.Int64
+	badConnClosed     atomic.Int64
 }
 
 func newConnPool(lifetime time.Duration, capacity int) *connPool {
@@ -30,6 +44,7 @@ func newConnPool(lifetime time.Duration, capacity int) *connPool {
 		finish:          make(chan struct{}),
 		finished:        make(chan struct{}),
 		maxConnLifetime: lifetime,
+		metrics:         &poolMetrics{},
 	}
 
 	go pool.runDrainPool()
@@ -82,6 +97,7 @@ func (i *connPool) Get(ctx context.Context) (nativeTransport, error) {
 		}
 
 		i.logExpired(conn, "closing expired connection from pool")
+		i.metrics.maxLifetimeClosed.Add(1)
 		conn.close()
 	}
 }
@@ -89,12 +105,14 @@ func (i *connPool) Get(ctx context.Context) (nativeTransport, error) {
 func (i *connPool) Put(conn nativeTransport) {
 	if i.isExpired(conn) {
 		i.logExpired(conn, "connection not returned to pool: lifetime expired")
+		i.metrics.maxLifetimeClosed.Add(1)
 		conn.close()
 		return
 	}
 
 	if err := conn.healthCheck(); err != nil {
 		conn.getLogger().Debug("connection not returned to pool: connection is bad", slog.Any("reason", err))
+		i.metrics.badConnClosed.Add(1)
 		conn.close()
 		return
 	}
@@ -110,6 +128,7 @@ func (i *connPool) Put(conn nativeTransport) {
 	if !i.conns.Push(conn) {
 		// Buffer is full, close the connection
 		conn.getLogger().Debug("connection not returned to pool: pool is full")
+		i.metrics.maxIdleClosed.Add(1)
 		conn.close()
 	}
 }
@@ -177,6 +196,7 @@ func (i *connPool) drainPool() {
 		return i.isExpired(conn)
 	}) {
 		i.logExpired(conn, "closing expired connection from pool")
+		i.metrics.maxLifetimeClosed.Add(1)
 		conn.close()
 	}
 }
@@ -193,5 +213,9 @@ func (i *connPool) logExpired(conn nativeTransport, msg string) {
 }
 
 func (i *connPool) expires(conn nativeTransport) time.Time {
-	return conn.connectedAtTime().Add(i.maxConnLifetime)
+	expires := conn.connectedAtTime().Add(i.maxConnLifetime)
+	if refresh := conn.credentialsRefreshTime(); !refresh.IsZero() && refresh.Before(expires) {
+		return refresh
+	}
+	return expires
 }
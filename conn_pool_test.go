@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
 )
 
 func TestConnPool_Cap(t *testing.T) {
@@ -221,6 +223,22 @@ func TestConnPool_PutExpiredConnection(t *testing.T) {
 	assert.Equal(t, 0, pool.Len())
 }
 
+func TestConnPool_CredentialsRefresh(t *testing.T) {
+	pool := newConnPool(time.Hour, 5)
+	defer pool.Close()
+
+	conn := newMockTransport(1)
+	conn.refreshAt = time.Now().Add(50 * time.Millisecond)
+	pool.Put(conn)
+	assert.Equal(t, 1, pool.Len())
+
+	time.Sleep(100 * time.Millisecond)
+	retrieved, err := pool.Get(context.Background())
+	require.ErrorIs(t, err, errQueueEmpty, "the connection is retired once its credentials are due for refresh")
+	assert.Nil(t, retrieved)
+	assert.True(t, conn.isClosed())
+}
+
 func TestConnPool_EvictionLogsReason(t *testing.T) {
 	newBufLogger := func() (*bytes.Buffer, *slog.Logger) {
 		var buf bytes.Buffer
@@ -529,9 +547,62 @@ func TestConnPool_FIFOOrdering(t *testing.T) {
 	}
 }
 
+func TestStatsPoolMetrics(t *testing.T) {
+	var failDial atomic.Bool
+	conn, err := Open(&Options{
+		MaxOpenConns: 2,
+		MaxIdleConns: 1,
+		DialTimeout:  time.Second,
+		DialStrategy: func(ctx context.Context, connID int, opt *Options, dial Dial) (DialResult, error) {
+			if failDial.Load() {
+				return DialResult{}, errors.New("connection refused")
+			}
+			return DialResult{conn: newMockTransport(connID)}, nil
+		},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	ch := conn.(*clickhouse)
+	ctx := context.Background()
+
+	c1, err := ch.acquire(ctx)
+	require.NoError(t, err)
+	c2, err := ch.acquire(ctx)
+	require.NoError(t, err)
+
+	// MaxOpenConns is reached, the third acquisition waits for c1
+	acquired := make(chan nativeTransport)
+	go func() {
+		c3, err := ch.acquire(ctx)
+		assert.NoError(t, err)
+		acquired <- c3
+	}()
+	time.Sleep(20 * time.Millisecond)
+	ch.release(c1, nil)
+	c3 := <-acquired
+	assert.Same(t, c1, c3, "the released connection is reused")
+
+	ch.release(c2, nil)
+	ch.release(c3, errors.New("broken"))
+	failDial.Store(true)
+	_, err = ch.acquire(ctx) // the idle c2
+	require.NoError(t, err)
+	_, err = ch.acquire(ctx)
+	require.Error(t, err)
+
+	stats := conn.Stats()
+	assert.Equal(t, int64(4), stats.AcquireCount)
+	assert.Equal(t, int64(1), stats.WaitCount)
+	assert.GreaterOrEqual(t, stats.WaitDuration, 10*time.Millisecond)
+	assert.Equal(t, int64(1), stats.DialErrors)
+	assert.Equal(t, int64(1), stats.BadConnClosed)
+	assert.Equal(t, 1, stats.Open)
+}
+
 // mockTransport implements nativeTransport for testing
 type mockTransport struct {
 	connectedAt   time.Time
+	refreshAt     time.Time
 	id            int
 	released      bool
 	closed        bool
@@ -580,6 +651,10 @@ func (m *mockTransport) ping(ctx context.Context) error {
 	return nil
 }
 
+func (m *mockTransport) tablesStatus(ctx context.Context, tables []proto.QualifiedTableName) (map[proto.QualifiedTableName]proto.TableStatus, error) {
+	return nil, nil
+}
+
 func (m *mockTransport) healthCheck() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -597,6 +672,10 @@ func (m *mockTransport) connectedAtTime() time.Time {
 	return m.connectedAt
 }
 
+func (m *mockTransport) credentialsRefreshTime() time.Time {
+	return m.refreshAt
+}
+
 func (m *mockTransport) isReleased() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
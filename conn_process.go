@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"sync/atomic"
+	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
 )
@@ -22,8 +24,7 @@ func (c *connect) firstBlock(ctx context.Context, on *onProcess) (*proto.Block,
 	// if context is already timedout/cancelled — we're done
 	select {
 	case <-ctx.Done():
-		c.cancel()
-		return nil, ctx.Err()
+		return nil, c.cancel(ctx, nil)
 	default:
 	}
 
@@ -31,6 +32,8 @@ func (c *connect) firstBlock(ctx context.Context, on *onProcess) (*proto.Block,
 	resultCh := make(chan *proto.Block, 1)
 	errCh := make(chan error, 1)
 
+	var cancelled atomic.Bool
+	on = on.unlessCancelled(&cancelled)
 	go func() {
 		block, err := c.firstBlockImpl(ctx, on)
 		if err != nil {
@@ -43,8 +46,16 @@ func (c *connect) firstBlock(ctx context.Context, on *onProcess) (*proto.Block,
 	// select on context or read channels (results/errors)
 	select {
 	case <-ctx.Done():
-		c.cancel()
-		return nil, ctx.Err()
+		cancelled.Store(true)
+		return nil, c.cancel(ctx, func() error {
+			select {
+			case err := <-errCh:
+				return err
+			case <-resultCh:
+				// the rest of the stream
+				return c.processImpl(ctx, on)
+			}
+		})
 
 	case err := <-errCh:
 		return nil, err
@@ -58,8 +69,9 @@ func (c *connect) firstBlockImpl(ctx context.Context, on *onProcess) (*proto.Blo
 	c.readerMutex.Lock()
 	defer c.readerMutex.Unlock()
 
-	c.startReadWriteTimeout(ctx)
-	defer c.clearReadWriteTimeout(ctx)
+	timeoutCtx := c.readTimeoutContext(ctx)
+	c.startReadWriteTimeout(timeoutCtx)
+	defer c.clearReadWriteTimeout(timeoutCtx)
 
 	for {
 		if c.reader == nil {
@@ -95,8 +107,7 @@ func (c *connect) process(ctx context.Context, on *onProcess) error {
 	// if context is already timedout/cancelled — we're done
 	select {
 	case <-ctx.Done():
-		c.cancel()
-		return ctx.Err()
+		return c.cancel(ctx, nil)
 	default:
 	}
 
@@ -104,6 +115,8 @@ func (c *connect) process(ctx context.Context, on *onProcess) error {
 	errCh := make(chan error, 1)
 	doneCh := make(chan bool, 1)
 
+	var cancelled atomic.Bool
+	on = on.unlessCancelled(&cancelled)
 	go func() {
 		err := c.processImpl(ctx, on)
 		if err != nil {
@@ -117,8 +130,15 @@ func (c *connect) process(ctx context.Context, on *onProcess) error {
 	// select on context or read channel (errors)
 	select {
 	case <-ctx.Done():
-		c.cancel()
-		return ctx.Err()
+		cancelled.Store(true)
+		return c.cancel(ctx, func() error {
+			select {
+			case err := <-errCh:
+				return err
+			case <-doneCh:
+				return nil
+			}
+		})
 
 	case err := <-errCh:
 		return err
@@ -132,8 +152,9 @@ func (c *connect) processImpl(ctx context.Context, on *onProcess) error {
 	c.readerMutex.Lock()
 	defer c.readerMutex.Unlock()
 
-	c.startReadWriteTimeout(ctx)
-	defer c.clearReadWriteTimeout(ctx)
+	timeoutCtx := c.readTimeoutContext(ctx)
+	c.startReadWriteTimeout(timeoutCtx)
+	defer c.clearReadWriteTimeout(timeoutCtx)
 
 	for {
 		if c.reader == nil {
@@ -211,6 +232,13 @@ func (c *connect) handle(ctx context.Context, packet byte, on *onProcess) error
 		}
 		// Progress is already logged in c.progress()
 		on.progress(progress)
+	case proto.ServerTimezoneUpdate:
+		// session_timezone set by the query, sent from revision 54464
+		tz, err := c.reader.Str()
+		if err != nil {
+			return err
+		}
+		c.logger.Debug("server timezone update", slog.String("timezone", tz))
 	default:
 		return &OpError{
 			Op:  "process",
@@ -220,13 +248,89 @@ func (c *connect) handle(ctx context.Context, packet byte, on *onProcess) error
 	return nil
 }
 
-func (c *connect) cancel() error {
+// cancel cancels the running query of ctx with ClientCancel. With
+// Options.CancelDrainTimeout, drain waits for the end of its stream, after
+// which the connection can be reused; otherwise the connection is closed.
+func (c *connect) cancel(ctx context.Context, drain func() error) error {
 	c.logger.Debug("cancelling query")
+	err := ctx.Err()
 	c.buffer.PutUVarInt(proto.ClientCancel)
 	wErr := c.flush()
-	// don't reuse a cancelled query as we don't drain the connection
+	if timeout := c.cancelDrainTimeout(); timeout > 0 && wErr == nil && drain != nil {
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+		drained := make(chan error, 1)
+		go func() {
+			drained <- drain()
+		}()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case dErr := <-drained:
+			var exception *Exception
+			if dErr == nil || errors.Is(dErr, io.EOF) || errors.As(dErr, &exception) {
+				c.conn.SetReadDeadline(time.Time{})
+				c.logger.Debug("cancelled query drained")
+				return &cancelledError{err: err}
+			}
+			c.logger.Debug("cancelled query drain failed", slog.Any("error", dErr))
+		case <-timer.C:
+			c.logger.Debug("cancelled query drain timed out")
+		}
+	}
+	// don't reuse a cancelled query as the connection isn't drained
 	if cErr := c.close(); cErr != nil {
 		return cErr
 	}
-	return wErr
+	if wErr != nil {
+		return wErr
+	}
+	return err
+}
+
+// cancelDrainTimeout is Options.CancelDrainTimeout, 0 without options.
+func (c *connect) cancelDrainTimeout() time.Duration {
+	if c.opt == nil {
+		return 0
+	}
+	return c.opt.CancelDrainTimeout
+}
+
+// readTimeoutContext returns the context of the read timeouts of a query.
+// When cancelled queries are drained, its deadline cancels the query instead
+// of interrupting a read.
+func (c *connect) readTimeoutContext(ctx context.Context) context.Context {
+	if c.cancelDrainTimeout() > 0 {
+		return context.WithoutCancel(ctx)
+	}
+	return ctx
+}
+
+// cancelledError is the error of a query cancelled by its context once its
+// connection is drained, which keeps the connection reusable.
+type cancelledError struct {
+	err error
+}
+
+func (e *cancelledError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cancelledError) Unwrap() error {
+	return e.err
+}
+
+// unlessCancelled returns the callbacks of on, which skip the data of a
+// cancelled query.
+func (on *onProcess) unlessCancelled(cancelled *atomic.Bool) *onProcess {
+	data := on.data
+	if data == nil {
+		return on
+	}
+	wrapped := *on
+	wrapped.data = func(block *proto.Block) {
+		if !cancelled.Load() {
+			data(block)
+		}
+	}
+	return &wrapped
 }
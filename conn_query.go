@@ -45,7 +45,11 @@ func (c *connect) query(ctx context.Context, release nativeTransportRelease, que
 
 	go func() {
 		onProcess.data = func(b *proto.Block) {
-			stream <- b
+			select {
+			case stream <- b:
+			case <-ctx.Done():
+				// not read anymore, the query is cancelled
+			}
 		}
 		err := c.process(ctx, onProcess)
 		if err != nil {
@@ -63,6 +67,7 @@ func (c *connect) query(ctx context.Context, release nativeTransportRelease, que
 		errors:    errors,
 		columns:   init.ColumnsNames(),
 		structMap: c.structMap,
+		queryID:   options.queryID,
 	}, nil
 }
 
@@ -9,9 +9,13 @@ import (
 // Connection::sendQuery
 // https://github.com/ClickHouse/ClickHouse/blob/master/src/Client/Connection.cpp
 func (c *connect) sendQuery(body string, o *QueryOptions) error {
+	if o.queryID == "" {
+		o.queryID = c.opt.newQueryID()
+	}
 	c.logger.Debug("sending query",
 		slog.String("compression", c.compression.String()),
-		slog.String("query", body))
+		slog.String("query", body),
+		slog.String("query_id", o.queryID))
 	c.buffer.PutByte(proto.ClientQuery)
 	q := proto.Query{
 		ClientTCPProtocolVersion: ClientTCPProtocolVersion,
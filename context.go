@@ -39,13 +39,20 @@ type (
 		ok   bool
 		wait bool
 	}
+	retryOptions struct {
+		override   bool
+		policy     *RetryPolicy
+		idempotent bool
+	}
 	QueryOptions struct {
-		span     trace.SpanContext
-		async    AsyncOptions
-		queryID  string
-		quotaKey string
-		jwt      string
-		events   struct {
+		span             trace.SpanContext
+		async            AsyncOptions
+		retry            retryOptions
+		queryID          string
+		generatedQueryID bool // queryID was generated by the driver, retries generate a new one
+		quotaKey         string
+		jwt              string
+		events           struct {
 			logs          func(*Log)
 			progress      func(*Progress)
 			profileInfo   func(*ProfileInfo)
@@ -58,6 +65,7 @@ type (
 		userLocation        *time.Location
 		columnNamesAndTypes []ColumnNameAndType
 		clientInfo          ClientInfo
+		replicaTables       []string
 	}
 )
 
@@ -70,7 +78,7 @@ func WithSpan(span trace.SpanContext) QueryOption {
 
 func WithQueryID(queryID string) QueryOption {
 	return func(o *QueryOptions) error {
-		o.queryID = queryID
+		o.queryID, o.generatedQueryID = queryID, false
 		return nil
 	}
 }
@@ -183,6 +191,26 @@ func WithStdAsync(wait bool) QueryOption {
 	}
 }
 
+// WithRetryPolicy overrides Options.RetryPolicy for the query, nil disables retries.
+func WithRetryPolicy(policy *RetryPolicy) QueryOption {
+	return func(o *QueryOptions) error {
+		o.retry.override, o.retry.policy = true, nil
+		if policy != nil {
+			o.retry.policy = policy.setDefaults()
+		}
+		return nil
+	}
+}
+
+// WithIdempotent marks an Exec statement as safe to repeat, so the RetryPolicy
+// also retries it after failures that may have happened once it reached the server.
+func WithIdempotent() QueryOption {
+	return func(o *QueryOptions) error {
+		o.retry.idempotent = true
+		return nil
+	}
+}
+
 func WithUserLocation(location *time.Location) QueryOption {
 	return func(o *QueryOptions) error {
 		o.userLocation = location
@@ -220,6 +248,7 @@ func Context(parent context.Context, options ...QueryOption) context.Context {
 // queryOptions returns a mutable copy of the QueryOptions struct within the given context.
 // If ClickHouse context was not provided, an empty struct with a valid Settings map is returned.
 // If the context has a deadline greater than 1s then max_execution_time setting is appended.
+// Without WithSpan the span context of the active span in ctx is used.
 func queryOptions(ctx context.Context) QueryOptions {
 	var opt QueryOptions
 
@@ -230,6 +259,10 @@ func queryOptions(ctx context.Context) QueryOptions {
 			settings: make(Settings),
 		}
 	}
+	if !opt.span.IsValid() {
+		// propagate the active span, such as the client span of Options.TracerProvider
+		opt.span = trace.SpanContextFromContext(ctx)
+	}
 
 	deadline, ok := ctx.Deadline()
 	if !ok {
@@ -310,7 +343,9 @@ func (q *QueryOptions) clone() QueryOptions {
 	c := QueryOptions{
 		span:                q.span,
 		async:               q.async,
+		retry:               q.retry,
 		queryID:             q.queryID,
+		generatedQueryID:    q.generatedQueryID,
 		quotaKey:            q.quotaKey,
 		jwt:                 q.jwt,
 		events:              q.events,
@@ -320,6 +355,7 @@ func (q *QueryOptions) clone() QueryOptions {
 		blockBufferSize:     q.blockBufferSize,
 		userLocation:        q.userLocation,
 		columnNamesAndTypes: nil,
+		replicaTables:       q.replicaTables,
 	}
 
 	if q.settings != nil {
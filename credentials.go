@@ -0,0 +1,177 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CredentialsProviderFunc returns the credentials of new connections and HTTP
+// requests and the time they expire, zero if they do not.
+type CredentialsProviderFunc = func(ctx context.Context) (Auth, time.Time, error)
+
+// credentialsRefreshMargin is how long before their expiry credentials are
+// refreshed and the connections dialed with them retired.
+const credentialsRefreshMargin = time.Minute
+
+type credentials struct {
+	auth    Auth
+	expiry  time.Time
+	refresh time.Time // zero when expiry is
+}
+
+// valid reports whether the credentials are not expired at now.
+func (c *credentials) valid(now time.Time) bool {
+	return c.expiry.IsZero() || now.Before(c.expiry)
+}
+
+// credentialsCache holds the credentials of a CredentialsProviderFunc until
+// they are due for refresh, credentials without expiry until the server
+// rejects them. Concurrent uses share a single call to the provider, which
+// runs without holding the cache.
+type credentialsCache struct {
+	provider CredentialsProviderFunc
+	timeout  time.Duration // bound of a provider call, none if zero
+
+	mu      sync.Mutex
+	cached  *credentials
+	pending *credentialsCall // the running provider call, if any
+}
+
+// credentialsCall is a call to the provider, its result is set once done is closed.
+type credentialsCall struct {
+	done  chan struct{}
+	creds credentials
+	err   error
+}
+
+func (c *credentialsCache) get(ctx context.Context) (credentials, error) {
+	c.mu.Lock()
+	now := time.Now()
+	if c.cached != nil && (c.cached.refresh.IsZero() || now.Before(c.cached.refresh)) {
+		defer c.mu.Unlock()
+		return *c.cached, nil
+	}
+	call := c.pending
+	if call == nil {
+		call = &credentialsCall{done: make(chan struct{})}
+		c.pending = call
+		// the call is shared, the cancellation of the use starting it must
+		// not fail the others
+		go c.provide(context.WithoutCancel(ctx), call, now)
+	}
+	c.mu.Unlock()
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return credentials{}, context.Cause(ctx)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.result(call, now)
+}
+
+// provide runs call, bounded by the timeout of the cache.
+func (c *credentialsCache) provide(ctx context.Context, call *credentialsCall, now time.Time) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	call.creds, call.err = provideCredentials(ctx, c.provider, now)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = nil
+	if call.err == nil {
+		c.cached = &call.creds
+	}
+	close(call.done)
+}
+
+// result returns the credentials of call. c.mu is held.
+func (c *credentialsCache) result(call *credentialsCall, now time.Time) (credentials, error) {
+	switch {
+	case call.err != nil && c.cached != nil && c.cached.valid(now):
+		// refreshed early enough to retry, keep using the current ones
+		return *c.cached, nil
+	case call.err != nil:
+		return credentials{}, call.err
+	}
+	return call.creds, nil
+}
+
+// invalidate drops the cached credentials, the next use calls the provider.
+func (c *credentialsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cached = nil
+}
+
+func provideCredentials(ctx context.Context, provider CredentialsProviderFunc, now time.Time) (credentials, error) {
+	auth, expiry, err := provider(ctx)
+	if err != nil {
+		return credentials{}, fmt.Errorf("clickhouse: credentials provider: %w", err)
+	}
+	creds := credentials{
+		auth:   auth,
+		expiry: expiry,
+	}
+	if !creds.valid(now) {
+		return credentials{}, fmt.Errorf("clickhouse: credentials provider: credentials expired at %s", expiry)
+	}
+	if !expiry.IsZero() {
+		// short lived credentials are refreshed halfway through
+		creds.refresh = expiry.Add(-min(credentialsRefreshMargin, expiry.Sub(now)/2))
+	}
+	return creds, nil
+}
+
+// credentialsRejected drops the cached credentials of Options.CredentialsProvider
+// when err is the server rejecting them, they may have been rotated.
+func (o *Options) credentialsRejected(err error) {
+	if o.credentialsCache != nil && authenticationFailed(err) {
+		o.credentialsCache.invalidate()
+	}
+}
+
+// authenticationFailed reports whether err is the server rejecting the user
+// or the password of a connection or request.
+func authenticationFailed(err error) bool {
+	var exception *Exception
+	if errors.As(err, &exception) {
+		switch exception.Code {
+		case 192, 193, 194, 516: // UNKNOWN_USER, WRONG_PASSWORD, REQUIRED_PASSWORD, AUTHENTICATION_FAILED
+			return true
+		}
+	}
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized
+}
+
+// credentials returns the credentials of a new connection or HTTP request,
+// those of Options.CredentialsProvider if set, otherwise Options.Auth.
+func (o *Options) credentials(ctx context.Context) (credentials, error) {
+	if o.CredentialsProvider == nil {
+		return credentials{auth: o.Auth}, nil
+	}
+	var (
+		creds credentials
+		err   error
+	)
+	if o.credentialsCache != nil {
+		creds, err = o.credentialsCache.get(ctx)
+	} else {
+		creds, err = provideCredentials(ctx, o.CredentialsProvider, time.Now())
+	}
+	if err != nil {
+		return credentials{}, err
+	}
+	creds.auth.Database = o.Auth.Database
+	if creds.auth.Username == "" {
+		creds.auth.Username = "default"
+	}
+	return creds, nil
+}
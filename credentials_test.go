@@ -0,0 +1,262 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// countingProvider returns the credentials of its calls with expiry, failing
+// once err is set.
+type countingProvider struct {
+	calls  int
+	expiry time.Duration
+	err    error
+}
+
+func (p *countingProvider) provide(context.Context) (Auth, time.Time, error) {
+	p.calls++
+	if p.err != nil {
+		return Auth{}, time.Time{}, p.err
+	}
+	var expiry time.Time
+	if p.expiry != 0 {
+		expiry = time.Now().Add(p.expiry)
+	}
+	return Auth{Username: "svc", Password: "secret"}, expiry, nil
+}
+
+func TestCredentialsCache(t *testing.T) {
+	ctx := context.Background()
+
+	provider := &countingProvider{expiry: 10 * time.Minute}
+	cache := &credentialsCache{provider: provider.provide}
+	creds, err := cache.get(ctx)
+	require.NoError(t, err)
+	_, err = cache.get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.calls, "the credentials are held until they are due for refresh")
+	assert.Equal(t, credentialsRefreshMargin, creds.expiry.Sub(creds.refresh))
+
+	provider = &countingProvider{expiry: 2 * time.Second}
+	creds, err = provideCredentials(ctx, provider.provide, time.Now())
+	require.NoError(t, err)
+	assert.InDelta(t, time.Second, creds.expiry.Sub(creds.refresh), float64(100*time.Millisecond), "short lived credentials are refreshed halfway")
+
+	provider = &countingProvider{}
+	cache = &credentialsCache{provider: provider.provide}
+	for range 2 {
+		creds, err = cache.get(ctx)
+		require.NoError(t, err)
+		assert.True(t, creds.refresh.IsZero())
+	}
+	assert.Equal(t, 1, provider.calls, "credentials without expiry are held")
+	opt := &Options{credentialsCache: cache}
+	opt.credentialsRejected(&Exception{Code: 62})
+	_, err = cache.get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.calls)
+	opt.credentialsRejected(&Exception{Code: 516, CodeName: "AUTHENTICATION_FAILED"})
+	_, err = cache.get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.calls, "credentials the server rejects are requested again")
+
+	provider = &countingProvider{expiry: 10 * time.Minute}
+	cache = &credentialsCache{provider: provider.provide}
+	_, err = cache.get(ctx)
+	require.NoError(t, err)
+	cache.cached.refresh = time.Now()
+	provider.err = errors.New("vault sealed")
+	creds, err = cache.get(ctx)
+	require.NoError(t, err, "the current credentials are used until they expire")
+	assert.Equal(t, "svc", creds.auth.Username)
+	cache.cached.expiry = time.Now()
+	_, err = cache.get(ctx)
+	assert.ErrorContains(t, err, "vault sealed")
+}
+
+func TestCredentialsCacheSingleFlight(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		release = make(chan struct{})
+		cache   = &credentialsCache{provider: func(context.Context) (Auth, time.Time, error) {
+			calls.Add(1)
+			<-release
+			return Auth{Username: "svc"}, time.Time{}, nil
+		}}
+		wg sync.WaitGroup
+	)
+	for range 4 {
+		wg.Go(func() {
+			creds, err := cache.get(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "svc", creds.auth.Username)
+		})
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// the provider runs without holding the cache
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cache.get(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "a waiting use returns once its context is done")
+	cache.invalidate()
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load(), "concurrent uses share one call of the provider")
+}
+
+func TestCredentialsCacheLeaderCanceled(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		cache   = &credentialsCache{provider: func(ctx context.Context) (Auth, time.Time, error) {
+			close(started)
+			select {
+			case <-release:
+				return Auth{Username: "svc"}, time.Time{}, nil
+			case <-ctx.Done():
+				return Auth{}, time.Time{}, ctx.Err()
+			}
+		}}
+		ctx, cancel = context.WithCancel(context.Background())
+		leader      = make(chan error, 1)
+	)
+	go func() {
+		_, err := cache.get(ctx)
+		leader <- err
+	}()
+	<-started
+	waiter := make(chan error, 1)
+	go func() {
+		creds, err := cache.get(context.Background())
+		assert.Equal(t, "svc", creds.auth.Username)
+		waiter <- err
+	}()
+	cancel()
+	assert.ErrorIs(t, <-leader, context.Canceled)
+	close(release)
+	assert.NoError(t, <-waiter, "the cancellation of the leader does not fail the waiters")
+
+	cache = &credentialsCache{
+		provider: func(ctx context.Context) (Auth, time.Time, error) {
+			<-ctx.Done()
+			return Auth{}, time.Time{}, ctx.Err()
+		},
+		timeout: time.Millisecond,
+	}
+	_, err := cache.get(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the provider call is bounded by the timeout")
+}
+
+func TestOptionsCredentials(t *testing.T) {
+	ctx := context.Background()
+	opt := Options{
+		Auth: Auth{Database: "db", Username: "static"},
+		CredentialsProvider: func(context.Context) (Auth, time.Time, error) {
+			return Auth{Database: "other"}, time.Time{}, nil
+		},
+	}
+	creds, err := opt.setDefaults().credentials(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Auth{Database: "db", Username: "default"}, creds.auth, "the database of Auth is kept")
+
+	opt.CredentialsProvider = func(context.Context) (Auth, time.Time, error) {
+		return Auth{Username: "svc"}, time.Now().Add(-time.Second), nil
+	}
+	_, err = opt.setDefaults().credentials(ctx)
+	assert.ErrorContains(t, err, "expired")
+}
+
+// helloServer answers a client hello on conn and sends the username and
+// password of the hello.
+func helloServer(conn net.Conn) <-chan Auth {
+	hello := make(chan Auth, 1)
+	go func() {
+		defer conn.Close()
+		var (
+			reader = chproto.NewReader(conn)
+			buffer = new(chproto.Buffer)
+			auth   Auth
+		)
+		_, _ = reader.ReadByte()
+		_, _ = reader.Str() // client name
+		_, _ = reader.UVarInt()
+		_, _ = reader.UVarInt()
+		_, _ = reader.UVarInt()
+		auth.Database, _ = reader.Str()
+		auth.Username, _ = reader.Str()
+		auth.Password, _ = reader.Str()
+		hello <- auth
+
+		buffer.PutByte(proto.ServerHello)
+		buffer.PutString("ClickHouse")
+		buffer.PutUVarInt(25)
+		buffer.PutUVarInt(8)
+		buffer.PutUVarInt(proto.DBMS_TCP_PROTOCOL_VERSION)
+		buffer.PutString("UTC")
+		buffer.PutString("server")
+		buffer.PutUVarInt(1)
+		conn.Write(buffer.Buf)
+		_, _ = reader.Str() // addendum quota key
+	}()
+	return hello
+}
+
+func TestDialCredentialsProvider(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	client, server := net.Pipe()
+	hello := helloServer(server)
+	opt := (&Options{
+		Auth:        Auth{Database: "db", Password: "static"},
+		DialContext: func(context.Context, string) (net.Conn, error) { return client, nil },
+		CredentialsProvider: func(context.Context) (Auth, time.Time, error) {
+			return Auth{Username: "svc", Password: "rotated"}, expiry, nil
+		},
+	}).setDefaults()
+
+	conn, err := dial(context.Background(), "127.0.0.1:9000", 1, opt)
+	require.NoError(t, err)
+	defer conn.close()
+	assert.Equal(t, Auth{Database: "db", Username: "svc", Password: "rotated"}, <-hello)
+	assert.Equal(t, expiry.Add(-credentialsRefreshMargin), conn.credentialsRefreshTime())
+
+	conn.credentialsRefresh = time.Now()
+	assert.ErrorIs(t, conn.healthCheck(), errCredentialsExpired)
+}
+
+func TestHTTPCredentialsProvider(t *testing.T) {
+	var password string
+	opt := (&Options{
+		Auth: Auth{Username: "static"},
+		CredentialsProvider: func(context.Context) (Auth, time.Time, error) {
+			return Auth{Username: "svc", Password: password}, time.Time{}, nil
+		},
+	}).setDefaults()
+	request := func() string {
+		req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8123", nil)
+		require.NoError(t, err)
+		require.NoError(t, applyOptionsToRequest(context.Background(), req, opt))
+		assert.Equal(t, "svc", req.URL.User.Username())
+		got, _ := req.URL.User.Password()
+		return got
+	}
+	password = "first"
+	assert.Equal(t, "first", request())
+	password = "rotated"
+	assert.Equal(t, "first", request(), "the credentials are held")
+	opt.credentialsRejected(&HTTPError{StatusCode: http.StatusUnauthorized, Err: errors.New("unauthorized")})
+	assert.Equal(t, "rotated", request(), "the credentials are requested again once rejected")
+}
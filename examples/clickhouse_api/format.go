@@ -114,7 +114,7 @@ func FormatJSONEachRow() error {
 // FormatParquet exports a query result to a .parquet file and imports a
 // .parquet file into a table - the two halves of a typical data-lake
 // exchange. The server produces and parses the Parquet bytes; the client
-// streams them. QueryFormat/InsertFormat require the HTTP protocol.
+// streams them. Parquet, unlike CSV or JSONEachRow, requires the HTTP protocol.
 func FormatParquet() error {
 	conn, err := GetHTTPConnection("format-parquet", nil, nil, nil)
 	if err != nil {
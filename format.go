@@ -52,8 +52,9 @@ func hasTrailingFormatClause(query string) bool {
 // different encoding than asked for.
 //
 // Experimental: this API is experimental and may change or be removed in a
-// future minor release. It is currently only supported over the HTTP
-// protocol; over the native protocol it returns ErrFormatNativeUnsupported.
+// future minor release. Over the native protocol only the client-side formats
+// (CSV, TSV, TSVWithNames, JSONEachRow, JSONCompactEachRow and RowBinary) are
+// supported, any other format returns ErrFormatNativeUnsupported.
 func (ch *clickhouse) QueryFormat(ctx context.Context, format string, query string, args ...any) (io.ReadCloser, error) {
 	if err := validateFormatName(format); err != nil {
 		return nil, err
@@ -63,15 +64,25 @@ func (ch *clickhouse) QueryFormat(ctx context.Context, format string, query stri
 	}
 	// Checked before acquiring: a saturated pool or failed dial must not mask
 	// the actionable "use HTTP" error behind ErrAcquireConnTimeout.
-	if ch.opt.Protocol != HTTP {
+	if _, ok := clientFormats[format]; !ok && ch.opt.Protocol != HTTP {
 		return nil, ErrFormatNativeUnsupported
 	}
+	ctx, queryID := ch.withQueryID(ctx)
+	ctx, span := ch.startSpan(ctx, "clickhouse.query_format", query)
 	conn, err := ch.acquire(ctx)
 	if err != nil {
+		span.end(err)
 		return nil, err
 	}
-	conn.getLogger().Debug("executing format query", slog.String("sql", query), slog.String("format", format))
-	return conn.queryFormat(ctx, ch.release, format, query, args...)
+	conn.getLogger().Debug("executing format query", slog.String("sql", query), slog.String("format", format), slog.String("query_id", queryID))
+	span.connected(ch, conn)
+	stream, err := conn.queryFormat(ctx, ch.trackQuery(ctx, span.release(ch.release)), format, query, args...)
+	if err != nil {
+		span.end(err)
+		return nil, withQueryIDError(err, queryID)
+	}
+	span.done()
+	return stream, nil
 }
 
 // InsertFormat executes the INSERT statement query, streaming data
@@ -79,13 +90,13 @@ func (ch *clickhouse) QueryFormat(ctx context.Context, format string, query stri
 // for the full contract.
 //
 // Experimental: this API is experimental and may change or be removed in a
-// future minor release. It is currently only supported over the HTTP
-// protocol; over the native protocol it returns ErrFormatNativeUnsupported.
+// future minor release. Over the native protocol only the client-side formats
+// are supported, see QueryFormat.
 func (ch *clickhouse) InsertFormat(ctx context.Context, format string, query string, data io.Reader) error {
 	if err := validateFormatName(format); err != nil {
 		return err
 	}
-	if ch.opt.Protocol != HTTP {
+	if _, ok := clientFormats[format]; !ok && ch.opt.Protocol != HTTP {
 		return ErrFormatNativeUnsupported
 	}
 	// Validated before acquiring: a malformed statement is a caller mistake
@@ -93,10 +104,16 @@ func (ch *clickhouse) InsertFormat(ctx context.Context, format string, query str
 	if _, _, _, err := extractInsertQueryComponents(query); err != nil {
 		return err
 	}
+	ctx, queryID := ch.withQueryID(ctx)
+	ctx, span := ch.startSpan(ctx, "clickhouse.insert_format", query)
 	conn, err := ch.acquire(ctx)
 	if err != nil {
+		span.end(err)
 		return err
 	}
-	conn.getLogger().Debug("executing format insert", slog.String("sql", query), slog.String("format", format))
-	return conn.insertFormat(ctx, ch.release, format, query, data)
+	conn.getLogger().Debug("executing format insert", slog.String("sql", query), slog.String("format", format), slog.String("query_id", queryID))
+	span.connected(ch, conn)
+	err = conn.insertFormat(ctx, ch.trackQuery(ctx, ch.release), format, query, data)
+	span.end(err)
+	return withQueryIDError(err, queryID)
 }
@@ -0,0 +1,503 @@
+package clickhouse
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/timezone"
+)
+
+// clientFormat is a format the driver encodes and parses itself, which is what
+// QueryFormat and InsertFormat rely on over the native protocol: the native
+// server only exchanges Native blocks.
+type clientFormat uint8
+
+const (
+	formatCSV clientFormat = iota
+	formatTSV
+	formatTSVWithNames
+	formatJSONEachRow
+	formatJSONCompactEachRow
+	formatRowBinary
+)
+
+var clientFormats = map[string]clientFormat{
+	"CSV":                   formatCSV,
+	"TSV":                   formatTSV,
+	"TabSeparated":          formatTSV,
+	"TSVWithNames":          formatTSVWithNames,
+	"TabSeparatedWithNames": formatTSVWithNames,
+	"JSONEachRow":           formatJSONEachRow,
+	"JSONCompactEachRow":    formatJSONCompactEachRow,
+	"RowBinary":             formatRowBinary,
+}
+
+type valueKind uint8
+
+const (
+	valueScalar valueKind = iota
+	valueNullable
+	valueArray
+	valueMap
+	valueTuple
+)
+
+// valueCodec describes how the values of a column are written and parsed by the
+// client-side formats. Values are generic: nil for NULL, the scalar as returned
+// by column.Interface.Row, []any for Array and Tuple and *orderedValues for Map,
+// all of which the columns accept back in AppendRow.
+type valueCodec struct {
+	kind       valueKind
+	scalar     column.Interface // scratch column of a scalar type, used for RowBinary
+	location   *time.Location   // timezone of DateTime and DateTime64 scalars
+	elem       *valueCodec      // Nullable base or Array element
+	key, value *valueCodec
+	elems      []*valueCodec
+	names      []string // Tuple element names, nil unless all elements are named
+}
+
+func newValueCodec(col column.Interface, sc *column.ServerContext) (*valueCodec, error) {
+	switch c := col.(type) {
+	case *column.Nullable:
+		elem, err := newValueCodec(c.Base(), sc)
+		if err != nil {
+			return nil, err
+		}
+		return &valueCodec{kind: valueNullable, elem: elem}, nil
+	case *column.LowCardinality:
+		return newValueCodec(c.Base(), sc)
+	case *column.Array:
+		// Base skips all nesting levels, build the element of this level from the type instead
+		inner := strings.TrimSuffix(strings.TrimPrefix(string(c.Type()), "Array("), ")")
+		base, err := column.Type(inner).Column(c.Name(), sc)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := newValueCodec(base, sc)
+		if err != nil {
+			return nil, err
+		}
+		return &valueCodec{kind: valueArray, elem: elem}, nil
+	case *column.Map:
+		key, err := newValueCodec(c.Keys(), sc)
+		if err != nil {
+			return nil, err
+		}
+		value, err := newValueCodec(c.Values(), sc)
+		if err != nil {
+			return nil, err
+		}
+		return &valueCodec{kind: valueMap, key: key, value: value}, nil
+	case *column.Tuple:
+		codec := &valueCodec{kind: valueTuple}
+		for _, element := range c.Columns() {
+			elem, err := newValueCodec(element, sc)
+			if err != nil {
+				return nil, err
+			}
+			codec.elems = append(codec.elems, elem)
+			codec.names = append(codec.names, element.Name())
+		}
+		for _, name := range codec.names {
+			if name == "" {
+				codec.names = nil
+				break
+			}
+		}
+		return codec, nil
+	case *column.Int8, *column.Int16, *column.Int32, *column.Int64,
+		*column.UInt8, *column.UInt16, *column.UInt32, *column.UInt64,
+		*column.BigInt, *column.Float32, *column.Float64, *column.Bool,
+		*column.String, *column.FixedString, *column.Enum8, *column.Enum16,
+		*column.UUID, *column.Date, *column.Date32, *column.Decimal,
+		*column.IPv4, *column.IPv6:
+		scalar, err := col.Type().Column(col.Name(), sc)
+		if err != nil {
+			return nil, err
+		}
+		return &valueCodec{kind: valueScalar, scalar: scalar}, nil
+	case *column.DateTime, *column.DateTime64:
+		scalar, err := col.Type().Column(col.Name(), sc)
+		if err != nil {
+			return nil, err
+		}
+		codec := &valueCodec{kind: valueScalar, scalar: scalar, location: sc.Timezone}
+		if codec.location == nil {
+			codec.location = time.Local
+		}
+		if name := quotedTypeParam(col.Type()); name != "" {
+			if codec.location, err = timezone.Load(name); err != nil {
+				return nil, err
+			}
+		}
+		return codec, nil
+	}
+	return nil, &column.Error{
+		ColumnType: string(col.Type()),
+		Err:        fmt.Errorf("not supported by the client-side formats"),
+	}
+}
+
+// quotedTypeParam returns the first single quoted parameter of t, the timezone of DateTime types.
+func quotedTypeParam(t column.Type) string {
+	value := string(t)
+	if start := strings.IndexByte(value, '\''); start != -1 {
+		if end := strings.IndexByte(value[start+1:], '\''); end != -1 {
+			return value[start+1 : start+1+end]
+		}
+	}
+	return ""
+}
+
+// orderedValues holds the entries of a Map value in column order.
+type orderedValues struct {
+	keys, values []any
+}
+
+func (m *orderedValues) Put(key, value any) {
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+}
+
+func (m *orderedValues) Iterator() column.MapIterator {
+	return &orderedValuesIterator{m: m, i: -1}
+}
+
+type orderedValuesIterator struct {
+	m *orderedValues
+	i int
+}
+
+func (it *orderedValuesIterator) Next() bool {
+	it.i++
+	return it.i < len(it.m.keys)
+}
+
+func (it *orderedValuesIterator) Key() any   { return it.m.keys[it.i] }
+func (it *orderedValuesIterator) Value() any { return it.m.values[it.i] }
+
+// rowValue returns row of col as a generic value, see valueCodec.
+func rowValue(col column.Interface, row int) any {
+	switch c := col.(type) {
+	case *column.Nullable:
+		if c.Nulls()[row] == 1 {
+			return nil
+		}
+		return rowValue(c.Base(), row)
+	case *column.LowCardinality:
+		return derefValue(c.Row(row, false))
+	case *column.Array:
+		return rowArrayValue(c.Offsets(), 0, c.Base(), row)
+	case *column.Map:
+		var (
+			offsets = c.Offsets()
+			start   int64
+			value   = &orderedValues{}
+		)
+		if row > 0 {
+			start = offsets[row-1]
+		}
+		for i := start; i < offsets[row]; i++ {
+			value.Put(rowValue(c.Keys(), int(i)), rowValue(c.Values(), int(i)))
+		}
+		return value
+	case *column.Tuple:
+		elements := c.Columns()
+		value := make([]any, len(elements))
+		for i, element := range elements {
+			value[i] = rowValue(element, row)
+		}
+		return value
+	}
+	return col.Row(row, false)
+}
+
+func rowArrayValue(offsets [][]uint64, level int, base column.Interface, row int) any {
+	var start uint64
+	if row > 0 {
+		start = offsets[level][row-1]
+	}
+	value := make([]any, 0, offsets[level][row]-start)
+	for i := start; i < offsets[level][row]; i++ {
+		switch {
+		case level+1 < len(offsets):
+			value = append(value, rowArrayValue(offsets, level+1, base, int(i)))
+		default:
+			value = append(value, rowValue(base, int(i)))
+		}
+	}
+	return value
+}
+
+// derefValue dereferences the pointers LowCardinality(Nullable(T)) returns for its rows.
+func derefValue(v any) any {
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		return value.Elem().Interface()
+	}
+	return v
+}
+
+// scalarText returns the text representation of a scalar the way the server
+// writes it, and whether it is a string-like value that is quoted in nested
+// and CSV contexts.
+func (c *valueCodec) scalarText(v any, json bool) (text string, quoted bool, err error) {
+	switch col := c.scalar.(type) {
+	case *column.Float32:
+		return textFloat(float64(v.(float32)), 32, json), false, nil
+	case *column.Float64:
+		return textFloat(v.(float64), 64, json), false, nil
+	case *column.Int64, *column.UInt64, *column.BigInt:
+		// 64 bit and wider integers are quoted in JSON, as output_format_json_quote_64bit_integers does
+		return intText(v), json, nil
+	case *column.Int8, *column.Int16, *column.Int32, *column.UInt8, *column.UInt16, *column.UInt32:
+		return intText(v), false, nil
+	case *column.Bool:
+		return strconv.FormatBool(v.(bool)), false, nil
+	case *column.String, *column.FixedString, *column.Enum8, *column.Enum16:
+		return v.(string), true, nil
+	case *column.UUID:
+		return v.(uuid.UUID).String(), true, nil
+	case *column.Date, *column.Date32:
+		return v.(time.Time).Format("2006-01-02"), true, nil
+	case *column.DateTime:
+		return v.(time.Time).In(c.location).Format("2006-01-02 15:04:05"), true, nil
+	case *column.DateTime64:
+		layout := "2006-01-02 15:04:05"
+		if precision, _ := col.Precision(); precision > 0 {
+			layout += "." + strings.Repeat("0", int(precision))
+		}
+		return v.(time.Time).In(c.location).Format(layout), true, nil
+	case *column.Decimal:
+		return v.(decimal.Decimal).StringFixed(int32(col.Scale())), false, nil
+	case *column.IPv4, *column.IPv6:
+		return fmt.Sprint(v), true, nil
+	}
+	return "", false, fmt.Errorf("unexpected %T value for %s", v, c.scalar.Type())
+}
+
+func intText(v any) string {
+	switch v := v.(type) {
+	case big.Int:
+		return v.String()
+	case *big.Int:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// textFloat writes floats like the server: without exponent for common magnitudes and nan/inf, or null in JSON.
+func textFloat(v float64, bits int, json bool) string {
+	switch {
+	case math.IsNaN(v):
+		if json {
+			return "null"
+		}
+		return "nan"
+	case math.IsInf(v, 1):
+		if json {
+			return "null"
+		}
+		return "inf"
+	case math.IsInf(v, -1):
+		if json {
+			return "null"
+		}
+		return "-inf"
+	}
+	if abs := math.Abs(v); abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		return strconv.FormatFloat(v, 'g', -1, bits)
+	}
+	return strconv.FormatFloat(v, 'f', -1, bits)
+}
+
+// parseScalar parses the text representation of a scalar into a value its column accepts.
+func (c *valueCodec) parseScalar(s string) (any, error) {
+	switch c.scalar.(type) {
+	case *column.Int8:
+		v, err := strconv.ParseInt(s, 10, 8)
+		return int8(v), err
+	case *column.Int16:
+		v, err := strconv.ParseInt(s, 10, 16)
+		return int16(v), err
+	case *column.Int32:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return int32(v), err
+	case *column.Int64:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return v, err
+	case *column.UInt8:
+		v, err := strconv.ParseUint(s, 10, 8)
+		return uint8(v), err
+	case *column.UInt16:
+		v, err := strconv.ParseUint(s, 10, 16)
+		return uint16(v), err
+	case *column.UInt32:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return uint32(v), err
+	case *column.UInt64:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return v, err
+	case *column.BigInt:
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+		return v, nil
+	case *column.Float32:
+		v, err := strconv.ParseFloat(s, 32)
+		return float32(v), err
+	case *column.Float64:
+		return strconv.ParseFloat(s, 64)
+	case *column.Bool:
+		return strconv.ParseBool(s)
+	case *column.Decimal:
+		return decimal.NewFromString(s)
+	case *column.Date, *column.Date32:
+		return time.ParseInLocation("2006-01-02", s, time.UTC)
+	case *column.DateTime, *column.DateTime64:
+		if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(unix, 0), nil
+		}
+		return time.ParseInLocation("2006-01-02 15:04:05.999999999", s, c.location)
+	}
+	// String, FixedString, Enum, UUID and IP columns parse text themselves
+	return s, nil
+}
+
+// writeText writes v in the text representation of style.
+func (c *valueCodec) writeText(buf *bytes.Buffer, v any, style textStyle) error {
+	if v == nil {
+		switch style {
+		case styleJSON:
+			buf.WriteString("null")
+		case styleQuoted:
+			buf.WriteString("NULL")
+		default:
+			buf.WriteString(`\N`)
+		}
+		return nil
+	}
+	switch c.kind {
+	case valueNullable:
+		return c.elem.writeText(buf, v, style)
+	case valueArray, valueMap, valueTuple:
+		switch style {
+		case styleCSV:
+			// composite values are written quoted and then escaped as a CSV string
+			var nested bytes.Buffer
+			if err := c.writeComposite(&nested, v, styleQuoted); err != nil {
+				return err
+			}
+			writeCSVString(buf, nested.String())
+			return nil
+		case styleTSV:
+			return c.writeComposite(buf, v, styleQuoted)
+		}
+		return c.writeComposite(buf, v, style)
+	}
+	text, quoted, err := c.scalarText(v, style == styleJSON)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !quoted:
+		buf.WriteString(text)
+	case style == styleJSON:
+		writeJSONString(buf, text)
+	case style == styleCSV:
+		writeCSVString(buf, text)
+	case style == styleTSV:
+		writeEscapedString(buf, text)
+	default:
+		buf.WriteByte('\'')
+		writeEscapedString(buf, text)
+		buf.WriteByte('\'')
+	}
+	return nil
+}
+
+func (c *valueCodec) writeComposite(buf *bytes.Buffer, v any, style textStyle) error {
+	switch c.kind {
+	case valueArray:
+		values, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("unexpected %T value for Array", v)
+		}
+		buf.WriteByte('[')
+		for i, value := range values {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			if err := c.elem.writeText(buf, value, style); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case valueMap:
+		values, ok := v.(*orderedValues)
+		if !ok {
+			return fmt.Errorf("unexpected %T value for Map", v)
+		}
+		buf.WriteByte('{')
+		for i, key := range values.keys {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			if style == styleJSON {
+				// JSON object keys are always strings, Map keys are always scalars
+				text, _, err := c.key.scalarText(key, false)
+				if err != nil {
+					return err
+				}
+				writeJSONString(buf, text)
+			} else if err := c.key.writeText(buf, key, style); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := c.value.writeText(buf, values.values[i], style); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case valueTuple:
+		values, ok := v.([]any)
+		if !ok || len(values) != len(c.elems) {
+			return fmt.Errorf("unexpected %T value for Tuple", v)
+		}
+		open, closing := byte('('), byte(')')
+		switch {
+		case style == styleJSON && c.names != nil:
+			open, closing = '{', '}'
+		case style == styleJSON:
+			open, closing = '[', ']'
+		}
+		buf.WriteByte(open)
+		for i, value := range values {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			if open == '{' {
+				writeJSONString(buf, c.names[i])
+				buf.WriteByte(':')
+			}
+			if err := c.elems[i].writeText(buf, value, style); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(closing)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package clickhouse
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+var formatTestColumns = [][2]string{
+	{"id", "UInt64"},
+	{"name", "Nullable(String)"},
+	{"score", "Float64"},
+	{"tags", "Array(String)"},
+	{"attrs", "Map(String, Int32)"},
+	{"point", "Tuple(x Int8, label LowCardinality(String))"},
+	{"amount", "Decimal(9, 2)"},
+	{"created", "DateTime64(3, 'UTC')"},
+	{"day", "Date"},
+	{"uid", "UUID"},
+}
+
+func newFormatTestBlock(t *testing.T) *proto.Block {
+	t.Helper()
+	block := &proto.Block{ServerContext: &column.ServerContext{Timezone: time.UTC}}
+	for _, c := range formatTestColumns {
+		require.NoError(t, block.AddColumn(c[0], column.Type(c[1])))
+	}
+	return block
+}
+
+// decodedFormatTestBlock returns block as received from the server.
+func decodedFormatTestBlock(t *testing.T, block *proto.Block) *proto.Block {
+	t.Helper()
+	var buffer chproto.Buffer
+	require.NoError(t, block.Encode(&buffer, proto.DBMS_TCP_PROTOCOL_VERSION))
+	result := &proto.Block{ServerContext: block.ServerContext}
+	require.NoError(t, result.Decode(buffer.Reader(), proto.DBMS_TCP_PROTOCOL_VERSION))
+	return result
+}
+
+func formatTestRows(t *testing.T) *proto.Block {
+	block := newFormatTestBlock(t)
+	name := "it's\ta \"test\"/"
+	require.NoError(t, block.Append(
+		uint64(1), &name, 1.5, []string{"a", "b'c"}, map[string]int32{"k": 1},
+		[]any{int8(-1), "x"}, decimal.RequireFromString("12.5"),
+		time.Date(2024, 5, 1, 10, 30, 0, 120_000_000, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		uuid.MustParse("f47ac10b-58cc-4372-a567-0e02b2c3d479"),
+	))
+	require.NoError(t, block.Append(
+		uint64(2), nil, 1e-7, []string{}, map[string]int32{},
+		[]any{int8(2), ""}, decimal.Zero,
+		time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		uuid.Nil,
+	))
+	return decodedFormatTestBlock(t, block)
+}
+
+func encodeFormatTestRows(t *testing.T, format clientFormat, block *proto.Block) string {
+	t.Helper()
+	var (
+		buf    bytes.Buffer
+		binary chproto.Buffer
+		names  = block.ColumnsNames()
+		codecs []*valueCodec
+	)
+	for _, col := range block.Columns {
+		codec, err := newValueCodec(col, block.ServerContext)
+		require.NoError(t, err)
+		codecs = append(codecs, codec)
+	}
+	if format == formatTSVWithNames {
+		writeTSVNames(&buf, names)
+	}
+	values := make([]any, len(codecs))
+	for row := 0; row < block.Rows(); row++ {
+		for i, col := range block.Columns {
+			values[i] = rowValue(col, row)
+		}
+		if format == formatRowBinary {
+			for i, codec := range codecs {
+				require.NoError(t, codec.writeBinary(&binary, values[i]))
+			}
+			continue
+		}
+		require.NoError(t, writeTextRow(&buf, format, names, codecs, values))
+	}
+	buf.Write(binary.Buf)
+	return buf.String()
+}
+
+func decodeFormatTestRows(t *testing.T, format clientFormat, data string) *proto.Block {
+	t.Helper()
+	block := newFormatTestBlock(t)
+	var codecs []*valueCodec
+	for _, col := range block.Columns {
+		codec, err := newValueCodec(col, block.ServerContext)
+		require.NoError(t, err)
+		codecs = append(codecs, codec)
+	}
+	decoder, err := newRowDecoder(format, strings.NewReader(data), block.ColumnsNames(), codecs)
+	require.NoError(t, err)
+	for {
+		values, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, block.Append(values...))
+	}
+	return decodedFormatTestBlock(t, block)
+}
+
+func TestClientFormatEncode(t *testing.T) {
+	block := formatTestRows(t)
+	tests := map[clientFormat]string{
+		formatTSV: "1\tit\\'s\\ta \"test\"/\t1.5\t['a','b\\'c']\t{'k':1}\t(-1,'x')\t12.50\t2024-05-01 10:30:00.120\t2024-05-01\tf47ac10b-58cc-4372-a567-0e02b2c3d479\n" +
+			"2\t\\N\t1e-07\t[]\t{}\t(2,'')\t0.00\t2024-05-02 00:00:00.000\t2024-05-02\t00000000-0000-0000-0000-000000000000\n",
+		formatCSV: "1,\"it's\ta \"\"test\"\"/\",1.5,\"['a','b\\'c']\",\"{'k':1}\",\"(-1,'x')\",12.50,\"2024-05-01 10:30:00.120\",\"2024-05-01\",\"f47ac10b-58cc-4372-a567-0e02b2c3d479\"\n" +
+			"2,\\N,1e-07,\"[]\",\"{}\",\"(2,'')\",0.00,\"2024-05-02 00:00:00.000\",\"2024-05-02\",\"00000000-0000-0000-0000-000000000000\"\n",
+		formatJSONEachRow: `{"id":"1","name":"it's\ta \"test\"\/","score":1.5,"tags":["a","b'c"],"attrs":{"k":1},"point":{"x":-1,"label":"x"},"amount":12.50,"created":"2024-05-01 10:30:00.120","day":"2024-05-01","uid":"f47ac10b-58cc-4372-a567-0e02b2c3d479"}` + "\n" +
+			`{"id":"2","name":null,"score":1e-07,"tags":[],"attrs":{},"point":{"x":2,"label":""},"amount":0.00,"created":"2024-05-02 00:00:00.000","day":"2024-05-02","uid":"00000000-0000-0000-0000-000000000000"}` + "\n",
+		formatJSONCompactEachRow: `["1","it's\ta \"test\"\/",1.5,["a","b'c"],{"k":1},{"x":-1,"label":"x"},12.50,"2024-05-01 10:30:00.120","2024-05-01","f47ac10b-58cc-4372-a567-0e02b2c3d479"]` + "\n" +
+			`["2",null,1e-07,[],{},{"x":2,"label":""},0.00,"2024-05-02 00:00:00.000","2024-05-02","00000000-0000-0000-0000-000000000000"]` + "\n",
+	}
+	for format, expected := range tests {
+		assert.Equal(t, expected, encodeFormatTestRows(t, format, block), "format %d", format)
+	}
+	assert.True(t, strings.HasPrefix(encodeFormatTestRows(t, formatTSVWithNames, block),
+		"id\tname\tscore\ttags\tattrs\tpoint\tamount\tcreated\tday\tuid\n1\t"))
+}
+
+func TestClientFormatRoundTrip(t *testing.T) {
+	source := formatTestRows(t)
+	for name, format := range clientFormats {
+		t.Run(name, func(t *testing.T) {
+			result := decodeFormatTestRows(t, format, encodeFormatTestRows(t, format, source))
+			require.Equal(t, source.Rows(), result.Rows())
+			for c := range source.Columns {
+				for row := 0; row < source.Rows(); row++ {
+					assert.Equal(t, source.Columns[c].Row(row, false), result.Columns[c].Row(row, false),
+						"column %s row %d", formatTestColumns[c][0], row)
+				}
+			}
+		})
+	}
+}
+
+func TestClientFormatTSVWithNamesReorders(t *testing.T) {
+	block := &proto.Block{ServerContext: &column.ServerContext{Timezone: time.UTC}}
+	require.NoError(t, block.AddColumn("a", "UInt8"))
+	require.NoError(t, block.AddColumn("b", "String"))
+	codecs := make([]*valueCodec, 2)
+	for i, col := range block.Columns {
+		var err error
+		codecs[i], err = newValueCodec(col, block.ServerContext)
+		require.NoError(t, err)
+	}
+	decoder, err := newRowDecoder(formatTSVWithNames, strings.NewReader("b\ta\nx\\ny\t7\n"), []string{"a", "b"}, codecs)
+	require.NoError(t, err)
+	values, err := decoder.next()
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint8(7), "x\ny"}, values)
+	_, err = decoder.next()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = newRowDecoder(formatTSVWithNames, strings.NewReader("b\tc\n"), []string{"a", "b"}, codecs)
+	assert.ErrorContains(t, err, `no column "a"`)
+}
+
+func TestClientFormatUnsupportedColumn(t *testing.T) {
+	col, err := column.Type("Variant(String, UInt64)").Column("v", &column.ServerContext{})
+	require.NoError(t, err)
+	_, err = newValueCodec(col, &column.ServerContext{})
+	assert.ErrorContains(t, err, "not supported by the client-side formats")
+}
+
+func TestClientFormatParseLiteral(t *testing.T) {
+	l, rest, err := parseLiteral(`[(1, 'a\'b'), NULL, {'k': [2]}] tail`)
+	require.NoError(t, err)
+	assert.Equal(t, " tail", rest)
+	require.True(t, l.isList)
+	require.Len(t, l.items, 3)
+	assert.True(t, l.items[0].isTuple)
+	assert.Equal(t, "a'b", l.items[0].items[1].text)
+	assert.True(t, l.items[1].null)
+	assert.True(t, l.items[2].isMap)
+
+	_, _, err = parseLiteral(`['a'`)
+	assert.Error(t, err)
+}
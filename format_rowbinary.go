@@ -0,0 +1,156 @@
+package clickhouse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ClickHouse/ch-go/proto"
+)
+
+// RowBinary serializes scalars exactly like a single row Native column, composite
+// values are length prefixed (Array, Map) or flagged (Nullable) in place.
+
+func (c *valueCodec) writeBinary(buf *proto.Buffer, v any) error {
+	switch c.kind {
+	case valueNullable:
+		if v == nil {
+			buf.PutUInt8(1)
+			return nil
+		}
+		buf.PutUInt8(0)
+		return c.elem.writeBinary(buf, v)
+	case valueArray:
+		values, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("unexpected %T value for Array", v)
+		}
+		buf.PutUVarInt(uint64(len(values)))
+		for _, value := range values {
+			if err := c.elem.writeBinary(buf, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case valueMap:
+		values, ok := v.(*orderedValues)
+		if !ok {
+			return fmt.Errorf("unexpected %T value for Map", v)
+		}
+		buf.PutUVarInt(uint64(len(values.keys)))
+		for i, key := range values.keys {
+			if err := c.key.writeBinary(buf, key); err != nil {
+				return err
+			}
+			if err := c.value.writeBinary(buf, values.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case valueTuple:
+		values, ok := v.([]any)
+		if !ok || len(values) != len(c.elems) {
+			return fmt.Errorf("unexpected %T value for Tuple", v)
+		}
+		for i, value := range values {
+			if err := c.elems[i].writeBinary(buf, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c.scalar.Reset()
+	if err := c.scalar.AppendRow(v); err != nil {
+		return err
+	}
+	c.scalar.Encode(buf)
+	return nil
+}
+
+func (c *valueCodec) readBinary(reader *proto.Reader) (any, error) {
+	switch c.kind {
+	case valueNullable:
+		null, err := reader.UInt8()
+		if err != nil || null == 1 {
+			return nil, err
+		}
+		return c.elem.readBinary(reader)
+	case valueArray:
+		n, err := reader.UVarInt()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			value, err := c.elem.readBinary(reader)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case valueMap:
+		n, err := reader.UVarInt()
+		if err != nil {
+			return nil, err
+		}
+		values := &orderedValues{}
+		for i := uint64(0); i < n; i++ {
+			key, err := c.key.readBinary(reader)
+			if err != nil {
+				return nil, err
+			}
+			value, err := c.value.readBinary(reader)
+			if err != nil {
+				return nil, err
+			}
+			values.Put(key, value)
+		}
+		return values, nil
+	case valueTuple:
+		values := make([]any, len(c.elems))
+		for i, elem := range c.elems {
+			value, err := elem.readBinary(reader)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	c.scalar.Reset()
+	if err := c.scalar.Decode(reader, 1); err != nil {
+		return nil, err
+	}
+	return c.scalar.Row(0, false), nil
+}
+
+type rowBinaryDecoder struct {
+	reader *proto.Reader
+	codecs []*valueCodec
+}
+
+func newRowBinaryDecoder(r io.Reader, codecs []*valueCodec) *rowBinaryDecoder {
+	return &rowBinaryDecoder{
+		reader: proto.NewReader(r),
+		codecs: codecs,
+	}
+}
+
+func (d *rowBinaryDecoder) next() ([]any, error) {
+	values := make([]any, len(d.codecs))
+	for i, codec := range d.codecs {
+		value, err := codec.readBinary(d.reader)
+		switch {
+		case i == 0 && errors.Is(err, io.EOF):
+			// the input ends between rows
+			return nil, io.EOF
+		case errors.Is(err, io.EOF):
+			return nil, io.ErrUnexpectedEOF
+		case err != nil:
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,553 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// textStyle selects how values are written and parsed by the text formats.
+type textStyle uint8
+
+const (
+	styleTSV    textStyle = iota // top level TSV fields: escaped, no quotes
+	styleCSV                     // top level CSV fields: strings in double quotes
+	styleQuoted                  // values nested in Array, Map and Tuple: strings in single quotes
+	styleJSON
+)
+
+const hexDigits = "0123456789abcdef"
+
+// writeEscapedString escapes s like the server escapes strings in TSV and inside single quotes.
+func writeEscapedString(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\'':
+			buf.WriteString(`\'`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case 0:
+			buf.WriteString(`\0`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+func writeCSVString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	buf.WriteString(strings.ReplaceAll(s, `"`, `""`))
+	buf.WriteByte('"')
+}
+
+// writeJSONString writes s as a JSON string, escaping forward slashes like the server does by default.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\' || c == '/':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case c == '\n':
+				buf.WriteString(`\n`)
+			case c == '\r':
+				buf.WriteString(`\r`)
+			case c == '\t':
+				buf.WriteString(`\t`)
+			case c == '\b':
+				buf.WriteString(`\b`)
+			case c == '\f':
+				buf.WriteString(`\f`)
+			case c < 0x20:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			default:
+				buf.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(`�`)
+		} else {
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+}
+
+// unescapeString reverses writeEscapedString.
+func unescapeString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case '0':
+			b.WriteByte(0)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// writeTextRow writes a row in one of the text formats.
+func writeTextRow(buf *bytes.Buffer, format clientFormat, names []string, codecs []*valueCodec, values []any) error {
+	style, separator, open, closing := styleTSV, byte('\t'), "", ""
+	switch format {
+	case formatCSV:
+		style, separator = styleCSV, ','
+	case formatJSONEachRow:
+		style, separator, open, closing = styleJSON, ',', "{", "}"
+	case formatJSONCompactEachRow:
+		style, separator, open, closing = styleJSON, ',', "[", "]"
+	}
+	buf.WriteString(open)
+	for i, codec := range codecs {
+		if i != 0 {
+			buf.WriteByte(separator)
+		}
+		if format == formatJSONEachRow {
+			writeJSONString(buf, names[i])
+			buf.WriteByte(':')
+		}
+		if err := codec.writeText(buf, values[i], style); err != nil {
+			return fmt.Errorf("column %s: %w", names[i], err)
+		}
+	}
+	buf.WriteString(closing)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// writeTSVNames writes the header line of TSVWithNames.
+func writeTSVNames(buf *bytes.Buffer, names []string) {
+	for i, name := range names {
+		if i != 0 {
+			buf.WriteByte('\t')
+		}
+		writeEscapedString(buf, name)
+	}
+	buf.WriteByte('\n')
+}
+
+// rowDecoder parses rows of a client-side format into values ordered like the insert columns.
+type rowDecoder interface {
+	// next returns the next row, io.EOF once the input is exhausted.
+	next() ([]any, error)
+}
+
+func newRowDecoder(format clientFormat, r io.Reader, names []string, codecs []*valueCodec) (rowDecoder, error) {
+	switch format {
+	case formatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = len(codecs)
+		reader.ReuseRecord = true
+		return &csvDecoder{reader: reader, codecs: codecs}, nil
+	case formatTSV, formatTSVWithNames:
+		decoder := &tsvDecoder{reader: bufio.NewReader(r), codecs: codecs}
+		if format == formatTSVWithNames {
+			if err := decoder.readNames(names); err != nil {
+				return nil, err
+			}
+		}
+		return decoder, nil
+	case formatJSONEachRow, formatJSONCompactEachRow:
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		return &jsonDecoder{decoder: decoder, names: names, codecs: codecs, compact: format == formatJSONCompactEachRow}, nil
+	case formatRowBinary:
+		return newRowBinaryDecoder(r, codecs), nil
+	}
+	return nil, ErrFormatNativeUnsupported
+}
+
+type csvDecoder struct {
+	reader *csv.Reader
+	codecs []*valueCodec
+}
+
+func (d *csvDecoder) next() ([]any, error) {
+	record, err := d.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(d.codecs))
+	for i, field := range record {
+		if values[i], err = d.codecs[i].parseText(field, styleCSV); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+type tsvDecoder struct {
+	reader *bufio.Reader
+	codecs []*valueCodec
+	order  []int // position of each column in a TSVWithNames line
+}
+
+func (d *tsvDecoder) line() ([]string, error) {
+	line, err := d.reader.ReadString('\n')
+	switch {
+	case err == io.EOF && line == "":
+		return nil, io.EOF
+	case err != nil && err != io.EOF:
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(line, "\n"), "\t"), nil
+}
+
+func (d *tsvDecoder) readNames(names []string) error {
+	header, err := d.line()
+	if err != nil {
+		return fmt.Errorf("read TSVWithNames header: %w", err)
+	}
+	d.order = make([]int, len(names))
+	for i, name := range names {
+		if d.order[i] = slices.Index(header, name); d.order[i] == -1 {
+			return fmt.Errorf("TSVWithNames header has no column %q", name)
+		}
+	}
+	return nil
+}
+
+func (d *tsvDecoder) next() ([]any, error) {
+	fields, err := d.line()
+	if err != nil {
+		return nil, err
+	}
+	if d.order == nil && len(fields) != len(d.codecs) || len(fields) < len(d.order) {
+		return nil, fmt.Errorf("TSV row has %d fields, expected %d", len(fields), len(d.codecs))
+	}
+	values := make([]any, len(d.codecs))
+	for i, codec := range d.codecs {
+		field := fields[i]
+		if d.order != nil {
+			field = fields[d.order[i]]
+		}
+		if values[i], err = codec.parseText(field, styleTSV); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+type jsonDecoder struct {
+	decoder *json.Decoder
+	names   []string
+	codecs  []*valueCodec
+	compact bool
+}
+
+func (d *jsonDecoder) next() ([]any, error) {
+	var (
+		err    error
+		values = make([]any, len(d.codecs))
+	)
+	if d.compact {
+		var row []any
+		if err := d.decoder.Decode(&row); err != nil {
+			return nil, err
+		}
+		if len(row) != len(d.codecs) {
+			return nil, fmt.Errorf("JSONCompactEachRow row has %d values, expected %d", len(row), len(d.codecs))
+		}
+		for i, value := range row {
+			if values[i], err = d.codecs[i].parseJSON(value); err != nil {
+				return nil, fmt.Errorf("column %s: %w", d.names[i], err)
+			}
+		}
+		return values, nil
+	}
+	var row map[string]any
+	if err := d.decoder.Decode(&row); err != nil {
+		return nil, err
+	}
+	// columns missing from the object are inserted as NULL or default, unknown keys are skipped
+	for i, name := range d.names {
+		if values[i], err = d.codecs[i].parseJSON(row[name]); err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+	return values, nil
+}
+
+// parseText parses a top level TSV or CSV field.
+func (c *valueCodec) parseText(s string, style textStyle) (any, error) {
+	if s == `\N` {
+		return nil, nil
+	}
+	codec := c
+	if codec.kind == valueNullable {
+		codec = codec.elem
+	}
+	if codec.kind != valueScalar {
+		// composite values are written like their nested representation
+		literal, rest, err := parseLiteral(s)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("unexpected %q after value", rest)
+		}
+		return codec.fromLiteral(literal)
+	}
+	if style == styleTSV {
+		s = unescapeString(s)
+	}
+	return codec.parseScalar(s)
+}
+
+// literal is a value parsed from the quoted representation of nested values.
+type literal struct {
+	null    bool
+	text    string
+	items   []literal // Array and Tuple elements, or the alternating keys and values of a Map
+	isMap   bool
+	isTuple bool
+	isList  bool
+}
+
+// parseLiteral parses a single value in quoted representation from the start of s.
+func parseLiteral(s string) (literal, string, error) {
+	s = strings.TrimLeft(s, " ")
+	if s == "" {
+		return literal{}, s, errors.New("unexpected end of value")
+	}
+	switch s[0] {
+	case '\'':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				if i+1 < len(s) {
+					b.WriteString(unescapeString(s[i : i+2]))
+					i++
+				}
+			case '\'':
+				return literal{text: b.String()}, s[i+1:], nil
+			default:
+				b.WriteByte(s[i])
+			}
+		}
+		return literal{}, "", errors.New("unterminated string")
+	case '[', '(', '{':
+		var (
+			result = literal{isList: s[0] == '[', isTuple: s[0] == '(', isMap: s[0] == '{'}
+			end    = map[byte]byte{'[': ']', '(': ')', '{': '}'}[s[0]]
+			rest   = strings.TrimLeft(s[1:], " ")
+		)
+		if rest != "" && rest[0] == end {
+			return result, rest[1:], nil
+		}
+		for {
+			item, tail, err := parseLiteral(rest)
+			if err != nil {
+				return literal{}, "", err
+			}
+			result.items = append(result.items, item)
+			if tail = strings.TrimLeft(tail, " "); tail == "" {
+				return literal{}, "", errors.New("unexpected end of value")
+			}
+			switch {
+			case tail[0] == end:
+				return result, tail[1:], nil
+			case tail[0] == ',', result.isMap && tail[0] == ':':
+				rest = tail[1:]
+			default:
+				return literal{}, "", fmt.Errorf("unexpected %q in value", tail[0])
+			}
+		}
+	}
+	end := strings.IndexAny(s, ",:])} ")
+	if end == -1 {
+		end = len(s)
+	}
+	token := s[:end]
+	if token == "NULL" || token == `\N` {
+		return literal{null: true}, s[end:], nil
+	}
+	return literal{text: token}, s[end:], nil
+}
+
+func (c *valueCodec) fromLiteral(l literal) (any, error) {
+	if l.null {
+		return nil, nil
+	}
+	switch c.kind {
+	case valueNullable:
+		return c.elem.fromLiteral(l)
+	case valueArray:
+		if !l.isList {
+			return nil, errors.New("expected an array")
+		}
+		values := make([]any, len(l.items))
+		for i, item := range l.items {
+			value, err := c.elem.fromLiteral(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case valueMap:
+		if !l.isMap || len(l.items)%2 != 0 {
+			return nil, errors.New("expected a map")
+		}
+		values := &orderedValues{}
+		for i := 0; i < len(l.items); i += 2 {
+			key, err := c.key.fromLiteral(l.items[i])
+			if err != nil {
+				return nil, err
+			}
+			value, err := c.value.fromLiteral(l.items[i+1])
+			if err != nil {
+				return nil, err
+			}
+			values.Put(key, value)
+		}
+		return values, nil
+	case valueTuple:
+		if !l.isTuple || len(l.items) != len(c.elems) {
+			return nil, fmt.Errorf("expected a tuple of %d elements", len(c.elems))
+		}
+		values := make([]any, len(l.items))
+		for i, item := range l.items {
+			value, err := c.elems[i].fromLiteral(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	if l.isList || l.isMap || l.isTuple {
+		return nil, fmt.Errorf("unexpected composite value for %s", c.scalar.Type())
+	}
+	return c.parseScalar(l.text)
+}
+
+// parseJSON converts a value decoded with json.Decoder.UseNumber.
+func (c *valueCodec) parseJSON(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch c.kind {
+	case valueNullable:
+		return c.elem.parseJSON(v)
+	case valueArray:
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an array, got %T", v)
+		}
+		values := make([]any, len(items))
+		for i, item := range items {
+			value, err := c.elem.parseJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case valueMap:
+		object, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %T", v)
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		values := &orderedValues{}
+		for _, key := range keys {
+			k, err := c.key.parseScalar(key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := c.value.parseJSON(object[key])
+			if err != nil {
+				return nil, err
+			}
+			values.Put(k, value)
+		}
+		return values, nil
+	case valueTuple:
+		var items []any
+		switch v := v.(type) {
+		case []any:
+			items = v
+		case map[string]any:
+			if c.names == nil {
+				return nil, errors.New("expected an array for an unnamed tuple")
+			}
+			for _, name := range c.names {
+				items = append(items, v[name])
+			}
+		}
+		if len(items) != len(c.elems) {
+			return nil, fmt.Errorf("expected a tuple of %d elements", len(c.elems))
+		}
+		values := make([]any, len(items))
+		for i, item := range items {
+			value, err := c.elems[i].parseJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	switch v := v.(type) {
+	case json.Number:
+		return c.parseScalar(v.String())
+	case string:
+		return c.parseScalar(v)
+	case bool:
+		if v {
+			return c.parseScalar("true")
+		}
+		return c.parseScalar("false")
+	}
+	return nil, fmt.Errorf("unexpected JSON %T for %s", v, c.scalar.Type())
+}
@@ -0,0 +1,300 @@
+package clickhouse
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HostSelector picks the address each new connection is dialed to and is
+// told how its hosts behave. Set it with Options.HostSelector; it replaces
+// ConnOpenStrategy and is ignored when Options.DialStrategy is set.
+// Implementations must be safe for concurrent use.
+type HostSelector interface {
+	// Select returns the addresses to dial for a new connection, most preferred
+	// first. Addresses left out are not dialed.
+	Select(addrs []string) []string
+	// Report records the outcome of dialing addr and how long it took, or, with
+	// a zero latency, that an established connection to addr broke with err.
+	Report(addr string, latency time.Duration, err error)
+	// Connected and Disconnected track the connections open to addr.
+	Connected(addr string)
+	Disconnected(addr string)
+}
+
+type HostSelectStrategy uint8
+
+const (
+	HostSelectInOrder HostSelectStrategy = iota
+	HostSelectRoundRobin
+	HostSelectRandom
+	// HostSelectLeastConnections prefers the host with the fewest open connections.
+	HostSelectLeastConnections
+	// HostSelectWeighted picks hosts at random in proportion to HostHealthOptions.Weights.
+	HostSelectWeighted
+	// HostSelectLowestLatency prefers the host with the lowest dial latency.
+	HostSelectLowestLatency
+)
+
+type HostHealthOptions struct {
+	Strategy HostSelectStrategy
+	// Weights of addresses for HostSelectWeighted, default 1.
+	Weights map[string]int
+	// MaxFailures is the number of consecutive failures that ejects a host, default 3.
+	MaxFailures int
+	// EjectionTime is how long a host is first ejected for, default 30s. It doubles
+	// each time a recovery probe fails, up to MaxEjectionTime (default 5m).
+	EjectionTime    time.Duration
+	MaxEjectionTime time.Duration
+}
+
+// HostState is the health of an address as tracked by HostHealthSelector.
+type HostState struct {
+	Addr    string
+	Healthy bool
+	// EjectedUntil is when an ejected host is next probed, zero for healthy hosts.
+	EjectedUntil        time.Time
+	ConsecutiveFailures int
+	Connections         int
+	// Latency is the moving average of dial latency.
+	Latency   time.Duration
+	LastError error
+}
+
+type hostHealth struct {
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+	probing      time.Time // when the ejected host was handed out for a recovery probe
+	connections  int
+	latency      time.Duration
+	lastError    error
+}
+
+// latencyDecay weighs the latest dial in the latency moving average.
+const latencyDecay = 0.3
+
+// HostHealthSelector is a HostSelector that ejects hosts after consecutive
+// failures. Once the ejection expires, a single connection is dialed to the
+// host as a recovery probe: success restores it, failure ejects it again for
+// twice as long. If every host is ejected, all of them are tried, the one whose
+// ejection expires first leading.
+type HostHealthSelector struct {
+	opt   HostHealthOptions
+	mu    sync.Mutex
+	next  int
+	hosts map[string]*hostHealth
+	now   func() time.Time
+}
+
+func NewHostHealthSelector(opt HostHealthOptions) *HostHealthSelector {
+	if opt.MaxFailures <= 0 {
+		opt.MaxFailures = 3
+	}
+	if opt.EjectionTime <= 0 {
+		opt.EjectionTime = 30 * time.Second
+	}
+	if opt.MaxEjectionTime < opt.EjectionTime {
+		opt.MaxEjectionTime = max(5*time.Minute, opt.EjectionTime)
+	}
+	return &HostHealthSelector{
+		opt:   opt,
+		hosts: make(map[string]*hostHealth),
+		now:   time.Now,
+	}
+}
+
+func (s *HostHealthSelector) host(addr string) *hostHealth {
+	h, ok := s.hosts[addr]
+	if !ok {
+		h = &hostHealth{}
+		s.hosts[addr] = h
+	}
+	return h
+}
+
+func (s *HostHealthSelector) Select(addrs []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		now              = s.now()
+		healthy, ejected []string
+		probe            string
+	)
+	for _, addr := range addrs {
+		h := s.host(addr)
+		switch {
+		case h.failures < s.opt.MaxFailures:
+			healthy = append(healthy, addr)
+		case probe == "" && !now.Before(h.ejectedUntil) && now.Sub(h.probing) >= s.opt.EjectionTime:
+			// one connection at a time probes an ejected host; the probe is given
+			// up on after EjectionTime in case the dial was never reported
+			h.probing, probe = now, addr
+		default:
+			ejected = append(ejected, addr)
+		}
+	}
+	selected := s.order(healthy)
+	if probe != "" {
+		selected = append([]string{probe}, selected...)
+	}
+	if len(selected) != 0 {
+		return selected
+	}
+	slices.SortStableFunc(ejected, func(a, b string) int {
+		return s.hosts[a].ejectedUntil.Compare(s.hosts[b].ejectedUntil)
+	})
+	return ejected
+}
+
+// order sorts healthy addresses by the strategy.
+func (s *HostHealthSelector) order(addrs []string) []string {
+	if len(addrs) == 0 {
+		return nil
+	}
+	switch s.opt.Strategy {
+	case HostSelectRoundRobin:
+		start := s.next % len(addrs)
+		s.next++
+		return append(addrs[start:len(addrs):len(addrs)], addrs[:start]...)
+	case HostSelectRandom:
+		rand.Shuffle(len(addrs), func(i, j int) {
+			addrs[i], addrs[j] = addrs[j], addrs[i]
+		})
+	case HostSelectLeastConnections:
+		slices.SortStableFunc(addrs, func(a, b string) int {
+			return cmp.Compare(s.hosts[a].connections, s.hosts[b].connections)
+		})
+	case HostSelectLowestLatency:
+		slices.SortStableFunc(addrs, func(a, b string) int {
+			return cmp.Compare(s.hosts[a].latency, s.hosts[b].latency)
+		})
+	case HostSelectWeighted:
+		// weighted random order: sort by u^(1/weight) descending
+		keys := make(map[string]float64, len(addrs))
+		for _, addr := range addrs {
+			weight := 1
+			if w, ok := s.opt.Weights[addr]; ok {
+				weight = w
+			}
+			if weight <= 0 {
+				keys[addr] = -1
+				continue
+			}
+			keys[addr] = math.Pow(rand.Float64(), 1/float64(weight))
+		}
+		slices.SortStableFunc(addrs, func(a, b string) int {
+			return cmp.Compare(keys[b], keys[a])
+		})
+	}
+	return addrs
+}
+
+func (s *HostHealthSelector) Report(addr string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.host(addr)
+	h.probing = time.Time{}
+	if err == nil {
+		h.failures, h.ejections, h.ejectedUntil, h.lastError = 0, 0, time.Time{}, nil
+		if latency > 0 {
+			if h.latency == 0 {
+				h.latency = latency
+			} else {
+				h.latency = time.Duration(latencyDecay*float64(latency) + (1-latencyDecay)*float64(h.latency))
+			}
+		}
+		return
+	}
+	h.failures++
+	h.lastError = err
+	if h.failures < s.opt.MaxFailures {
+		return
+	}
+	ejection := s.opt.EjectionTime << min(h.ejections, 16)
+	if ejection <= 0 || ejection > s.opt.MaxEjectionTime {
+		ejection = s.opt.MaxEjectionTime
+	}
+	h.ejections++
+	h.ejectedUntil = s.now().Add(ejection)
+}
+
+func (s *HostHealthSelector) Connected(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.host(addr).connections++
+}
+
+func (s *HostHealthSelector) Disconnected(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h := s.host(addr); h.connections > 0 {
+		h.connections--
+	}
+}
+
+// Hosts returns the state of every address seen, sorted by address.
+func (s *HostHealthSelector) Hosts() []HostState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make([]HostState, 0, len(s.hosts))
+	for addr, h := range s.hosts {
+		state := HostState{
+			Addr:                addr,
+			Healthy:             h.failures < s.opt.MaxFailures,
+			ConsecutiveFailures: h.failures,
+			Connections:         h.connections,
+			Latency:             h.latency,
+			LastError:           h.lastError,
+		}
+		if !state.Healthy {
+			state.EjectedUntil = h.ejectedUntil
+		}
+		states = append(states, state)
+	}
+	slices.SortFunc(states, func(a, b HostState) int {
+		return cmp.Compare(a.Addr, b.Addr)
+	})
+	return states
+}
+
+// dialSelectedHost dials the addresses in the order given by selector until one
+// succeeds, reporting each outcome. Dials interrupted by ctx are not reported.
+func dialSelectedHost[T any](ctx context.Context, selector HostSelector, addrs []string, dial func(ctx context.Context, addr string) (T, error)) (conn T, addr string, err error) {
+	for _, addr = range selector.Select(slices.Clone(addrs)) {
+		start := time.Now()
+		if conn, err = dial(ctx, addr); err == nil {
+			selector.Report(addr, time.Since(start), nil)
+			return conn, addr, nil
+		}
+		if ctx.Err() != nil {
+			return conn, "", err
+		}
+		selector.Report(addr, 0, err)
+	}
+	if err == nil {
+		err = ErrAcquireConnNoAddress
+	}
+	return conn, "", err
+}
+
+// closeHooker is implemented by transports that call a hook once when closed.
+type closeHooker interface {
+	setCloseHook(hook func())
+}
+
+// trackHost counts conn as open to addr with selector until it is closed.
+func trackHost(selector HostSelector, addr string, conn any) {
+	hooker, ok := conn.(closeHooker)
+	if !ok {
+		return
+	}
+	selector.Connected(addr)
+	hooker.setCloseHook(func() {
+		selector.Disconnected(addr)
+	})
+}
@@ -0,0 +1,120 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHostSelector(opt HostHealthOptions) (*HostHealthSelector, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewHostHealthSelector(opt)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestHostHealthSelectorEjection(t *testing.T) {
+	s, now := newTestHostSelector(HostHealthOptions{MaxFailures: 2, EjectionTime: time.Minute})
+	addrs := []string{"a", "b", "c"}
+	refused := errors.New("connection refused")
+
+	assert.Equal(t, addrs, s.Select(addrs))
+	s.Report("a", 0, refused)
+	assert.Equal(t, addrs, s.Select(addrs), "a single failure keeps the host")
+	s.Report("a", 0, refused)
+	assert.Equal(t, []string{"b", "c"}, s.Select(addrs))
+
+	hosts := s.Hosts()
+	require.Len(t, hosts, 3)
+	assert.False(t, hosts[0].Healthy)
+	assert.Equal(t, 2, hosts[0].ConsecutiveFailures)
+	assert.Equal(t, now.Add(time.Minute), hosts[0].EjectedUntil)
+	assert.Equal(t, refused, hosts[0].LastError)
+	assert.True(t, hosts[1].Healthy)
+
+	// once the ejection expires a single recovery probe is let through
+	*now = now.Add(time.Minute)
+	assert.Equal(t, []string{"a", "b", "c"}, s.Select(addrs))
+	assert.Equal(t, []string{"b", "c"}, s.Select(addrs))
+
+	// a failed probe doubles the ejection
+	s.Report("a", 0, refused)
+	assert.Equal(t, now.Add(2*time.Minute), s.Hosts()[0].EjectedUntil)
+	*now = now.Add(2 * time.Minute)
+	assert.Equal(t, []string{"a", "b", "c"}, s.Select(addrs))
+
+	// a successful probe restores the host
+	s.Report("a", 10*time.Millisecond, nil)
+	assert.Equal(t, addrs, s.Select(addrs))
+	assert.True(t, s.Hosts()[0].Healthy)
+	assert.Equal(t, 10*time.Millisecond, s.Hosts()[0].Latency)
+}
+
+func TestHostHealthSelectorAllEjected(t *testing.T) {
+	s, now := newTestHostSelector(HostHealthOptions{MaxFailures: 1, EjectionTime: time.Minute})
+	s.Report("a", 0, errors.New("down"))
+	*now = now.Add(time.Second)
+	s.Report("b", 0, errors.New("down"))
+	assert.Equal(t, []string{"a", "b"}, s.Select([]string{"b", "a"}), "earliest recovery first")
+}
+
+func TestHostHealthSelectorStrategies(t *testing.T) {
+	addrs := []string{"a", "b", "c"}
+
+	s, _ := newTestHostSelector(HostHealthOptions{Strategy: HostSelectRoundRobin})
+	assert.Equal(t, []string{"a", "b", "c"}, s.Select(addrs))
+	assert.Equal(t, []string{"b", "c", "a"}, s.Select(addrs))
+	assert.Equal(t, []string{"c", "a", "b"}, s.Select(addrs))
+
+	s, _ = newTestHostSelector(HostHealthOptions{Strategy: HostSelectLeastConnections})
+	s.Connected("a")
+	s.Connected("a")
+	s.Connected("b")
+	assert.Equal(t, []string{"c", "b", "a"}, s.Select(addrs))
+	s.Disconnected("a")
+	s.Disconnected("a")
+	assert.Equal(t, []string{"a", "c", "b"}, s.Select(addrs))
+
+	s, _ = newTestHostSelector(HostHealthOptions{Strategy: HostSelectLowestLatency})
+	s.Report("a", 30*time.Millisecond, nil)
+	s.Report("b", 10*time.Millisecond, nil)
+	s.Report("c", 20*time.Millisecond, nil)
+	assert.Equal(t, []string{"b", "c", "a"}, s.Select(addrs))
+
+	s, _ = newTestHostSelector(HostHealthOptions{
+		Strategy: HostSelectWeighted,
+		Weights:  map[string]int{"a": 100, "b": 1, "c": 0},
+	})
+	first := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		selected := s.Select(addrs)
+		assert.Equal(t, "c", selected[2], "zero weight is tried last")
+		first[selected[0]]++
+	}
+	assert.Greater(t, first["a"], 900)
+}
+
+func TestHostSelectorDial(t *testing.T) {
+	selector := NewHostHealthSelector(HostHealthOptions{MaxFailures: 2})
+	conn, err := Open(&Options{
+		// nothing listens on the port, so every dial is refused
+		Addr:         []string{"127.0.0.1:1"},
+		DialTimeout:  time.Second,
+		HostSelector: selector,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		require.Error(t, conn.Ping(context.Background()))
+	}
+	hosts := selector.Hosts()
+	require.Len(t, hosts, 1)
+	assert.Equal(t, "127.0.0.1:1", hosts[0].Addr)
+	assert.False(t, hosts[0].Healthy)
+	assert.Error(t, hosts[0].LastError)
+}
@@ -0,0 +1,23 @@
+// Package otelattr holds the OpenTelemetry attributes of the spans of the
+// driver. The otelmetric module, which cannot import it, keeps its own copy.
+package otelattr
+
+import (
+	"net"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Server splits a host:port address into server.address and server.port.
+func Server(addr string) []attribute.KeyValue {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return []attribute.KeyValue{attribute.String("server.address", addr)}
+	}
+	attrs := []attribute.KeyValue{attribute.String("server.address", host)}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attribute.Int("server.port", p))
+	}
+	return attrs
+}
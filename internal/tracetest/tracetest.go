@@ -0,0 +1,215 @@
+// Package tracetest records the spans of the driver for its tests, without
+// pulling the OpenTelemetry SDK into the module.
+package tracetest
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// Recorder is a trace.TracerProvider recording the spans of its tracers.
+// Every span is sampled.
+type Recorder struct {
+	embedded.TracerProvider
+
+	mu    sync.Mutex
+	ended []*Span
+}
+
+// NewRecorder returns a Recorder without spans.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &tracer{recorder: r}
+}
+
+// Ended returns the ended spans in the order they ended.
+func (r *Recorder) Ended() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Span(nil), r.ended...)
+}
+
+type tracer struct {
+	embedded.Tracer
+	recorder *Recorder
+}
+
+func (t *tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	var (
+		config = trace.NewSpanStartConfig(opts...)
+		parent = trace.SpanContextFromContext(ctx)
+		sc     = trace.SpanContextConfig{
+			TraceID:    parent.TraceID(),
+			TraceFlags: trace.FlagsSampled,
+		}
+	)
+	if !parent.IsValid() {
+		binary.LittleEndian.PutUint64(sc.TraceID[:8], rand.Uint64())
+		binary.LittleEndian.PutUint64(sc.TraceID[8:], rand.Uint64())
+	}
+	binary.LittleEndian.PutUint64(sc.SpanID[:], rand.Uint64()|1)
+	span := &Span{
+		recorder:    t.recorder,
+		name:        name,
+		spanContext: trace.NewSpanContext(sc),
+		parent:      parent,
+		kind:        config.SpanKind(),
+		start:       config.Timestamp(),
+		attributes:  config.Attributes(),
+	}
+	if span.start.IsZero() {
+		span.start = time.Now()
+	}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// Status is the status of a span.
+type Status struct {
+	Code        codes.Code
+	Description string
+}
+
+// Event is an event of a span.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []attribute.KeyValue
+}
+
+// Span is a recorded span.
+type Span struct {
+	embedded.Span
+	recorder    *Recorder
+	spanContext trace.SpanContext
+	parent      trace.SpanContext
+	kind        trace.SpanKind
+	start       time.Time
+
+	mu         sync.Mutex
+	name       string
+	end        time.Time
+	attributes []attribute.KeyValue
+	events     []Event
+	status     Status
+}
+
+func (s *Span) End(opts ...trace.SpanEndOption) {
+	config := trace.NewSpanEndConfig(opts...)
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = config.Timestamp()
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+	s.mu.Unlock()
+	s.recorder.mu.Lock()
+	s.recorder.ended = append(s.recorder.ended, s)
+	s.recorder.mu.Unlock()
+}
+
+func (s *Span) AddEvent(name string, opts ...trace.EventOption) {
+	config := trace.NewEventConfig(opts...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, Event{Name: name, Time: config.Timestamp(), Attributes: config.Attributes()})
+}
+
+func (s *Span) AddLink(trace.Link) {}
+
+func (s *Span) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.end.IsZero()
+}
+
+func (s *Span) RecordError(err error, opts ...trace.EventOption) {
+	if err != nil {
+		s.AddEvent("exception", append(opts, trace.WithAttributes(attribute.String("exception.message", err.Error())))...)
+	}
+}
+
+func (s *Span) SpanContext() trace.SpanContext {
+	return s.spanContext
+}
+
+func (s *Span) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = Status{Code: code, Description: description}
+}
+
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, kv...)
+}
+
+func (s *Span) TracerProvider() trace.TracerProvider {
+	return s.recorder
+}
+
+func (s *Span) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+// Parent returns the span context of the parent span, invalid for a root span.
+func (s *Span) Parent() trace.SpanContext {
+	return s.parent
+}
+
+func (s *Span) SpanKind() trace.SpanKind {
+	return s.kind
+}
+
+func (s *Span) StartTime() time.Time {
+	return s.start
+}
+
+func (s *Span) EndTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.end
+}
+
+// Attributes returns the attributes of the span, the last value of a key
+// set more than once wins.
+func (s *Span) Attributes() []attribute.KeyValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := attribute.NewSet(s.attributes...)
+	return set.ToSlice()
+}
+
+func (s *Span) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+func (s *Span) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
@@ -0,0 +1,177 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// queryRegistry holds the query IDs of the queries running on the connections
+// of a Conn, for RunningQueriesFilter.Own.
+type queryRegistry struct {
+	mu  sync.Mutex
+	ids map[string]int // a query ID reused by concurrent queries counts each of them
+}
+
+func (r *queryRegistry) add(queryID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ids == nil {
+		r.ids = make(map[string]int)
+	}
+	r.ids[queryID]++
+}
+
+func (r *queryRegistry) remove(queryID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ids[queryID]--; r.ids[queryID] <= 0 {
+		delete(r.ids, queryID)
+	}
+}
+
+func (r *queryRegistry) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.ids))
+	for id := range r.ids {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// trackQuery registers the query ID of ctx, if any, until the returned
+// release of the attempt running it on a connection.
+func (ch *clickhouse) trackQuery(ctx context.Context, release nativeTransportRelease) nativeTransportRelease {
+	queryID := queryOptions(ctx).queryID
+	if queryID == "" {
+		return release
+	}
+	ch.queries.add(queryID)
+	var once sync.Once
+	return func(conn nativeTransport, err error) {
+		once.Do(func() { ch.queries.remove(queryID) })
+		release(conn, err)
+	}
+}
+
+// killQueryStatement returns the KILL QUERY statement of queryID.
+func killQueryStatement(queryID string, opts driver.KillOptions) string {
+	var b strings.Builder
+	b.WriteString("KILL QUERY")
+	if opts.Cluster != "" {
+		// a literal, for macros such as '{cluster}'
+		b.WriteString(" ON CLUSTER '" + stringQuoteReplacer.Replace(opts.Cluster) + "'")
+	}
+	b.WriteString(" WHERE query_id = '" + stringQuoteReplacer.Replace(queryID) + "'")
+	switch opts.Mode {
+	case driver.KillSync:
+		b.WriteString(" SYNC")
+	case driver.KillTest:
+		b.WriteString(" TEST")
+	default:
+		b.WriteString(" ASYNC")
+	}
+	return b.String()
+}
+
+func (ch *clickhouse) KillQuery(ctx context.Context, queryID string, opts ...driver.KillOption) error {
+	if queryID == "" {
+		return &OpError{
+			Op:  "KillQuery",
+			Err: fmt.Errorf("empty query ID"),
+		}
+	}
+	var options driver.KillOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return ch.Exec(ctx, killQueryStatement(queryID, options))
+}
+
+// runningQuery is a row of system.processes, see driver.RunningQuery.
+type runningQuery struct {
+	QueryID         string  `ch:"query_id"`
+	InitialQueryID  string  `ch:"initial_query_id"`
+	IsInitialQuery  uint8   `ch:"is_initial_query"`
+	User            string  `ch:"user"`
+	InitialUser     string  `ch:"initial_user"`
+	Query           string  `ch:"query"`
+	Elapsed         float64 `ch:"elapsed"`
+	IsCancelled     uint8   `ch:"is_cancelled"`
+	ReadRows        uint64  `ch:"read_rows"`
+	ReadBytes       uint64  `ch:"read_bytes"`
+	TotalRowsApprox uint64  `ch:"total_rows_approx"`
+	WrittenRows     uint64  `ch:"written_rows"`
+	WrittenBytes    uint64  `ch:"written_bytes"`
+	MemoryUsage     int64   `ch:"memory_usage"`
+	PeakMemoryUsage int64   `ch:"peak_memory_usage"`
+}
+
+// runningQueriesStatement returns the system.processes query of filter and its
+// arguments. The query of RunningQueries itself, sent with selfID, is left out.
+func runningQueriesStatement(filter driver.RunningQueriesFilter, selfID string, own []string) (string, []any) {
+	var (
+		where = []string{"query_id != ?"}
+		args  = []any{selfID}
+	)
+	if filter.QueryID != "" {
+		where, args = append(where, "query_id = ?"), append(args, filter.QueryID)
+	}
+	if filter.User != "" {
+		where, args = append(where, "user = ?"), append(args, filter.User)
+	}
+	if filter.MinElapsed > 0 {
+		where, args = append(where, "elapsed >= ?"), append(args, filter.MinElapsed.Seconds())
+	}
+	if filter.Own {
+		where, args = append(where, "has(?, query_id)"), append(args, own)
+	}
+	return "SELECT query_id, initial_query_id, is_initial_query, user, initial_user, query, elapsed, is_cancelled, " +
+		"read_rows, read_bytes, total_rows_approx, written_rows, written_bytes, memory_usage, peak_memory_usage " +
+		"FROM system.processes WHERE " + strings.Join(where, " AND ") + " ORDER BY elapsed DESC", args
+}
+
+func (ch *clickhouse) RunningQueries(ctx context.Context, filter driver.RunningQueriesFilter) ([]driver.RunningQuery, error) {
+	var own []string
+	if filter.Own {
+		if own = ch.queries.list(); len(own) == 0 {
+			return nil, nil
+		}
+	}
+	selfID := uuid.NewString()
+	query, args := runningQueriesStatement(filter, selfID, own)
+	var processes []runningQuery
+	if err := ch.Select(Context(ctx, WithQueryID(selfID)), &processes, query, args...); err != nil {
+		return nil, err
+	}
+	queries := make([]driver.RunningQuery, 0, len(processes))
+	for _, p := range processes {
+		queries = append(queries, driver.RunningQuery{
+			QueryID:         p.QueryID,
+			InitialQueryID:  p.InitialQueryID,
+			IsInitialQuery:  p.IsInitialQuery == 1,
+			User:            p.User,
+			InitialUser:     p.InitialUser,
+			Query:           p.Query,
+			Elapsed:         time.Duration(p.Elapsed * float64(time.Second)),
+			IsCancelled:     p.IsCancelled == 1,
+			ReadRows:        p.ReadRows,
+			ReadBytes:       p.ReadBytes,
+			TotalRowsApprox: p.TotalRowsApprox,
+			WrittenRows:     p.WrittenRows,
+			WrittenBytes:    p.WrittenBytes,
+			MemoryUsage:     p.MemoryUsage,
+			PeakMemoryUsage: p.PeakMemoryUsage,
+		})
+	}
+	return queries, nil
+}
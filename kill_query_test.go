@@ -0,0 +1,78 @@
+package clickhouse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// runningTransport keeps the release of its last query and records its statements.
+type runningTransport struct {
+	*mockTransport
+	release    nativeTransportRelease
+	statements []string
+}
+
+func (r *runningTransport) query(ctx context.Context, release nativeTransportRelease, query string, args ...any) (*rows, error) {
+	r.release = release
+	return &rows{queryID: queryOptions(ctx).queryID}, nil
+}
+
+func (r *runningTransport) exec(ctx context.Context, query string, args ...any) error {
+	r.statements = append(r.statements, query)
+	return nil
+}
+
+func TestKillQueryStatement(t *testing.T) {
+	assert.Equal(t, "KILL QUERY WHERE query_id = 'q\\'1' ASYNC", killQueryStatement("q'1", driver.KillOptions{}))
+	assert.Equal(t, "KILL QUERY ON CLUSTER '{cluster}' WHERE query_id = 'q1' SYNC",
+		killQueryStatement("q1", driver.KillOptions{Mode: driver.KillSync, Cluster: "{cluster}"}))
+	assert.Equal(t, "KILL QUERY WHERE query_id = 'q1' TEST", killQueryStatement("q1", driver.KillOptions{Mode: driver.KillTest}))
+}
+
+func TestKillQuery(t *testing.T) {
+	transport := &runningTransport{mockTransport: newMockTransport(1)}
+	ch := openSessionConn(t, func(int) nativeTransport { return transport })
+	ctx := context.Background()
+
+	require.NoError(t, ch.KillQuery(ctx, "q1", driver.WithKillMode(driver.KillSync), driver.WithKillOnCluster("c1")))
+	assert.Equal(t, []string{"KILL QUERY ON CLUSTER 'c1' WHERE query_id = 'q1' SYNC"}, transport.statements)
+	assert.Error(t, ch.KillQuery(ctx, ""))
+}
+
+func TestRunningQueriesStatement(t *testing.T) {
+	query, args := runningQueriesStatement(driver.RunningQueriesFilter{
+		User:       "default",
+		MinElapsed: 1500 * time.Millisecond,
+		Own:        true,
+	}, "self", []string{"q1", "q2"})
+	assert.Contains(t, query, "FROM system.processes WHERE query_id != ? AND user = ? AND elapsed >= ? AND has(?, query_id)")
+	assert.Equal(t, []any{"self", "default", 1.5, []string{"q1", "q2"}}, args)
+}
+
+func TestQueryRegistry(t *testing.T) {
+	transport := &runningTransport{mockTransport: newMockTransport(1)}
+	ch := openSessionConn(t, func(int) nativeTransport { return transport })
+	ctx := context.Background()
+
+	r, err := ch.Query(Context(ctx, WithQueryID("q1")), "SELECT sleep(1)")
+	require.NoError(t, err)
+	assert.Equal(t, "q1", r.(driver.QueryIDProvider).QueryID())
+	assert.Equal(t, []string{"q1"}, ch.queries.list(), "the query runs until its connection is released")
+	transport.release(transport, nil)
+	assert.Empty(t, ch.queries.list())
+
+	queries, err := ch.RunningQueries(ctx, driver.RunningQueriesFilter{Own: true})
+	require.NoError(t, err)
+	assert.Empty(t, queries, "no query of the Conn is running")
+
+	r, err = ch.Query(ctx, "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{r.(driver.QueryIDProvider).QueryID()}, ch.queries.list(), "the generated query ID is registered")
+	transport.release(transport, nil)
+}
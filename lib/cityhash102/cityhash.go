@@ -381,3 +381,9 @@ func CityHash128(s []byte, length uint32) Uint128 {
 		return CityHash128WithSeed(s, length, Uint128{k0, k1})
 	}
 }
+
+// Hash128to64 hashes a 128-bit number to 64 bits, ClickHouse combines the
+// hashes of the arguments of cityHash64 with it.
+func Hash128to64(x Uint128) uint64 {
+	return hash128to64(x)
+}
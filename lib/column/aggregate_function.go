@@ -0,0 +1,278 @@
+package column
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ClickHouse/ch-go/proto"
+)
+
+// AggregateFunction holds intermediate aggregation states, e.g. the result of a
+// `uniqState(x)` expression or an AggregatingMergeTree column.
+//
+// The native format concatenates serialized states without any framing, so the
+// column needs to understand the state layout of the function to split rows.
+// States are exposed as opaque []byte per row and may be sent back unchanged on
+// insert. Some functions additionally support decoding into Go values, see
+// aggregateState implementations for details.
+type AggregateFunction struct {
+	chType   Type
+	name     string
+	function string
+	args     []string
+	state    aggregateState
+	data     []byte
+	offsets  []int
+}
+
+func (col *AggregateFunction) Reset() {
+	col.data = col.data[:0]
+	col.offsets = col.offsets[:0]
+}
+
+func (col *AggregateFunction) Name() string {
+	return col.name
+}
+
+func (col *AggregateFunction) parse(t Type) (_ Interface, err error) {
+	col.chType = t
+	params := splitTypeParams(t.params())
+	// versioned functions are prefixed with a numeric version: AggregateFunction(1, sumMap, ...)
+	if len(params) > 1 {
+		if _, err := strconv.ParseUint(params[0], 10, 64); err == nil {
+			params = params[1:]
+		}
+	}
+	if len(params) == 0 || params[0] == "" {
+		return nil, &UnsupportedColumnTypeError{t: t}
+	}
+	col.function, col.args = params[0], params[1:]
+	if col.state, err = newAggregateState(col.function, col.args); err != nil {
+		return nil, &Error{
+			ColumnType: string(t),
+			Err:        err,
+		}
+	}
+	return col, nil
+}
+
+// Function returns the aggregate function name including parameters, e.g. "quantiles(0.5, 0.9)".
+func (col *AggregateFunction) Function() string {
+	return col.function
+}
+
+// Arguments returns the aggregate function argument types.
+func (col *AggregateFunction) Arguments() []string {
+	return col.args
+}
+
+func (col *AggregateFunction) Type() Type {
+	return col.chType
+}
+
+func (col *AggregateFunction) ScanType() reflect.Type {
+	return scanTypeByte
+}
+
+func (col *AggregateFunction) Rows() int {
+	return len(col.offsets)
+}
+
+func (col *AggregateFunction) Row(i int, ptr bool) any {
+	value := col.rowBytes(i)
+	if ptr {
+		return &value
+	}
+	return value
+}
+
+func (col *AggregateFunction) ScanRow(dest any, row int) error {
+	switch d := dest.(type) {
+	case *[]byte:
+		*d = col.rowBytes(row)
+		return nil
+	case **[]byte:
+		*d = new([]byte)
+		**d = col.rowBytes(row)
+		return nil
+	case encoding.BinaryUnmarshaler:
+		return d.UnmarshalBinary(col.rowBytes(row))
+	}
+	ok, err := col.state.scan(col.rowState(row), dest)
+	if err != nil {
+		return &Error{
+			ColumnType: string(col.chType),
+			Err:        err,
+		}
+	}
+	if !ok {
+		return &ColumnConverterError{
+			Op:   "ScanRow",
+			To:   fmt.Sprintf("%T", dest),
+			From: string(col.chType),
+			Hint: "intermediate states can be scanned into []byte",
+		}
+	}
+	return nil
+}
+
+func (col *AggregateFunction) Append(v any) (nulls []uint8, err error) {
+	switch v := v.(type) {
+	case [][]byte:
+		nulls = make([]uint8, len(v))
+		for i := range v {
+			if err := col.appendState(v[i]); err != nil {
+				return nil, err
+			}
+		}
+	case []*[]byte:
+		nulls = make([]uint8, len(v))
+		for i := range v {
+			if v[i] == nil {
+				return nil, col.nilStateError("Append", v)
+			}
+			if err := col.appendState(*v[i]); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if s, ok := v.([]encoding.BinaryMarshaler); ok {
+			nulls = make([]uint8, len(s))
+			for i := range s {
+				if err := col.AppendRow(s[i]); err != nil {
+					return nil, err
+				}
+			}
+			return nulls, nil
+		}
+		return nil, &ColumnConverterError{
+			Op:   "Append",
+			To:   string(col.chType),
+			From: fmt.Sprintf("%T", v),
+		}
+	}
+	return
+}
+
+func (col *AggregateFunction) AppendRow(v any) error {
+	switch v := v.(type) {
+	case []byte:
+		return col.appendState(v)
+	case *[]byte:
+		if v == nil {
+			return col.nilStateError("AppendRow", v)
+		}
+		return col.appendState(*v)
+	case encoding.BinaryMarshaler:
+		state, err := v.MarshalBinary()
+		if err != nil {
+			return &ColumnConverterError{
+				Op:   "AppendRow",
+				To:   string(col.chType),
+				From: fmt.Sprintf("%T", v),
+				Hint: fmt.Sprintf("could not marshal state: %v", err),
+			}
+		}
+		return col.appendState(state)
+	case nil:
+		return col.nilStateError("AppendRow", v)
+	}
+	return &ColumnConverterError{
+		Op:   "AppendRow",
+		To:   string(col.chType),
+		From: fmt.Sprintf("%T", v),
+	}
+}
+
+func (col *AggregateFunction) Decode(reader *proto.Reader, rows int) error {
+	r := stateReader{reader: reader}
+	for i := 0; i < rows; i++ {
+		r.buf = col.data
+		if err := col.state.read(&r); err != nil {
+			return &Error{
+				ColumnType: string(col.chType),
+				Err:        fmt.Errorf("read state of row %d: %w", i, err),
+			}
+		}
+		col.data = r.buf
+		col.offsets = append(col.offsets, len(col.data))
+	}
+	return nil
+}
+
+func (col *AggregateFunction) Encode(buffer *proto.Buffer) {
+	buffer.PutRaw(col.data)
+}
+
+// appendState validates that state holds exactly one serialized state before appending it,
+// a malformed state would otherwise corrupt every following row of the block.
+func (col *AggregateFunction) appendState(state []byte) error {
+	r := stateReader{reader: proto.NewReader(bytes.NewReader(state))}
+	if err := col.state.read(&r); err != nil {
+		return &Error{
+			ColumnType: string(col.chType),
+			Err:        fmt.Errorf("invalid state: %w", err),
+		}
+	}
+	if len(r.buf) != len(state) {
+		return &Error{
+			ColumnType: string(col.chType),
+			Err:        fmt.Errorf("invalid state: %d trailing bytes", len(state)-len(r.buf)),
+		}
+	}
+	col.data = append(col.data, state...)
+	col.offsets = append(col.offsets, len(col.data))
+	return nil
+}
+
+func (col *AggregateFunction) nilStateError(op string, v any) error {
+	return &ColumnConverterError{
+		Op:   op,
+		To:   string(col.chType),
+		From: fmt.Sprintf("%T", v),
+		Hint: "nil is not a valid aggregate function state",
+	}
+}
+
+func (col *AggregateFunction) rowState(i int) []byte {
+	var start int
+	if i > 0 {
+		start = col.offsets[i-1]
+	}
+	return col.data[start:col.offsets[i]]
+}
+
+func (col *AggregateFunction) rowBytes(i int) []byte {
+	return bytes.Clone(col.rowState(i))
+}
+
+// splitTypeParams splits type parameters on top level commas, e.g. "quantiles(0.5, 0.9), Float64".
+func splitTypeParams(params string) []string {
+	var (
+		parts    []string
+		brackets int
+		quoted   bool
+		start    int
+	)
+	for i, r := range params {
+		switch {
+		case r == '\'' && (i == 0 || params[i-1] != '\\'):
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			brackets++
+		case r == ')':
+			brackets--
+		case r == ',' && brackets == 0:
+			parts = append(parts, strings.TrimSpace(params[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(params[start:]))
+}
+
+var _ Interface = (*AggregateFunction)(nil)
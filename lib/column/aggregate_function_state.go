@@ -0,0 +1,680 @@
+package column
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ClickHouse/ch-go/proto"
+)
+
+// aggregateState knows the serialization layout of an aggregate function state.
+type aggregateState interface {
+	// read consumes exactly one serialized state.
+	read(r *stateReader) error
+	// scan decodes a single serialized state into dest, reporting false if dest is not supported.
+	scan(state []byte, dest any) (bool, error)
+}
+
+// stateReader reads from the underlying reader while keeping a copy of every consumed byte,
+// which allows framing states without knowing their size upfront.
+type stateReader struct {
+	reader *proto.Reader
+	buf    []byte
+}
+
+func (r *stateReader) raw(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid size %d", n)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	b, err := r.reader.ReadRaw(n)
+	if err != nil {
+		return nil, err
+	}
+	r.buf = append(r.buf, b...)
+	return r.buf[len(r.buf)-n:], nil
+}
+
+func (r *stateReader) uvarint() (uint64, error) {
+	var (
+		x     uint64
+		shift uint
+	)
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = append(r.buf, b)
+		if b < 0x80 {
+			return x | uint64(b)<<shift, nil
+		}
+		x |= uint64(b&0x7f) << shift
+		shift += 7
+	}
+	return 0, errors.New("varint overflows a 64-bit integer")
+}
+
+func (r *stateReader) uint64() (uint64, error) {
+	b, err := r.raw(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// items reads n fixed size items, guarding against sizes that can't be valid for a single state.
+func (r *stateReader) items(n uint64, size int) error {
+	if n > math.MaxInt32/uint64(size) {
+		return fmt.Errorf("invalid number of items %d", n)
+	}
+	_, err := r.raw(int(n) * size)
+	return err
+}
+
+func newAggregateState(function string, args []string) (aggregateState, error) {
+	name := function
+	if idx := strings.Index(function, "("); idx > 0 {
+		name = strings.TrimSpace(function[:idx])
+	}
+	// the -If combinator doesn't change the state, only adds the condition argument
+	if base := strings.TrimSuffix(name, "If"); base != name && len(args) != 0 {
+		if _, err := newAggregateState(base, args[:len(args)-1]); err == nil {
+			name, args = base, args[:len(args)-1]
+		}
+	}
+	switch {
+	case name == "count":
+		return countState{}, nil
+	case name == "uniq" && len(args) > 1:
+		return uniqState{}, nil
+	case name == "uniqExact" && len(args) > 1:
+		// tuples of arguments are stored as 128-bit hashes
+		return uniqExactState{size: 16}, nil
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("unsupported aggregate function %s with %d arguments", function, len(args))
+	}
+	arg := args[0]
+	if strings.HasPrefix(arg, "Nullable(") {
+		return nil, fmt.Errorf("unsupported aggregate function %s over %s", function, arg)
+	}
+	switch name {
+	case "sum":
+		if state, ok := newSumState(arg); ok {
+			return state, nil
+		}
+	case "min", "max", "any", "anyLast":
+		if arg == "String" {
+			return singleValueState{arg: arg}, nil
+		}
+		if size := fixedTypeSize(arg); size != 0 {
+			return singleValueState{arg: arg, size: size}, nil
+		}
+	case "avg":
+		if state, ok := newAvgState(arg); ok {
+			return state, nil
+		}
+	case "uniq":
+		return uniqState{}, nil
+	case "uniqExact":
+		// non numeric values are stored as 128-bit hashes
+		size := fixedTypeSize(arg)
+		if size == 0 {
+			size = 16
+		}
+		return uniqExactState{size: size}, nil
+	case "groupBitmap":
+		if size := fixedTypeSize(arg); size != 0 && size <= 8 && isIntegerType(arg) {
+			return groupBitmapState{size: size}, nil
+		}
+	case "quantile", "quantiles", "median":
+		if size := fixedTypeSize(arg); size != 0 {
+			return quantileState{size: size}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported aggregate function %s", function)
+	}
+	return nil, fmt.Errorf("unsupported aggregate function %s over %s", function, arg)
+}
+
+// countState is a VarUInt counter. Scans into *uint64.
+type countState struct{}
+
+func (countState) read(r *stateReader) error {
+	_, err := r.uvarint()
+	return err
+}
+
+func (countState) scan(state []byte, dest any) (bool, error) {
+	count, _ := binary.Uvarint(state)
+	return assignState(dest, count), nil
+}
+
+// sumState holds the sum in the nearest 64-bit type of the argument, or the widest
+// type for big integers and decimals. Scans into *uint64, *int64 or *float64.
+type sumState struct {
+	size int
+	kind reflect.Kind
+}
+
+func newSumState(arg string) (sumState, bool) {
+	switch {
+	case isIntegerType(arg) && fixedTypeSize(arg) <= 8 && strings.HasPrefix(arg, "U"):
+		return sumState{size: 8, kind: reflect.Uint64}, true
+	case isIntegerType(arg) && fixedTypeSize(arg) <= 8:
+		return sumState{size: 8, kind: reflect.Int64}, true
+	case arg == "Float32", arg == "Float64", arg == "BFloat16":
+		return sumState{size: 8, kind: reflect.Float64}, true
+	case arg == "Int128", arg == "UInt128":
+		return sumState{size: 16}, true
+	case arg == "Int256", arg == "UInt256":
+		return sumState{size: 32}, true
+	case strings.HasPrefix(arg, "Decimal"):
+		if fixedTypeSize(arg) == 32 {
+			return sumState{size: 32}, true
+		}
+		return sumState{size: 16}, true
+	}
+	return sumState{}, false
+}
+
+func (s sumState) read(r *stateReader) error {
+	_, err := r.raw(s.size)
+	return err
+}
+
+func (s sumState) scan(state []byte, dest any) (bool, error) {
+	switch s.kind {
+	case reflect.Uint64:
+		return assignState(dest, binary.LittleEndian.Uint64(state)), nil
+	case reflect.Int64:
+		return assignState(dest, int64(binary.LittleEndian.Uint64(state))), nil
+	case reflect.Float64:
+		return assignState(dest, math.Float64frombits(binary.LittleEndian.Uint64(state))), nil
+	}
+	return false, nil
+}
+
+// singleValueState is used by min, max, any and anyLast: a flag followed by the value.
+// Strings are stored with their size including the terminating zero byte, a size of zero
+// (or -1 on older servers) means no value. Scans into *T or **T of the argument type.
+type singleValueState struct {
+	arg  string
+	size int
+}
+
+func (s singleValueState) read(r *stateReader) error {
+	if s.size == 0 {
+		b, err := r.raw(4)
+		if err != nil {
+			return err
+		}
+		if size := int32(binary.LittleEndian.Uint32(b)); size > 0 {
+			_, err = r.raw(int(size))
+		}
+		return err
+	}
+	flag, err := r.raw(1)
+	if err != nil {
+		return err
+	}
+	if flag[0] != 0 {
+		_, err = r.raw(s.size)
+	}
+	return err
+}
+
+func (s singleValueState) scan(state []byte, dest any) (bool, error) {
+	var value any
+	switch {
+	case s.size == 0:
+		if size := int32(binary.LittleEndian.Uint32(state)); size > 0 {
+			value = strings.TrimSuffix(string(state[4:4+size]), "\x00")
+		}
+	case state[0] != 0:
+		var ok bool
+		if value, ok = decodeNumber(s.arg, state[1:]); !ok {
+			return false, nil
+		}
+	}
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return false, nil
+	}
+	if value == nil {
+		// no value was aggregated, reset dest to nil or the zero value
+		elem := rv.Elem()
+		t := elem.Type()
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if !s.matches(t) {
+			return false, nil
+		}
+		elem.Set(reflect.Zero(elem.Type()))
+		return true, nil
+	}
+	return assignState(dest, value), nil
+}
+
+func (s singleValueState) matches(t reflect.Type) bool {
+	if s.size == 0 {
+		return t.Kind() == reflect.String
+	}
+	value, ok := decodeNumber(s.arg, make([]byte, s.size))
+	return ok && reflect.TypeOf(value) == t
+}
+
+// avgState holds the numerator followed by a VarUInt denominator. Scans into *float64
+// unless the argument is a decimal.
+type avgState struct {
+	size int
+	kind reflect.Kind
+}
+
+func newAvgState(arg string) (avgState, bool) {
+	switch {
+	case strings.HasPrefix(arg, "Decimal"):
+		if fixedTypeSize(arg) == 32 {
+			return avgState{size: 32}, true
+		}
+		return avgState{size: 16}, true
+	case isIntegerType(arg) && fixedTypeSize(arg) <= 8 && strings.HasPrefix(arg, "U"):
+		return avgState{size: 8, kind: reflect.Uint64}, true
+	case isIntegerType(arg) && fixedTypeSize(arg) <= 8:
+		return avgState{size: 8, kind: reflect.Int64}, true
+	case isNumericType(arg):
+		return avgState{size: 8, kind: reflect.Float64}, true
+	}
+	return avgState{}, false
+}
+
+func (s avgState) read(r *stateReader) error {
+	if _, err := r.raw(s.size); err != nil {
+		return err
+	}
+	_, err := r.uvarint()
+	return err
+}
+
+func (s avgState) scan(state []byte, dest any) (bool, error) {
+	var numerator float64
+	switch s.kind {
+	case reflect.Uint64:
+		numerator = float64(binary.LittleEndian.Uint64(state))
+	case reflect.Int64:
+		numerator = float64(int64(binary.LittleEndian.Uint64(state)))
+	case reflect.Float64:
+		numerator = math.Float64frombits(binary.LittleEndian.Uint64(state))
+	default:
+		return false, nil
+	}
+	denominator, _ := binary.Uvarint(state[s.size:])
+	return assignState(dest, numerator/float64(denominator)), nil
+}
+
+// uniqState is the UniquesHashSet used by uniq: skip degree, size and 32-bit hashes.
+type uniqState struct{}
+
+func (uniqState) read(r *stateReader) error {
+	if _, err := r.raw(1); err != nil {
+		return err
+	}
+	n, err := r.uvarint()
+	if err != nil {
+		return err
+	}
+	return r.items(n, 4)
+}
+
+func (uniqState) scan([]byte, any) (bool, error) {
+	return false, nil
+}
+
+// uniqExactState is a hash set of values or their 128-bit hashes. Scans the exact
+// cardinality into *uint64.
+type uniqExactState struct {
+	size int
+}
+
+func (s uniqExactState) read(r *stateReader) error {
+	n, err := r.uvarint()
+	if err != nil {
+		return err
+	}
+	return r.items(n, s.size)
+}
+
+func (s uniqExactState) scan(state []byte, dest any) (bool, error) {
+	n, _ := binary.Uvarint(state)
+	return assignState(dest, n), nil
+}
+
+// quantileState is the reservoir sampler used by quantile and quantiles.
+type quantileState struct {
+	size int
+}
+
+func (s quantileState) read(r *stateReader) error {
+	sampleCount, err := r.uint64()
+	if err != nil {
+		return err
+	}
+	totalValues, err := r.uint64()
+	if err != nil {
+		return err
+	}
+	return r.items(min(sampleCount, totalValues), s.size)
+}
+
+func (quantileState) scan([]byte, any) (bool, error) {
+	return false, nil
+}
+
+const (
+	groupBitmapSmall = 0
+	groupBitmapLarge = 1
+)
+
+// groupBitmapState holds up to 32 values as a plain set and a portable roaring bitmap
+// above that. Scans the cardinality into *uint64 or the values into *[]uint64.
+type groupBitmapState struct {
+	size int
+}
+
+func (s groupBitmapState) read(r *stateReader) error {
+	kind, err := r.raw(1)
+	if err != nil {
+		return err
+	}
+	n, err := r.uvarint()
+	if err != nil {
+		return err
+	}
+	switch kind[0] {
+	case groupBitmapSmall:
+		return r.items(n, s.size)
+	case groupBitmapLarge:
+		return r.items(n, 1)
+	}
+	return fmt.Errorf("unknown bitmap kind %d", kind[0])
+}
+
+func (s groupBitmapState) scan(state []byte, dest any) (bool, error) {
+	switch dest.(type) {
+	case *uint64, *[]uint64:
+	default:
+		return false, nil
+	}
+	values, err := s.values(state)
+	if err != nil {
+		return false, err
+	}
+	switch d := dest.(type) {
+	case *uint64:
+		*d = uint64(len(values))
+	case *[]uint64:
+		*d = values
+	}
+	return true, nil
+}
+
+func (s groupBitmapState) values(state []byte) ([]uint64, error) {
+	n, l := binary.Uvarint(state[1:])
+	data := state[1+l:]
+	if state[0] == groupBitmapSmall {
+		values := make([]uint64, n)
+		for i := range values {
+			var v uint64
+			for j := s.size - 1; j >= 0; j-- {
+				v = v<<8 | uint64(data[i*s.size+j])
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+	if s.size <= 4 {
+		values, _, err := decodeRoaring32(data, 0, nil)
+		return values, err
+	}
+	// Roaring64Map: number of 32-bit bitmaps followed by high bits and bitmap pairs
+	if len(data) < 8 {
+		return nil, errors.New("roaring64: short buffer")
+	}
+	var (
+		values []uint64
+		err    error
+		maps   = binary.LittleEndian.Uint64(data)
+	)
+	data = data[8:]
+	for i := uint64(0); i < maps; i++ {
+		if len(data) < 4 {
+			return nil, errors.New("roaring64: short buffer")
+		}
+		high := binary.LittleEndian.Uint32(data)
+		if values, data, err = decodeRoaring32(data[4:], uint64(high)<<32, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+const (
+	roaringSerialCookieNoRun = 12346
+	roaringSerialCookie      = 12347
+	roaringNoOffsetThreshold = 4
+	roaringMaxArraySize      = 4096
+)
+
+var errRoaringShortBuffer = errors.New("roaring: short buffer")
+
+// decodeRoaring32 decodes a bitmap in the portable roaring format, appending its values
+// combined with high to values, and returns the remaining data.
+func decodeRoaring32(data []byte, high uint64, values []uint64) ([]uint64, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, errRoaringShortBuffer
+	}
+	var (
+		runs    []byte
+		size    int
+		cookie  = binary.LittleEndian.Uint32(data)
+		offsets bool
+	)
+	data = data[4:]
+	switch {
+	case cookie&0xFFFF == roaringSerialCookie:
+		size = int(cookie>>16) + 1
+		if len(data) < (size+7)/8 {
+			return nil, nil, errRoaringShortBuffer
+		}
+		runs, data = data[:(size+7)/8], data[(size+7)/8:]
+		offsets = size >= roaringNoOffsetThreshold
+	case cookie == roaringSerialCookieNoRun:
+		if len(data) < 4 {
+			return nil, nil, errRoaringShortBuffer
+		}
+		size, data, offsets = int(binary.LittleEndian.Uint32(data)), data[4:], true
+	default:
+		return nil, nil, fmt.Errorf("roaring: unknown cookie %d", cookie)
+	}
+	if len(data) < size*4 {
+		return nil, nil, errRoaringShortBuffer
+	}
+	header := data[:size*4]
+	data = data[size*4:]
+	if offsets {
+		if len(data) < size*4 {
+			return nil, nil, errRoaringShortBuffer
+		}
+		data = data[size*4:]
+	}
+	for i := 0; i < size; i++ {
+		var (
+			key         = high | uint64(binary.LittleEndian.Uint16(header[i*4:]))<<16
+			cardinality = int(binary.LittleEndian.Uint16(header[i*4+2:])) + 1
+		)
+		switch {
+		case runs != nil && runs[i/8]&(1<<(i%8)) != 0:
+			if len(data) < 2 {
+				return nil, nil, errRoaringShortBuffer
+			}
+			n := int(binary.LittleEndian.Uint16(data))
+			if data = data[2:]; len(data) < n*4 {
+				return nil, nil, errRoaringShortBuffer
+			}
+			for j := 0; j < n; j++ {
+				start := uint64(binary.LittleEndian.Uint16(data[j*4:]))
+				length := uint64(binary.LittleEndian.Uint16(data[j*4+2:]))
+				for v := start; v <= start+length; v++ {
+					values = append(values, key|v)
+				}
+			}
+			data = data[n*4:]
+		case cardinality > roaringMaxArraySize:
+			if len(data) < 8192 {
+				return nil, nil, errRoaringShortBuffer
+			}
+			for j := 0; j < 1024; j++ {
+				word := binary.LittleEndian.Uint64(data[j*8:])
+				for word != 0 {
+					values = append(values, key|uint64(j*64+bits.TrailingZeros64(word)))
+					word &= word - 1
+				}
+			}
+			data = data[8192:]
+		default:
+			if len(data) < cardinality*2 {
+				return nil, nil, errRoaringShortBuffer
+			}
+			for j := 0; j < cardinality; j++ {
+				values = append(values, key|uint64(binary.LittleEndian.Uint16(data[j*2:])))
+			}
+			data = data[cardinality*2:]
+		}
+	}
+	return values, data, nil
+}
+
+// assignState sets *dest or **dest to value if the types match exactly.
+func assignState(dest any, value any) bool {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return false
+	}
+	v := reflect.ValueOf(value)
+	switch elem := rv.Elem(); {
+	case elem.Type() == v.Type():
+		elem.Set(v)
+	case elem.Kind() == reflect.Pointer && elem.Type().Elem() == v.Type():
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		elem.Set(ptr)
+	default:
+		return false
+	}
+	return true
+}
+
+// decodeNumber decodes a little endian value of a native integer or float type.
+func decodeNumber(t string, b []byte) (any, bool) {
+	switch t {
+	case "UInt8":
+		return b[0], true
+	case "UInt16":
+		return binary.LittleEndian.Uint16(b), true
+	case "UInt32":
+		return binary.LittleEndian.Uint32(b), true
+	case "UInt64":
+		return binary.LittleEndian.Uint64(b), true
+	case "Int8":
+		return int8(b[0]), true
+	case "Int16":
+		return int16(binary.LittleEndian.Uint16(b)), true
+	case "Int32":
+		return int32(binary.LittleEndian.Uint32(b)), true
+	case "Int64":
+		return int64(binary.LittleEndian.Uint64(b)), true
+	case "Float32":
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), true
+	case "Float64":
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), true
+	}
+	return nil, false
+}
+
+func isIntegerType(t string) bool {
+	switch t {
+	case "UInt8", "UInt16", "UInt32", "UInt64", "UInt128", "UInt256",
+		"Int8", "Int16", "Int32", "Int64", "Int128", "Int256":
+		return true
+	}
+	return false
+}
+
+func isNumericType(t string) bool {
+	switch t {
+	case "Float32", "Float64", "BFloat16":
+		return true
+	}
+	return isIntegerType(t)
+}
+
+// fixedTypeSize returns the in memory size of fixed size types, or 0 if the size is not fixed.
+func fixedTypeSize(t string) int {
+	switch t {
+	case "UInt8", "Int8", "Bool", "Enum8":
+		return 1
+	case "UInt16", "Int16", "Date", "BFloat16":
+		return 2
+	case "UInt32", "Int32", "Float32", "Date32", "IPv4":
+		return 4
+	case "UInt64", "Int64", "Float64":
+		return 8
+	case "UInt128", "Int128", "UUID", "IPv6":
+		return 16
+	case "UInt256", "Int256":
+		return 32
+	}
+	switch {
+	case strings.HasPrefix(t, "Enum8("):
+		return 1
+	case strings.HasPrefix(t, "Enum16("):
+		return 2
+	case strings.HasPrefix(t, "DateTime64"):
+		return 8
+	case strings.HasPrefix(t, "DateTime"):
+		return 4
+	case strings.HasPrefix(t, "Decimal32"):
+		return 4
+	case strings.HasPrefix(t, "Decimal64"):
+		return 8
+	case strings.HasPrefix(t, "Decimal128"):
+		return 16
+	case strings.HasPrefix(t, "Decimal256"):
+		return 32
+	case strings.HasPrefix(t, "Decimal("):
+		precision, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(Type(t).params(), ",", 2)[0]))
+		switch {
+		case err != nil:
+			return 0
+		case precision <= 9:
+			return 4
+		case precision <= 18:
+			return 8
+		case precision <= 38:
+			return 16
+		default:
+			return 32
+		}
+	}
+	return 0
+}
@@ -0,0 +1,181 @@
+package column
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeAggregateFunction(t *testing.T, chType Type, rows int, states ...[]byte) *AggregateFunction {
+	t.Helper()
+	col, err := chType.Column("agg", nil)
+	require.NoError(t, err)
+	reader := proto.NewReader(bytes.NewReader(bytes.Join(states, nil)))
+	require.NoError(t, col.Decode(reader, rows))
+	require.Equal(t, rows, col.Rows())
+	return col.(*AggregateFunction)
+}
+
+func TestAggregateFunctionParse(t *testing.T) {
+	tests := []struct {
+		chType   Type
+		function string
+		args     []string
+	}{
+		{chType: "AggregateFunction(count)", function: "count", args: []string{}},
+		{chType: "AggregateFunction(uniq, UInt64)", function: "uniq", args: []string{"UInt64"}},
+		{chType: "AggregateFunction(quantiles(0.5, 0.9), Float64)", function: "quantiles(0.5, 0.9)", args: []string{"Float64"}},
+		{chType: "AggregateFunction(sumIf, UInt32, UInt8)", function: "sumIf", args: []string{"UInt32", "UInt8"}},
+		{chType: "AggregateFunction(1, avg, Decimal(18, 4))", function: "avg", args: []string{"Decimal(18, 4)"}},
+		{chType: "AggregateFunction(uniqExact, String, DateTime('UTC'))", function: "uniqExact", args: []string{"String", "DateTime('UTC')"}},
+	}
+	for _, test := range tests {
+		t.Run(string(test.chType), func(t *testing.T) {
+			col, err := test.chType.Column("agg", nil)
+			require.NoError(t, err)
+			agg := col.(*AggregateFunction)
+			assert.Equal(t, test.chType, agg.Type())
+			assert.Equal(t, test.function, agg.Function())
+			assert.Equal(t, test.args, agg.Arguments())
+		})
+	}
+
+	for _, chType := range []Type{
+		"AggregateFunction(groupArray, String)",
+		"AggregateFunction(sum, Nullable(UInt64))",
+		"AggregateFunction(min, Array(UInt8))",
+	} {
+		_, err := chType.Column("agg", nil)
+		assert.Error(t, err, chType)
+	}
+}
+
+func TestAggregateFunctionRoundTrip(t *testing.T) {
+	states := [][]byte{
+		{0x01, 0x02, 0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00},
+		{0x00, 0x00},
+		{0x00, 0x81, 0x01}, // 129 hashes, truncated below
+	}
+	hashes := make([]byte, 129*4)
+	states[2] = append(states[2], hashes...)
+	col := decodeAggregateFunction(t, "AggregateFunction(uniq, UInt64)", 3, states...)
+	for i, state := range states {
+		var scanned []byte
+		require.NoError(t, col.ScanRow(&scanned, i))
+		assert.Equal(t, state, scanned)
+		assert.Equal(t, state, col.Row(i, false))
+	}
+
+	var buffer proto.Buffer
+	col.Encode(&buffer)
+	assert.Equal(t, bytes.Join(states, nil), buffer.Buf)
+
+	col.Reset()
+	require.NoError(t, col.AppendRow(states[1]))
+	_, err := col.Append([][]byte{states[0], states[2]})
+	require.NoError(t, err)
+	assert.Equal(t, 3, col.Rows())
+	assert.Equal(t, states[0], col.Row(1, false))
+
+	// states are validated on append to avoid corrupting the block
+	assert.Error(t, col.AppendRow([]byte{0x00, 0x05}))
+	assert.Error(t, col.AppendRow(append(bytes.Clone(states[1]), 0x00)))
+	assert.Error(t, col.AppendRow(nil))
+	assert.Equal(t, 3, col.Rows())
+}
+
+func TestAggregateFunctionScanTyped(t *testing.T) {
+	t.Run("count", func(t *testing.T) {
+		col := decodeAggregateFunction(t, "AggregateFunction(count)", 1, binary.AppendUvarint(nil, 300))
+		var count uint64
+		require.NoError(t, col.ScanRow(&count, 0))
+		assert.Equal(t, uint64(300), count)
+		var wrong int32
+		assert.Error(t, col.ScanRow(&wrong, 0))
+	})
+	t.Run("sum", func(t *testing.T) {
+		col := decodeAggregateFunction(t, "AggregateFunction(sum, Int32)", 1, binary.LittleEndian.AppendUint64(nil, uint64(math.MaxUint64)))
+		var sum int64
+		require.NoError(t, col.ScanRow(&sum, 0))
+		assert.Equal(t, int64(-1), sum)
+	})
+	t.Run("avg", func(t *testing.T) {
+		state := binary.LittleEndian.AppendUint64(nil, math.Float64bits(10))
+		col := decodeAggregateFunction(t, "AggregateFunction(avg, Float32)", 1, binary.AppendUvarint(state, 4))
+		var avg float64
+		require.NoError(t, col.ScanRow(&avg, 0))
+		assert.Equal(t, 2.5, avg)
+	})
+	t.Run("max", func(t *testing.T) {
+		col := decodeAggregateFunction(t, "AggregateFunction(max, UInt16)", 2, []byte{0x01, 0x2a, 0x00}, []byte{0x00})
+		var (
+			value uint16
+			ptr   *uint16
+		)
+		require.NoError(t, col.ScanRow(&value, 0))
+		assert.Equal(t, uint16(42), value)
+		require.NoError(t, col.ScanRow(&ptr, 1))
+		assert.Nil(t, ptr)
+	})
+	t.Run("min string", func(t *testing.T) {
+		col := decodeAggregateFunction(t, "AggregateFunction(min, String)", 2,
+			[]byte{0x03, 0x00, 0x00, 0x00, 'h', 'i', 0x00},
+			[]byte{0xff, 0xff, 0xff, 0xff},
+		)
+		var value string
+		require.NoError(t, col.ScanRow(&value, 0))
+		assert.Equal(t, "hi", value)
+		require.NoError(t, col.ScanRow(&value, 1))
+		assert.Equal(t, "", value)
+	})
+	t.Run("uniqExact", func(t *testing.T) {
+		col := decodeAggregateFunction(t, "AggregateFunction(uniqExact, String)", 1, append([]byte{0x02}, make([]byte, 32)...))
+		var count uint64
+		require.NoError(t, col.ScanRow(&count, 0))
+		assert.Equal(t, uint64(2), count)
+	})
+	t.Run("groupBitmap small", func(t *testing.T) {
+		col := decodeAggregateFunction(t, "AggregateFunction(groupBitmap, UInt32)", 1, []byte{0x00, 0x02, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00})
+		var values []uint64
+		require.NoError(t, col.ScanRow(&values, 0))
+		assert.Equal(t, []uint64{1, 256}, values)
+	})
+	t.Run("groupBitmap roaring", func(t *testing.T) {
+		// portable format without run containers: one array container with key 1
+		bitmap := binary.LittleEndian.AppendUint32(nil, roaringSerialCookieNoRun)
+		bitmap = binary.LittleEndian.AppendUint32(bitmap, 1)
+		bitmap = binary.LittleEndian.AppendUint16(bitmap, 1)
+		bitmap = binary.LittleEndian.AppendUint16(bitmap, 2)
+		bitmap = binary.LittleEndian.AppendUint32(bitmap, 16)
+		for _, v := range []uint16{3, 5, 7} {
+			bitmap = binary.LittleEndian.AppendUint16(bitmap, v)
+		}
+		state := binary.AppendUvarint([]byte{groupBitmapLarge}, uint64(len(bitmap)))
+		col := decodeAggregateFunction(t, "AggregateFunction(groupBitmap, UInt32)", 1, append(state, bitmap...))
+		var (
+			values []uint64
+			count  uint64
+		)
+		require.NoError(t, col.ScanRow(&values, 0))
+		assert.Equal(t, []uint64{1<<16 | 3, 1<<16 | 5, 1<<16 | 7}, values)
+		require.NoError(t, col.ScanRow(&count, 0))
+		assert.Equal(t, uint64(3), count)
+	})
+	t.Run("quantiles", func(t *testing.T) {
+		state := binary.LittleEndian.AppendUint64(nil, 8192)
+		state = binary.LittleEndian.AppendUint64(state, 2)
+		state = binary.LittleEndian.AppendUint64(state, math.Float64bits(1))
+		state = binary.LittleEndian.AppendUint64(state, math.Float64bits(2))
+		col := decodeAggregateFunction(t, "AggregateFunction(quantiles(0.5, 0.9), Float64)", 1, state)
+		var raw []byte
+		require.NoError(t, col.ScanRow(&raw, 0))
+		assert.Equal(t, state, raw)
+		var value float64
+		assert.Error(t, col.ScanRow(&value, 0))
+	})
+}
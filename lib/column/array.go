@@ -77,6 +77,16 @@ func (col *Array) Base() Interface {
 	return col.values
 }
 
+// Offsets returns the end offsets into the next level for each nesting level of
+// the array, outermost first. The innermost level offsets point into Base.
+func (col *Array) Offsets() [][]uint64 {
+	offsets := make([][]uint64, len(col.offsets))
+	for i, o := range col.offsets {
+		offsets[i] = o.values.Data()
+	}
+	return offsets
+}
+
 func (col *Array) Type() Type {
 	return col.chType
 }
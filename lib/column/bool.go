@@ -171,4 +171,10 @@ func (col *Bool) row(i int) bool {
 	return col.col.Row(i)
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *Bool) Data() []bool {
+	return col.col
+}
+
 var _ Interface = (*Bool)(nil)
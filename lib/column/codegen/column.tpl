@@ -161,6 +161,8 @@ func (t Type) Column(name string, sc *ServerContext) (Interface, error) {
 		return (&LowCardinality{name: name}).parse(t, sc)
 	case strings.HasPrefix(string(t), "SimpleAggregateFunction"):
 		return (&SimpleAggregateFunction{name: name}).parse(t, sc)
+	case strings.HasPrefix(string(t), "AggregateFunction("):
+		return (&AggregateFunction{name: name}).parse(t)
 	case strings.HasPrefix(string(t), "Enum8") || strings.HasPrefix(string(t), "Enum16"):
 		return Enum(t, name)
 	case strings.HasPrefix(string(t), "DateTime64"):
@@ -241,6 +243,23 @@ func (col *{{ .ChType }}) Reset() {
     col.col.Reset()
 }
 
+{{ if eq .ChType "BFloat16" -}}
+// Data returns a copy of the values of the column, widened to float32.
+func (col *{{ .ChType }}) Data() []{{ .GoType }} {
+	values := make([]float32, col.col.Rows())
+	for i := range values {
+		values[i] = col.col.Row(i)
+	}
+	return values
+}
+{{- else }}
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *{{ .ChType }}) Data() []{{ .GoType }} {
+	return col.col
+}
+{{- end }}
+
 func (col *{{ .ChType }}) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -178,6 +178,8 @@ func (t Type) Column(name string, sc *ServerContext) (Interface, error) {
 		return (&LowCardinality{name: name}).parse(t, sc)
 	case strings.HasPrefix(string(t), "SimpleAggregateFunction"):
 		return (&SimpleAggregateFunction{name: name}).parse(t, sc)
+	case strings.HasPrefix(string(t), "AggregateFunction("):
+		return (&AggregateFunction{name: name}).parse(t)
 	case strings.HasPrefix(string(t), "Enum8") || strings.HasPrefix(string(t), "Enum16"):
 		return Enum(t, name)
 	case strings.HasPrefix(string(t), "DateTime64"):
@@ -310,6 +312,15 @@ func (col *BFloat16) Reset() {
 	col.col.Reset()
 }
 
+// Data returns a copy of the values of the column, widened to float32.
+func (col *BFloat16) Data() []float32 {
+	values := make([]float32, col.col.Rows())
+	for i := range values {
+		values[i] = col.col.Row(i)
+	}
+	return values
+}
+
 func (col *BFloat16) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -451,6 +462,12 @@ func (col *Float32) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *Float32) Data() []float32 {
+	return col.col
+}
+
 func (col *Float32) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -592,6 +609,12 @@ func (col *Float64) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *Float64) Data() []float64 {
+	return col.col
+}
+
 func (col *Float64) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -762,6 +785,12 @@ func (col *Int8) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *Int8) Data() []int8 {
+	return col.col
+}
+
 func (col *Int8) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -942,6 +971,12 @@ func (col *Int16) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *Int16) Data() []int16 {
+	return col.col
+}
+
 func (col *Int16) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -1112,6 +1147,12 @@ func (col *Int32) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *Int32) Data() []int32 {
+	return col.col
+}
+
 func (col *Int32) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -1282,6 +1323,12 @@ func (col *Int64) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *Int64) Data() []int64 {
+	return col.col
+}
+
 func (col *Int64) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -1458,6 +1505,12 @@ func (col *UInt8) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *UInt8) Data() []uint8 {
+	return col.col
+}
+
 func (col *UInt8) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -1612,6 +1665,12 @@ func (col *UInt16) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *UInt16) Data() []uint16 {
+	return col.col
+}
+
 func (col *UInt16) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -1753,6 +1812,12 @@ func (col *UInt32) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *UInt32) Data() []uint32 {
+	return col.col
+}
+
 func (col *UInt32) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -1894,6 +1959,12 @@ func (col *UInt64) Reset() {
 	col.col.Reset()
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *UInt64) Data() []uint64 {
+	return col.col
+}
+
 func (col *UInt64) ScanRow(dest any, row int) error {
 	value := col.col.Row(row)
 	switch d := dest.(type) {
@@ -261,4 +261,13 @@ func (col *Date) row(i int) time.Time {
 	return t
 }
 
+// Data returns the values of the column.
+func (col *Date) Data() []time.Time {
+	values := make([]time.Time, col.Rows())
+	for i := range values {
+		values[i] = col.row(i)
+	}
+	return values
+}
+
 var _ Interface = (*Date)(nil)
@@ -238,4 +238,13 @@ func (col *Date32) row(i int) time.Time {
 	return t
 }
 
+// Data returns the values of the column.
+func (col *Date32) Data() []time.Time {
+	values := make([]time.Time, col.Rows())
+	for i := range values {
+		values[i] = col.row(i)
+	}
+	return values
+}
+
 var _ Interface = (*Date32)(nil)
@@ -285,6 +285,15 @@ func (col *DateTime) row(i int) time.Time {
 	return v
 }
 
+// Data returns the values of the column.
+func (col *DateTime) Data() []time.Time {
+	values := make([]time.Time, col.Rows())
+	for i := range values {
+		values[i] = col.row(i)
+	}
+	return values
+}
+
 func (col *DateTime) parseDateTime(value string) (tv time.Time, err error) {
 	if tv, err = time.Parse(defaultDateTimeFormatWithZone, value); err == nil {
 		return tv, nil
@@ -286,6 +286,15 @@ func (col *DateTime64) row(i int) time.Time {
 	return time
 }
 
+// Data returns the values of the column.
+func (col *DateTime64) Data() []time.Time {
+	values := make([]time.Time, col.Rows())
+	for i := range values {
+		values[i] = col.row(i)
+	}
+	return values
+}
+
 func (col *DateTime64) timeToInt64(t time.Time) int64 {
 	var timestamp int64
 	if !t.IsZero() {
@@ -80,6 +80,11 @@ func (col *LowCardinality) parse(t Type, sc *ServerContext) (_ *LowCardinality,
 	return col, nil
 }
 
+// Base returns the dictionary column holding the distinct values.
+func (col *LowCardinality) Base() Interface {
+	return col.index
+}
+
 func (col *LowCardinality) Type() Type {
 	return col.chType
 }
@@ -80,6 +80,22 @@ func (col *Map) parse(t Type, sc *ServerContext) (_ Interface, err error) {
 	}
 }
 
+// Keys returns the column holding the keys of all maps, see Offsets.
+func (col *Map) Keys() Interface {
+	return col.keys
+}
+
+// Values returns the column holding the values of all maps, see Offsets.
+func (col *Map) Values() Interface {
+	return col.values
+}
+
+// Offsets returns the end offset into Keys and Values for each row, sharing memory
+// with the column.
+func (col *Map) Offsets() []int64 {
+	return col.offsets.Data()
+}
+
 func (col *Map) Type() Type {
 	return col.chType
 }
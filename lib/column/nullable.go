@@ -47,6 +47,12 @@ func (col *Nullable) Base() Interface {
 	return col.base
 }
 
+// Nulls returns the null map of the column where 1 marks a NULL row, sharing memory
+// with the column: the slice is only valid until the column is reset or decoded into.
+func (col *Nullable) Nulls() []uint8 {
+	return col.nulls
+}
+
 func (col *Nullable) Type() Type {
 	return "Nullable(" + col.base.Type() + ")"
 }
@@ -0,0 +1,156 @@
+package column
+
+import (
+	"fmt"
+
+	"github.com/ClickHouse/ch-go/proto"
+)
+
+// SerializationKind is the custom serialization kind the server used to encode a column.
+type SerializationKind uint8
+
+const (
+	SerializationDefault SerializationKind = 0
+	// SerializationSparse only sends non-default values along with their offsets.
+	SerializationSparse SerializationKind = 1
+)
+
+// sparseEndOfGranule flags the last group of the offsets stream, holding the number of trailing defaults.
+const sparseEndOfGranule = uint64(1) << 62
+
+// SerializationInfo describes the serialization kinds of a column, Tuple elements have their own kinds.
+type SerializationInfo struct {
+	Kind     SerializationKind
+	Elements []*SerializationInfo
+}
+
+// ReadSerializationInfo reads the serialization kinds of col sent when a column has custom serialization.
+func ReadSerializationInfo(reader *proto.Reader, col Interface) (*SerializationInfo, error) {
+	kind, err := reader.UInt8()
+	if err != nil {
+		return nil, err
+	}
+	info := &SerializationInfo{Kind: SerializationKind(kind)}
+	switch info.Kind {
+	case SerializationDefault, SerializationSparse:
+	default:
+		return nil, fmt.Errorf("unknown serialization kind %d", kind)
+	}
+	if tuple, ok := col.(*Tuple); ok {
+		info.Elements = make([]*SerializationInfo, len(tuple.columns))
+		for i, c := range tuple.columns {
+			if info.Elements[i], err = ReadSerializationInfo(reader, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return info, nil
+}
+
+// DecodeWithSerialization decodes rows of col including the state prefix according to info.
+func DecodeWithSerialization(reader *proto.Reader, col Interface, rows int, info *SerializationInfo, sc *ServerContext) error {
+	if info.Kind == SerializationSparse {
+		return decodeSparse(reader, col, rows, sc)
+	}
+	if serialize, ok := col.(CustomSerialization); ok {
+		if err := serialize.ReadStatePrefix(reader); err != nil {
+			return err
+		}
+	}
+	return decodeElements(reader, col, rows, info, sc)
+}
+
+func decodeElements(reader *proto.Reader, col Interface, rows int, info *SerializationInfo, sc *ServerContext) error {
+	if info.Kind == SerializationSparse {
+		return decodeSparse(reader, col, rows, sc)
+	}
+	if tuple, ok := col.(*Tuple); ok && len(info.Elements) == len(tuple.columns) {
+		for i, c := range tuple.columns {
+			if err := decodeElements(reader, c, rows, info.Elements[i], sc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return col.Decode(reader, rows)
+}
+
+// decodeSparse reads the offsets of non-default rows followed by their values and
+// materializes them into col, filling all other rows with the default value.
+func decodeSparse(reader *proto.Reader, col Interface, rows int, sc *ServerContext) error {
+	values, err := col.Type().Column(col.Name(), sc)
+	if err != nil {
+		return err
+	}
+	if serialize, ok := values.(CustomSerialization); ok {
+		if err := serialize.ReadStatePrefix(reader); err != nil {
+			return err
+		}
+	}
+	var (
+		offsets []int
+		total   int
+	)
+	for {
+		group, err := reader.UVarInt()
+		if err != nil {
+			return err
+		}
+		end := group&sparseEndOfGranule != 0
+		total += int(group &^ sparseEndOfGranule)
+		if total > rows {
+			return fmt.Errorf("sparse offsets exceed %d rows", rows)
+		}
+		if end {
+			break
+		}
+		offsets = append(offsets, total)
+		total++
+	}
+	if total != rows {
+		return fmt.Errorf("sparse offsets cover %d rows, expected %d", total, rows)
+	}
+	if len(offsets) != 0 {
+		if err := values.Decode(reader, len(offsets)); err != nil {
+			return err
+		}
+	}
+	defaultValue, err := sparseDefault(col, sc)
+	if err != nil {
+		return err
+	}
+	for row, next := 0, 0; row < rows; row++ {
+		value := defaultValue
+		if next < len(offsets) && offsets[next] == row {
+			value = values.Row(next, false)
+			next++
+		}
+		if err := col.AppendRow(value); err != nil {
+			return fmt.Errorf("materialize sparse row %d: %w", row, err)
+		}
+	}
+	return nil
+}
+
+// sparseDefault returns the value sparse serialization omits: zero, or NULL for Nullable columns.
+func sparseDefault(col Interface, sc *ServerContext) (any, error) {
+	if _, ok := col.(*Nullable); ok {
+		return nil, nil
+	}
+	def, err := col.Type().Column(col.Name(), sc)
+	if err != nil {
+		return nil, err
+	}
+	if err := def.Decode(proto.NewReader(zeroReader{}), 1); err != nil {
+		return nil, err
+	}
+	return def.Row(0, false), nil
+}
+
+// zeroReader is an endless stream of zero bytes, the binary representation of a single default value.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
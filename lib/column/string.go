@@ -228,4 +228,14 @@ func (col *String) Encode(buffer *proto.Buffer) {
 	col.col.EncodeColumn(buffer)
 }
 
+// Data returns the values of the column.
+func (col *String) Data() []string {
+	values := make([]string, 0, col.col.Rows())
+	col.col.ForEach(func(_ int, s string) error {
+		values = append(values, s)
+		return nil
+	})
+	return values
+}
+
 var _ Interface = (*String)(nil)
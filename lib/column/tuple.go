@@ -98,6 +98,11 @@ func (col *Tuple) parse(t Type, sc *ServerContext) (_ Interface, err error) {
 	}
 }
 
+// Columns returns the element columns of the tuple, named elements keep their name.
+func (col *Tuple) Columns() []Interface {
+	return col.columns
+}
+
 func (col *Tuple) Type() Type {
 	return col.chType
 }
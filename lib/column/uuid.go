@@ -204,4 +204,10 @@ func (col *UUID) row(i int) (uuid uuid.UUID) {
 	return col.col.Row(i)
 }
 
+// Data returns the values of the column, sharing memory with the column: the
+// slice is only valid until the column is reset or decoded into.
+func (col *UUID) Data() []uuid.UUID {
+	return col.col
+}
+
 var _ Interface = (*UUID)(nil)
@@ -29,11 +29,73 @@ type (
 		Scale uint8
 	}
 
+	// TableStatus is the replication status of a table on one server.
+	TableStatus struct {
+		Database      string
+		Table         string
+		IsReplicated  bool
+		AbsoluteDelay time.Duration // replication delay, zero for tables that aren't replicated
+		IsReadonly    bool
+	}
+
+	// SessionOptions configures a Session.
+	SessionOptions struct {
+		// ID is the session_id of an HTTP session, a random UUID when empty.
+		ID string
+		// Timeout is the session_timeout of an HTTP session, the server
+		// default (60s) when zero.
+		Timeout time.Duration
+		// KeepAlive is the interval of the pings keeping an idle session and
+		// its connection alive, half of Timeout (or 30s) when zero. A negative
+		// interval disables them.
+		KeepAlive time.Duration
+	}
+
+	// RunningQuery is a query running on the server, from system.processes.
+	RunningQuery struct {
+		QueryID         string
+		InitialQueryID  string // query ID of the distributed query this one is part of
+		IsInitialQuery  bool
+		User            string
+		InitialUser     string
+		Query           string
+		Elapsed         time.Duration
+		IsCancelled     bool
+		ReadRows        uint64
+		ReadBytes       uint64
+		TotalRowsApprox uint64
+		WrittenRows     uint64
+		WrittenBytes    uint64
+		MemoryUsage     int64
+		PeakMemoryUsage int64
+	}
+
+	// RunningQueriesFilter selects the queries returned by Conn.RunningQueries.
+	// Zero fields don't filter.
+	RunningQueriesFilter struct {
+		QueryID    string
+		User       string
+		MinElapsed time.Duration
+		// Own keeps the queries of this Conn only, those started with a query
+		// ID of WithQueryID or generated by the driver.
+		Own bool
+	}
+
 	Stats struct {
 		MaxOpenConns int
 		MaxIdleConns int
-		Open         int
+		Open         int // connections in use
 		Idle         int
+
+		AcquireCount      int64         // connections handed out, from the pool or newly dialed
+		WaitCount         int64         // acquisitions that waited for MaxOpenConns to free up
+		WaitDuration      time.Duration // total time spent waiting for MaxOpenConns to free up
+		DialErrors        int64         // new connections that failed to dial, on every address tried
+		MaxIdleClosed     int64         // connections closed because the idle pool was full
+		MaxLifetimeClosed int64         // connections closed because of ConnMaxLifetime
+		BadConnClosed     int64         // connections closed after an error or a failed health check
+		// OpenByAddr is the number of connections, in use or idle, to each server address.
+		OpenByAddr map[string]int
 	}
 )
 
@@ -94,11 +156,16 @@ type (
 		// XML, ...) would embed it in a valid payload and end the stream
 		// cleanly. An explicit caller setting takes precedence over the pin.
 		//
+		// Over the native protocol the server only sends Native blocks and
+		// the driver encodes the stream itself. This works for CSV, TSV
+		// (TabSeparated), TSVWithNames (TabSeparatedWithNames), JSONEachRow,
+		// JSONCompactEachRow and RowBinary; other formats return
+		// clickhouse.ErrFormatNativeUnsupported. A query failing mid-stream
+		// surfaces as an error from Read after the rows encoded so far.
+		//
 		// Experimental: this API is experimental and may change or be removed
-		// in a future minor release. It is currently only supported over the
-		// HTTP protocol, where the server encodes the stream and every
-		// server-supported format works; over the native protocol it returns
-		// clickhouse.ErrFormatNativeUnsupported.
+		// in a future minor release. Over the HTTP protocol the server
+		// encodes the stream and every server-supported format works.
 		QueryFormat(ctx context.Context, format string, query string, args ...any) (io.ReadCloser, error)
 
 		// InsertFormat executes the INSERT statement query, streaming
@@ -114,19 +181,57 @@ type (
 		// (such as a .parquet.gz file) therefore compresses it twice, and the
 		// server rejects the once-decoded payload as malformed format data.
 		//
+		// Over the native protocol the driver parses the payload itself into
+		// Native blocks, which supports the same formats as QueryFormat;
+		// other formats return clickhouse.ErrFormatNativeUnsupported.
+		//
 		// Experimental: this API is experimental and may change or be removed
-		// in a future minor release. It is currently only supported over the
-		// HTTP protocol, where the server parses the payload and every
-		// server-supported format works; over the native protocol it returns
-		// clickhouse.ErrFormatNativeUnsupported.
+		// in a future minor release. Over the HTTP protocol the server parses
+		// the payload and every server-supported format works.
 		InsertFormat(ctx context.Context, format string, query string, data io.Reader) error
 
 		// Deprecated: use context aware `WithAsync()` for any async operations
 		AsyncInsert(ctx context.Context, query string, wait bool, args ...any) error
 		Ping(context.Context) error
+		// TablesStatus reports the replication status of tables ("table" or
+		// "database.table") on the server of one pooled connection, using the
+		// native TablesStatus packet (system.replicas over HTTP). Tables the
+		// server doesn't know are left out.
+		TablesStatus(ctx context.Context, tables ...string) ([]TableStatus, error)
+		// Session pins a connection of the pool for statements sharing server
+		// session state, such as temporary tables and SET. Over HTTP its requests
+		// carry a session_id; over the native protocol the connection itself is
+		// the session. On Session.Close the HTTP connection is returned to the
+		// pool, while the native one is closed to drop the temporary tables of
+		// the session.
+		Session(ctx context.Context, opts SessionOptions) (Session, error)
+		// KillQuery stops the query with queryID using KILL QUERY. By default
+		// it returns once the server asked the query to stop, use
+		// WithKillMode(KillSync) to wait for it to stop.
+		KillQuery(ctx context.Context, queryID string, opts ...KillOption) error
+		// RunningQueries returns the queries running on the server of one
+		// pooled connection, from system.processes.
+		RunningQueries(ctx context.Context, filter RunningQueriesFilter) ([]RunningQuery, error)
 		Stats() Stats
 		Close() error
 	}
+	// Session runs statements one at a time on a pinned connection, see
+	// Conn.Session. A statement waits for the previous one to finish: Rows
+	// must be closed and batches sent or aborted first.
+	Session interface {
+		// ID is the session_id of an HTTP session, empty over the native protocol.
+		ID() string
+		Select(ctx context.Context, dest any, query string, args ...any) error
+		Query(ctx context.Context, query string, args ...any) (Rows, error)
+		QueryRow(ctx context.Context, query string, args ...any) Row
+		PrepareBatch(ctx context.Context, query string, opts ...PrepareBatchOption) (Batch, error)
+		Exec(ctx context.Context, query string, args ...any) error
+		Ping(context.Context) error
+		// Close waits for the running statement and returns the connection to
+		// the pool. A native connection is closed instead, dropping its
+		// temporary tables; an HTTP session expires after its timeout.
+		Close() error
+	}
 	Row interface {
 		Err() error
 		Scan(dest ...any) error
@@ -144,6 +249,44 @@ type (
 		HasData() bool
 	}
 
+	// BlockRows is implemented by the Rows of the driver, which callers
+	// type-assert for block-level columnar reads:
+	//
+	//	if blocks, ok := rows.(driver.BlockRows); ok {
+	//		for block, ok := blocks.NextBlock(); ok; block, ok = blocks.NextBlock() { ... }
+	//	}
+	BlockRows interface {
+		Rows
+
+		// NextBlock advances to the next block of the result that has not been
+		// read with Next and returns it. It returns false once the result is
+		// exhausted or an error occurred, see Err. Unlike the rows of Next, a
+		// returned block stays valid after advancing further.
+		NextBlock() (Block, bool)
+	}
+
+	// QueryIDProvider is implemented by the Rows and Batch of the driver,
+	// which callers type-assert:
+	//
+	//	if q, ok := rows.(driver.QueryIDProvider); ok {
+	//		log.Printf("running %s", q.QueryID())
+	//	}
+	QueryIDProvider interface {
+		// QueryID is the query ID the query was sent with, from WithQueryID or
+		// generated. Pass it to Conn.KillQuery to stop the query.
+		QueryID() string
+	}
+
+	// Block is a block of a query result as decoded from the server. Its
+	// columns hold the values of all rows in their native representation:
+	// type assert them to the lib/column types and use their Data, Offsets
+	// or Nulls methods for typed access without boxing every value.
+	Block interface {
+		Rows() int
+		Columns() []column.Interface
+		ColumnNames() []string
+	}
+
 	// Batch represents a prepared INSERT that buffers rows client-side and sends them to ClickHouse.
 	//
 	// Typical usage:
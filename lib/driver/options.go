@@ -25,3 +25,36 @@ func WithCloseOnFlush() PrepareBatchOption {
 		options.CloseOnFlush = true
 	}
 }
+
+// KillMode is how KILL QUERY waits for the query to stop.
+type KillMode uint8
+
+const (
+	// KillAsync returns once the query was asked to stop, the server default.
+	KillAsync KillMode = iota
+	// KillSync waits for the query to stop.
+	KillSync
+	// KillTest only checks that the query exists and may be killed by the user.
+	KillTest
+)
+
+type KillOptions struct {
+	Mode    KillMode
+	Cluster string
+}
+
+type KillOption func(options *KillOptions)
+
+// WithKillMode sets whether KillQuery waits for the query to stop.
+func WithKillMode(mode KillMode) KillOption {
+	return func(options *KillOptions) {
+		options.Mode = mode
+	}
+}
+
+// WithKillOnCluster kills the query on every server of cluster with ON CLUSTER.
+func WithKillOnCluster(cluster string) KillOption {
+	return func(options *KillOptions) {
+		options.Cluster = cluster
+	}
+}
@@ -3,6 +3,7 @@ package proto
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 
 	"github.com/ClickHouse/ch-go/proto"
@@ -92,6 +93,20 @@ func (b *Block) SortColumns(columns []string) error {
 	return nil
 }
 
+// DropColumns removes the named columns from the block.
+func (b *Block) DropColumns(names ...string) {
+	var (
+		kept      = make([]column.Interface, 0, len(b.Columns))
+		keptNames = make([]string, 0, len(b.names))
+	)
+	for i, name := range b.names {
+		if !slices.Contains(names, name) {
+			kept, keptNames = append(kept, b.Columns[i]), append(keptNames, name)
+		}
+	}
+	b.Columns, b.names = kept, keptNames
+}
+
 func difference(a, b []string) []string {
 	mb := make(map[string]struct{}, len(b))
 	for _, x := range b {
@@ -208,20 +223,33 @@ func (b *Block) Decode(reader *proto.Reader, revision uint64) (err error) {
 			return err
 		}
 
+		var info *column.SerializationInfo
 		if revision >= DBMS_MIN_REVISION_WITH_CUSTOM_SERIALIZATION {
 			hasCustom, err := reader.Bool()
 			if err != nil {
 				return err
 			}
 			if hasCustom {
-				return &BlockError{
-					Op:  "Decode",
-					Err: fmt.Errorf("custom serialization for column %s. not supported by clickhouse-go driver", columnName),
+				if info, err = column.ReadSerializationInfo(reader, c); err != nil {
+					return &BlockError{
+						Op:         "Decode",
+						Err:        err,
+						ColumnName: columnName,
+					}
 				}
 			}
 		}
 
-		if numRows != 0 {
+		switch {
+		case numRows != 0 && info != nil:
+			if err := column.DecodeWithSerialization(reader, c, int(numRows), info, b.ServerContext); err != nil {
+				return &BlockError{
+					Op:         "Decode",
+					Err:        err,
+					ColumnName: columnName,
+				}
+			}
+		case numRows != 0:
 			if serialize, ok := c.(column.CustomSerialization); ok {
 				if err := serialize.ReadStatePrefix(reader); err != nil {
 					return &BlockError{
@@ -0,0 +1,100 @@
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+)
+
+const sparseEndOfGranule = uint64(1) << 62
+
+// TestBlockDecodeSparse decodes a block as sent by a server with
+// ratio_of_defaults_for_sparse_serialization enabled: non-default values
+// are preceded by the number of defaults before them.
+func TestBlockDecodeSparse(t *testing.T) {
+	var buffer proto.Buffer
+	encodeBlockInfo(&buffer)
+	buffer.PutUVarInt(3) // columns
+	buffer.PutUVarInt(6) // rows
+
+	// sparse UInt64: 0, 0, 42, 0, 7, 0
+	buffer.PutString("id")
+	buffer.PutString("UInt64")
+	buffer.PutBool(true)
+	buffer.PutUInt8(uint8(column.SerializationSparse))
+	buffer.PutUVarInt(2)
+	buffer.PutUVarInt(1)
+	buffer.PutUVarInt(1 | sparseEndOfGranule)
+	buffer.PutUInt64(42)
+	buffer.PutUInt64(7)
+
+	// sparse String with no values at all
+	buffer.PutString("name")
+	buffer.PutString("String")
+	buffer.PutBool(true)
+	buffer.PutUInt8(uint8(column.SerializationSparse))
+	buffer.PutUVarInt(6 | sparseEndOfGranule)
+
+	// Tuple with a regular and a sparse element
+	buffer.PutString("pair")
+	buffer.PutString("Tuple(a UInt8, b Nullable(String))")
+	buffer.PutBool(true)
+	buffer.PutUInt8(uint8(column.SerializationDefault))
+	buffer.PutUInt8(uint8(column.SerializationDefault))
+	buffer.PutUInt8(uint8(column.SerializationSparse))
+	for i := 0; i < 6; i++ {
+		buffer.PutUInt8(uint8(i))
+	}
+	buffer.PutUVarInt(5)
+	buffer.PutUVarInt(0 | sparseEndOfGranule)
+	buffer.PutUInt8(0)
+	buffer.PutString("last")
+
+	block := NewBlock()
+	block.ServerContext.Timezone = time.UTC
+	require.NoError(t, block.Decode(buffer.Reader(), DBMS_MIN_REVISION_WITH_CUSTOM_SERIALIZATION))
+	require.Equal(t, 6, block.Rows())
+	assert.Equal(t, []string{"id", "name", "pair"}, block.ColumnsNames())
+
+	for i, expected := range []uint64{0, 0, 42, 0, 7, 0} {
+		var id uint64
+		require.NoError(t, block.Columns[0].ScanRow(&id, i))
+		assert.Equal(t, expected, id)
+		var name string
+		require.NoError(t, block.Columns[1].ScanRow(&name, i))
+		assert.Empty(t, name)
+	}
+
+	var pair struct {
+		A uint8   `ch:"a"`
+		B *string `ch:"b"`
+	}
+	require.NoError(t, block.Columns[2].ScanRow(&pair, 3))
+	assert.Equal(t, uint8(3), pair.A)
+	assert.Nil(t, pair.B)
+	require.NoError(t, block.Columns[2].ScanRow(&pair, 5))
+	require.NotNil(t, pair.B)
+	assert.Equal(t, "last", *pair.B)
+}
+
+func TestBlockDecodeSparseInvalidOffsets(t *testing.T) {
+	var buffer proto.Buffer
+	encodeBlockInfo(&buffer)
+	buffer.PutUVarInt(1)
+	buffer.PutUVarInt(2)
+	buffer.PutString("id")
+	buffer.PutString("UInt64")
+	buffer.PutBool(true)
+	buffer.PutUInt8(uint8(column.SerializationSparse))
+	buffer.PutUVarInt(5 | sparseEndOfGranule)
+
+	block := NewBlock()
+	err := block.Decode(buffer.Reader(), DBMS_MIN_REVISION_WITH_CUSTOM_SERIALIZATION)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sparse offsets")
+}
@@ -20,6 +20,13 @@ const (
 	DBMS_MIN_PROTOCOL_VERSION_WITH_QUOTA_KEY                    = 54458
 	DBMS_MIN_PROTOCOL_VERSION_WITH_PARAMETERS                   = 54459
 	DBMS_MIN_PROTOCOL_VERSION_WITH_SERVER_QUERY_TIME_IN_PROGRES = 54460
+	DBMS_MIN_PROTOCOL_VERSION_WITH_PASSWORD_COMPLEXITY_RULES    = 54461
+	DBMS_MIN_REVISION_WITH_INTERSERVER_SECRET_V2                = 54462
+	DBMS_MIN_PROTOCOL_VERSION_WITH_TOTAL_BYTES_IN_PROGRESS      = 54463
+	DBMS_MIN_PROTOCOL_VERSION_WITH_TIMEZONE_UPDATES             = 54464
+	DBMS_MIN_REVISION_WITH_SPARSE_SERIALIZATION                 = 54465
+	DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION                   = 54466
+	DBMS_MIN_PROTOCOL_VERSION_WITH_TABLE_READ_ONLY_CHECK        = 54467
 	DBMS_TCP_PROTOCOL_VERSION                                   = DBMS_MIN_PROTOCOL_VERSION_WITH_SERVER_QUERY_TIME_IN_PROGRES
 )
 
@@ -29,6 +36,11 @@ const (
 	ClientData   = 2
 	ClientCancel = 3
 	ClientPing   = 4
+
+	ClientTablesStatusRequest = 5
+
+	ClientSSHChallengeRequest  = 11
+	ClientSSHChallengeResponse = 12
 )
 
 const (
@@ -63,4 +75,6 @@ const (
 	ServerReadTaskRequest     = 13
 	ServerProfileEvents       = 14
 	ServerTreeReadTaskRequest = 15
+	ServerTimezoneUpdate      = 17
+	ServerSSHChallenge        = 18
 )
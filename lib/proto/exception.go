@@ -16,8 +16,10 @@ type Exception struct {
 	CodeName   string
 	Message    string
 	StackTrace string
-	Nested     []Exception
-	nested     bool
+	// QueryID is the query ID of the failed query, set by the client.
+	QueryID string
+	Nested  []Exception
+	nested  bool
 }
 
 func (e *Exception) Error() string {
@@ -35,6 +35,15 @@ type ServerHandshake struct {
 	Revision    uint64
 	Version     Version
 	Timezone    *time.Location
+
+	PasswordComplexityRules []PasswordComplexityRule
+	Nonce                   uint64 // for the interserver secret, unused by clients
+}
+
+// PasswordComplexityRule is a password_complexity rule of the server config.
+type PasswordComplexityRule struct {
+	Pattern string
+	Message string
 }
 
 type Version struct {
@@ -69,6 +78,12 @@ func CheckMinVersion(constraint Version, version Version) bool {
 }
 
 func (srv *ServerHandshake) Decode(reader *chproto.Reader) (err error) {
+	return srv.DecodeRevision(reader, DBMS_TCP_PROTOCOL_VERSION)
+}
+
+// DecodeRevision decodes the server hello answering a client hello with
+// protocol version clientRevision.
+func (srv *ServerHandshake) DecodeRevision(reader *chproto.Reader, clientRevision uint64) (err error) {
 	if srv.Name, err = reader.Str(); err != nil {
 		return fmt.Errorf("could not read server name: %v", err)
 	}
@@ -102,6 +117,29 @@ func (srv *ServerHandshake) Decode(reader *chproto.Reader) (err error) {
 	} else {
 		srv.Version.Patch = srv.Revision
 	}
+	revision := min(srv.Revision, clientRevision)
+	if revision >= DBMS_MIN_PROTOCOL_VERSION_WITH_PASSWORD_COMPLEXITY_RULES {
+		n, err := reader.UVarInt()
+		if err != nil {
+			return fmt.Errorf("could not read password complexity rules: %v", err)
+		}
+		srv.PasswordComplexityRules = make([]PasswordComplexityRule, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var rule PasswordComplexityRule
+			if rule.Pattern, err = reader.Str(); err != nil {
+				return fmt.Errorf("could not read password complexity rule: %v", err)
+			}
+			if rule.Message, err = reader.Str(); err != nil {
+				return fmt.Errorf("could not read password complexity rule: %v", err)
+			}
+			srv.PasswordComplexityRules = append(srv.PasswordComplexityRules, rule)
+		}
+	}
+	if revision >= DBMS_MIN_REVISION_WITH_INTERSERVER_SECRET_V2 {
+		if srv.Nonce, err = reader.UInt64(); err != nil {
+			return fmt.Errorf("could not read server nonce: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -1,10 +1,13 @@
 package proto
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
+	chproto "github.com/ClickHouse/ch-go/proto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestCheckMinVersion pins the boundary semantics of the version gate used
@@ -34,3 +37,34 @@ func TestCheckMinVersion(t *testing.T) {
 	assert.False(t, CheckMinVersion(patchConstraint, Version{Major: 25, Minor: 8, Patch: 2}))
 	assert.True(t, CheckMinVersion(patchConstraint, Version{Major: 25, Minor: 9, Patch: 0}))
 }
+
+// TestServerHandshakeDecodeRevision checks that the fields of the server hello
+// are read up to the lower of the client and server revisions.
+func TestServerHandshakeDecodeRevision(t *testing.T) {
+	var buffer chproto.Buffer
+	buffer.PutString("ClickHouse")
+	buffer.PutUVarInt(25)
+	buffer.PutUVarInt(8)
+	buffer.PutUVarInt(DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION)
+	buffer.PutString("UTC")
+	buffer.PutString("server")
+	buffer.PutUVarInt(1)
+	hello := bytes.Clone(buffer.Buf)
+	buffer.PutUVarInt(1)
+	buffer.PutString(".{12}")
+	buffer.PutString("at least 12 characters")
+	buffer.PutUInt64(42)
+
+	var srv ServerHandshake
+	reader := bytes.NewReader(buffer.Buf)
+	require.NoError(t, srv.DecodeRevision(chproto.NewReader(reader), DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION))
+	assert.Zero(t, reader.Len())
+	assert.Equal(t, []PasswordComplexityRule{{Pattern: ".{12}", Message: "at least 12 characters"}}, srv.PasswordComplexityRules)
+	assert.Equal(t, uint64(42), srv.Nonce)
+
+	srv = ServerHandshake{}
+	reader = bytes.NewReader(hello)
+	require.NoError(t, srv.Decode(chproto.NewReader(reader)))
+	assert.Zero(t, reader.Len(), "an older client reads no newer field")
+	assert.Empty(t, srv.PasswordComplexityRules)
+}
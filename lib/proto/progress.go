@@ -11,6 +11,7 @@ type Progress struct {
 	Rows       uint64
 	Bytes      uint64
 	TotalRows  uint64
+	TotalBytes uint64
 	WroteRows  uint64
 	WroteBytes uint64
 	Elapsed    time.Duration
@@ -27,6 +28,11 @@ func (p *Progress) Decode(reader *chproto.Reader, revision uint64) (err error) {
 	if p.TotalRows, err = reader.UVarInt(); err != nil {
 		return err
 	}
+	if revision >= DBMS_MIN_PROTOCOL_VERSION_WITH_TOTAL_BYTES_IN_PROGRESS {
+		if p.TotalBytes, err = reader.UVarInt(); err != nil {
+			return err
+		}
+	}
 	if revision >= DBMS_MIN_REVISION_WITH_CLIENT_WRITE_INFO {
 		p.withClient = true
 		if p.WroteRows, err = reader.UVarInt(); err != nil {
@@ -0,0 +1,74 @@
+package proto
+
+import (
+	chproto "github.com/ClickHouse/ch-go/proto"
+)
+
+type QualifiedTableName struct {
+	Database string
+	Table    string
+}
+
+// TablesStatusRequest asks the server for the replication status of tables.
+type TablesStatusRequest struct {
+	Tables []QualifiedTableName
+}
+
+func (r *TablesStatusRequest) Encode(buffer *chproto.Buffer, revision uint64) {
+	buffer.PutUVarInt(uint64(len(r.Tables)))
+	for _, table := range r.Tables {
+		buffer.PutString(table.Database)
+		buffer.PutString(table.Table)
+	}
+}
+
+type TableStatus struct {
+	IsReplicated bool
+	// AbsoluteDelay is the replication delay of a replicated table in seconds.
+	AbsoluteDelay uint32
+	IsReadonly    bool
+}
+
+// TablesStatusResponse holds the status of the requested tables the server knows.
+type TablesStatusResponse struct {
+	Tables map[QualifiedTableName]TableStatus
+}
+
+func (r *TablesStatusResponse) Decode(reader *chproto.Reader, revision uint64) error {
+	n, err := reader.UVarInt()
+	if err != nil {
+		return err
+	}
+	r.Tables = make(map[QualifiedTableName]TableStatus, n)
+	for i := uint64(0); i < n; i++ {
+		var (
+			name   QualifiedTableName
+			status TableStatus
+		)
+		if name.Database, err = reader.Str(); err != nil {
+			return err
+		}
+		if name.Table, err = reader.Str(); err != nil {
+			return err
+		}
+		if status.IsReplicated, err = reader.Bool(); err != nil {
+			return err
+		}
+		if status.IsReplicated {
+			delay, err := reader.UVarInt()
+			if err != nil {
+				return err
+			}
+			status.AbsoluteDelay = uint32(delay)
+			if revision >= DBMS_MIN_PROTOCOL_VERSION_WITH_TABLE_READ_ONLY_CHECK {
+				readonly, err := reader.UVarInt()
+				if err != nil {
+					return err
+				}
+				status.IsReadonly = readonly != 0
+			}
+		}
+		r.Tables[name] = status
+	}
+	return nil
+}
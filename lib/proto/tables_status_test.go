@@ -0,0 +1,44 @@
+package proto
+
+import (
+	"bytes"
+	"testing"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTablesStatusRequestEncode(t *testing.T) {
+	var buffer chproto.Buffer
+	(&TablesStatusRequest{Tables: []QualifiedTableName{{"db", "events"}}}).Encode(&buffer, DBMS_TCP_PROTOCOL_VERSION)
+	assert.Equal(t, []byte{1, 2, 'd', 'b', 6, 'e', 'v', 'e', 'n', 't', 's'}, buffer.Buf)
+}
+
+func TestTablesStatusResponseDecode(t *testing.T) {
+	encode := func(readonly bool) []byte {
+		var buffer chproto.Buffer
+		buffer.PutUVarInt(2)
+		buffer.PutString("db")
+		buffer.PutString("local")
+		buffer.PutBool(false)
+		buffer.PutString("db")
+		buffer.PutString("replicated")
+		buffer.PutBool(true)
+		buffer.PutUVarInt(300)
+		if readonly {
+			buffer.PutUVarInt(1)
+		}
+		return buffer.Buf
+	}
+
+	var response TablesStatusResponse
+	require.NoError(t, response.Decode(chproto.NewReader(bytes.NewReader(encode(false))), DBMS_TCP_PROTOCOL_VERSION))
+	assert.Equal(t, map[QualifiedTableName]TableStatus{
+		{"db", "local"}:      {},
+		{"db", "replicated"}: {IsReplicated: true, AbsoluteDelay: 300},
+	}, response.Tables)
+
+	require.NoError(t, response.Decode(chproto.NewReader(bytes.NewReader(encode(true))), DBMS_MIN_PROTOCOL_VERSION_WITH_TABLE_READ_ONLY_CHECK))
+	assert.Equal(t, TableStatus{IsReplicated: true, AbsoluteDelay: 300, IsReadonly: true}, response.Tables[QualifiedTableName{"db", "replicated"}])
+}
@@ -0,0 +1,113 @@
+// Package otelmetric exports the connection pool statistics of clickhouse.Open
+// connections (driver.Stats) as OpenTelemetry metrics. Register it with a Meter
+// of any MeterProvider; for Prometheus use the OpenTelemetry Prometheus exporter
+// (go.opentelemetry.io/otel/exporters/prometheus) as the provider's reader.
+//
+// Connection counts follow the OpenTelemetry database client conventions
+// (db.client.connection.*), the other metrics use the clickhouse.client.connection prefix.
+package otelmetric
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// StatsProvider is implemented by driver.Conn.
+type StatsProvider interface {
+	Stats() driver.Stats
+}
+
+// Register observes the statistics of conn on every collection of meter, adding
+// attrs (for example the name of the pool) to every data point. Unregister the
+// returned registration when conn is closed.
+func Register(meter metric.Meter, conn StatsProvider, attrs ...attribute.KeyValue) (metric.Registration, error) {
+	var (
+		err         error
+		instruments []metric.Observable
+	)
+	upDownCounter := func(name, description string) metric.Int64ObservableUpDownCounter {
+		counter, cerr := meter.Int64ObservableUpDownCounter(name, metric.WithUnit("{connection}"), metric.WithDescription(description))
+		if err == nil {
+			err = cerr
+		}
+		instruments = append(instruments, counter)
+		return counter
+	}
+	counter := func(name, unit, description string) metric.Int64ObservableCounter {
+		counter, cerr := meter.Int64ObservableCounter(name, metric.WithUnit(unit), metric.WithDescription(description))
+		if err == nil {
+			err = cerr
+		}
+		instruments = append(instruments, counter)
+		return counter
+	}
+	var (
+		count      = upDownCounter("db.client.connection.count", "Connections in use or idle.")
+		maxOpen    = upDownCounter("db.client.connection.max", "Maximum number of open connections (MaxOpenConns).")
+		maxIdle    = upDownCounter("db.client.connection.idle.max", "Maximum number of idle connections (MaxIdleConns).")
+		byServer   = upDownCounter("clickhouse.client.connection.server.count", "Connections in use or idle per server.")
+		acquires   = counter("clickhouse.client.connection.acquires", "{acquire}", "Connections handed out, from the pool or newly dialed.")
+		waits      = counter("clickhouse.client.connection.waits", "{wait}", "Acquisitions that waited for MaxOpenConns to free up.")
+		dialErrors = counter("clickhouse.client.connection.dial_errors", "{error}", "New connections that failed to dial.")
+		closed     = counter("clickhouse.client.connection.closed", "{connection}", "Connections closed by the pool, by reason.")
+	)
+	waitTime, werr := meter.Float64ObservableCounter("clickhouse.client.connection.wait_time",
+		metric.WithUnit("s"), metric.WithDescription("Time spent waiting for MaxOpenConns to free up."))
+	if err == nil {
+		err = werr
+	}
+	if err != nil {
+		return nil, err
+	}
+	instruments = append(instruments, waitTime)
+
+	with := func(kv ...attribute.KeyValue) metric.MeasurementOption {
+		return metric.WithAttributeSet(attribute.NewSet(append(kv, attrs...)...))
+	}
+	var (
+		pool        = with()
+		used        = with(attribute.String("db.client.connection.state", "used"))
+		idle        = with(attribute.String("db.client.connection.state", "idle"))
+		maxIdleConn = with(attribute.String("reason", "max_idle"))
+		maxLifetime = with(attribute.String("reason", "max_lifetime"))
+		badConn     = with(attribute.String("reason", "bad_conn"))
+	)
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := conn.Stats()
+		o.ObserveInt64(count, int64(stats.Open), used)
+		o.ObserveInt64(count, int64(stats.Idle), idle)
+		o.ObserveInt64(maxOpen, int64(stats.MaxOpenConns), pool)
+		o.ObserveInt64(maxIdle, int64(stats.MaxIdleConns), pool)
+		for addr, n := range stats.OpenByAddr {
+			o.ObserveInt64(byServer, int64(n), with(serverAttributes(addr)...))
+		}
+		o.ObserveInt64(acquires, stats.AcquireCount, pool)
+		o.ObserveInt64(waits, stats.WaitCount, pool)
+		o.ObserveFloat64(waitTime, stats.WaitDuration.Seconds(), pool)
+		o.ObserveInt64(dialErrors, stats.DialErrors, pool)
+		o.ObserveInt64(closed, stats.MaxIdleClosed, maxIdleConn)
+		o.ObserveInt64(closed, stats.MaxLifetimeClosed, maxLifetime)
+		o.ObserveInt64(closed, stats.BadConnClosed, badConn)
+		return nil
+	}, instruments...)
+}
+
+// serverAttributes splits a host:port address into server.address and
+// server.port, as the spans of the driver do.
+func serverAttributes(addr string) []attribute.KeyValue {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return []attribute.KeyValue{attribute.String("server.address", addr)}
+	}
+	attrs := []attribute.KeyValue{attribute.String("server.address", host)}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attribute.Int("server.port", p))
+	}
+	return attrs
+}
@@ -0,0 +1,78 @@
+package otelmetric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+type staticStats driver.Stats
+
+func (s staticStats) Stats() driver.Stats { return driver.Stats(s) }
+
+func TestRegister(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	registration, err := Register(provider.Meter("test"), staticStats{
+		MaxOpenConns:  10,
+		Open:          2,
+		Idle:          1,
+		AcquireCount:  7,
+		WaitCount:     3,
+		WaitDuration:  1500 * time.Millisecond,
+		BadConnClosed: 4,
+		OpenByAddr:    map[string]int{"127.0.0.1:9000": 3},
+	}, attribute.String("pool", "main"))
+	require.NoError(t, err)
+	defer registration.Unregister()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+
+	points := func(name string) map[attribute.Distinct]float64 {
+		values := make(map[attribute.Distinct]float64)
+		switch data := metrics[name].(type) {
+		case metricdata.Sum[int64]:
+			for _, p := range data.DataPoints {
+				values[p.Attributes.Equivalent()] = float64(p.Value)
+			}
+		case metricdata.Sum[float64]:
+			for _, p := range data.DataPoints {
+				values[p.Attributes.Equivalent()] = p.Value
+			}
+		default:
+			t.Fatalf("unexpected data %T for %s", data, name)
+		}
+		return values
+	}
+	key := func(kv ...attribute.KeyValue) attribute.Distinct {
+		set := attribute.NewSet(append(kv, attribute.String("pool", "main"))...)
+		return set.Equivalent()
+	}
+
+	count := points("db.client.connection.count")
+	assert.Equal(t, float64(2), count[key(attribute.String("db.client.connection.state", "used"))])
+	assert.Equal(t, float64(1), count[key(attribute.String("db.client.connection.state", "idle"))])
+	assert.Equal(t, float64(10), points("db.client.connection.max")[key()])
+	assert.Equal(t, float64(3), points("clickhouse.client.connection.server.count")[key(
+		attribute.String("server.address", "127.0.0.1"), attribute.Int("server.port", 9000))])
+	assert.Equal(t, float64(7), points("clickhouse.client.connection.acquires")[key()])
+	assert.Equal(t, float64(3), points("clickhouse.client.connection.waits")[key()])
+	assert.Equal(t, 1.5, points("clickhouse.client.connection.wait_time")[key()])
+	assert.Equal(t, float64(4), points("clickhouse.client.connection.closed")[key(attribute.String("reason", "bad_conn"))])
+}
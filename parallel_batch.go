@@ -0,0 +1,236 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const (
+	defaultParallelBatchParallelism = 4
+	defaultParallelBatchBlockSize   = 100_000
+)
+
+// ParallelBatchOptions configures PrepareParallelBatch.
+type ParallelBatchOptions struct {
+	// Parallelism is the number of connections the rows are inserted over,
+	// default 4, at most MaxOpenConns of the Conn.
+	Parallelism int
+	// BlockSize is the number of rows of the blocks sent, default 100000.
+	BlockSize int
+	// BatchOptions are passed to PrepareBatch of every connection.
+	BatchOptions []driver.PrepareBatchOption
+}
+
+// ParallelBatch inserts the rows of an INSERT over several connections of
+// the pool at once. Appended rows fill a block, which is sent on its own
+// connection once it holds BlockSize rows while the next rows fill the block
+// of another one. Every connection runs its own INSERT of the query, so the
+// rows of a ParallelBatch are not inserted atomically.
+type ParallelBatch struct {
+	ctx         context.Context
+	conn        driver.Conn
+	query       string
+	opts        ParallelBatchOptions
+	parallelism int
+
+	mu      sync.Mutex
+	workers []*parallelBatchWorker
+	filling *parallelBatchWorker // receives the appended rows, nil until the next append
+	idle    chan *parallelBatchWorker
+	rows    int
+	err     error
+	sent    bool
+	flushes sync.WaitGroup
+}
+
+// parallelBatchWorker is the batch of a connection, which either receives
+// rows or sends them.
+type parallelBatchWorker struct {
+	batch driver.Batch
+	err   error // of the last flush, set before the worker is idle again
+}
+
+// PrepareParallelBatch prepares the INSERT query on a connection of conn, a
+// Conn of Open with the native protocol. Further connections are acquired
+// when the rows outpace the blocks being sent, up to Parallelism.
+func PrepareParallelBatch(ctx context.Context, conn driver.Conn, query string, opts ParallelBatchOptions) (*ParallelBatch, error) {
+	if ch, ok := conn.(*clickhouse); !ok || ch.opt.Protocol != Native {
+		return nil, errors.New("clickhouse: ParallelBatch requires a native protocol Conn of Open")
+	}
+	return newParallelBatch(ctx, conn, query, opts)
+}
+
+func newParallelBatch(ctx context.Context, conn driver.Conn, query string, opts ParallelBatchOptions) (*ParallelBatch, error) {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = defaultParallelBatchParallelism
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = defaultParallelBatchBlockSize
+	}
+	b := &ParallelBatch{
+		ctx:         ctx,
+		conn:        conn,
+		query:       query,
+		opts:        opts,
+		parallelism: max(1, min(opts.Parallelism, conn.Stats().MaxOpenConns)),
+	}
+	b.idle = make(chan *parallelBatchWorker, b.parallelism)
+	w, err := b.prepare()
+	if err != nil {
+		return nil, err
+	}
+	b.filling = w
+	return b, nil
+}
+
+// prepare prepares the batch of a new worker. b.mu is held.
+func (b *ParallelBatch) prepare() (*parallelBatchWorker, error) {
+	batch, err := b.conn.PrepareBatch(b.ctx, b.query, b.opts.BatchOptions...)
+	if err != nil {
+		return nil, err
+	}
+	w := &parallelBatchWorker{batch: batch}
+	b.workers = append(b.workers, w)
+	return w, nil
+}
+
+// worker returns the worker that receives the next rows: an idle one, a new
+// one below Parallelism, or the first one done sending. b.mu is held.
+func (b *ParallelBatch) worker() (*parallelBatchWorker, error) {
+	var w *parallelBatchWorker
+	select {
+	case w = <-b.idle:
+	default:
+		if len(b.workers) < b.parallelism {
+			return b.prepare()
+		}
+		select {
+		case w = <-b.idle:
+		case <-b.ctx.Done():
+			return nil, context.Cause(b.ctx)
+		}
+	}
+	if w.err != nil {
+		// keep the failed worker for Send to report
+		b.idle <- w
+		return nil, w.err
+	}
+	return w, nil
+}
+
+// Append appends a row, in the column order of the query. It blocks while
+// Parallelism blocks are being sent. Append is safe for concurrent use.
+func (b *ParallelBatch) Append(v ...any) error {
+	return b.append(func(batch driver.Batch) error {
+		return batch.Append(v...)
+	})
+}
+
+// AppendStruct appends the fields of struct v, like Batch.AppendStruct.
+func (b *ParallelBatch) AppendStruct(v any) error {
+	return b.append(func(batch driver.Batch) error {
+		return batch.AppendStruct(v)
+	})
+}
+
+func (b *ParallelBatch) append(appendRow func(driver.Batch) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.sent:
+		return ErrBatchAlreadySent
+	case b.err != nil:
+		return b.err
+	}
+	if b.filling == nil {
+		w, err := b.worker()
+		if err != nil {
+			b.err = err
+			return err
+		}
+		b.filling = w
+	}
+	w := b.filling
+	if err := appendRow(w.batch); err != nil {
+		// a rejected row leaves the batch unusable, as with Batch.Append
+		w.err, b.err, b.filling = err, err, nil
+		b.idle <- w
+		return err
+	}
+	b.rows++
+	if w.batch.Rows() >= b.opts.BlockSize {
+		b.filling = nil
+		b.flushes.Add(1)
+		go func() {
+			defer b.flushes.Done()
+			w.err = w.batch.Flush()
+			b.idle <- w
+		}()
+	}
+	return nil
+}
+
+// Rows returns the number of rows appended, including the ones already sent.
+func (b *ParallelBatch) Rows() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rows
+}
+
+// Send sends the rows left and ends the INSERT of every connection in
+// parallel. It returns the errors of all connections joined, the INSERTs of
+// the other connections are completed and the rows flushed on the failed
+// ones before their failure may have been inserted.
+func (b *ParallelBatch) Send() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sent {
+		return ErrBatchAlreadySent
+	}
+	b.sent = true
+	b.flushes.Wait()
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for _, w := range b.workers {
+		if w.err != nil {
+			w.batch.Abort()
+			mu.Lock()
+			errs = append(errs, w.err)
+			mu.Unlock()
+			continue
+		}
+		wg.Go(func() {
+			if err := w.batch.Send(); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Abort ends the INSERT of every connection without sending the rows left.
+func (b *ParallelBatch) Abort() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sent {
+		return ErrBatchAlreadySent
+	}
+	b.sent = true
+	b.flushes.Wait()
+	var errs []error
+	for _, w := range b.workers {
+		if err := w.batch.Abort(); err != nil && !errors.Is(err, ErrBatchAlreadySent) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,156 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// parallelConn stands for the pool of a ParallelBatch, its batches record
+// the blocks flushed and sent.
+type parallelConn struct {
+	driver.Conn
+	maxOpen  int
+	flushErr error
+	hold     chan struct{} // flushes wait for it to be closed, if set
+
+	mu       sync.Mutex
+	prepared int
+	blocks   [][]any // by flush or send
+	sent     int
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+}
+
+func (c *parallelConn) Stats() driver.Stats {
+	return driver.Stats{MaxOpenConns: c.maxOpen}
+}
+
+func (c *parallelConn) PrepareBatch(context.Context, string, ...driver.PrepareBatchOption) (driver.Batch, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prepared++
+	return &parallelTestBatch{conn: c}, nil
+}
+
+type parallelTestBatch struct {
+	driver.Batch
+	conn    *parallelConn
+	rows    []any
+	aborted bool
+}
+
+func (b *parallelTestBatch) Append(v ...any) error {
+	if len(v) != 1 {
+		return errors.New("expected 1 column")
+	}
+	b.rows = append(b.rows, v[0])
+	return nil
+}
+
+func (b *parallelTestBatch) Rows() int { return len(b.rows) }
+
+func (b *parallelTestBatch) Flush() error {
+	n := b.conn.inFlight.Add(1)
+	defer b.conn.inFlight.Add(-1)
+	for seen := b.conn.maxSeen.Load(); n > seen && !b.conn.maxSeen.CompareAndSwap(seen, n); seen = b.conn.maxSeen.Load() {
+	}
+	if b.conn.hold != nil {
+		<-b.conn.hold
+	}
+	if b.conn.flushErr != nil {
+		return b.conn.flushErr
+	}
+	b.conn.mu.Lock()
+	defer b.conn.mu.Unlock()
+	b.conn.blocks = append(b.conn.blocks, b.rows)
+	b.rows = nil
+	return nil
+}
+
+func (b *parallelTestBatch) Send() error {
+	b.conn.mu.Lock()
+	defer b.conn.mu.Unlock()
+	if len(b.rows) != 0 {
+		b.conn.blocks = append(b.conn.blocks, b.rows)
+	}
+	b.conn.sent++
+	return nil
+}
+
+func (b *parallelTestBatch) Abort() error {
+	b.aborted = true
+	return nil
+}
+
+func TestParallelBatch(t *testing.T) {
+	conn := &parallelConn{maxOpen: 10, hold: make(chan struct{})}
+	batch, err := newParallelBatch(context.Background(), conn, "INSERT INTO t", ParallelBatchOptions{
+		Parallelism: 3,
+		BlockSize:   10,
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for producer := range 4 {
+		wg.Go(func() {
+			for i := range 25 {
+				assert.NoError(t, batch.Append(producer*100+i))
+			}
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(3), conn.inFlight.Load(), "appends wait while Parallelism blocks are sent")
+	close(conn.hold)
+	wg.Wait()
+	assert.Equal(t, 100, batch.Rows())
+	require.NoError(t, batch.Send())
+	assert.ErrorIs(t, batch.Send(), ErrBatchAlreadySent)
+
+	assert.Equal(t, 3, conn.prepared)
+	assert.Equal(t, 3, conn.sent)
+	assert.Equal(t, int32(3), conn.maxSeen.Load())
+	var rows int
+	for _, block := range conn.blocks {
+		assert.LessOrEqual(t, len(block), 10)
+		rows += len(block)
+	}
+	assert.Equal(t, 100, rows)
+}
+
+func TestParallelBatchErrors(t *testing.T) {
+	conn := &parallelConn{maxOpen: 2, flushErr: errors.New("code: 252, TOO_MANY_PARTS")}
+	batch, err := newParallelBatch(context.Background(), conn, "INSERT INTO t", ParallelBatchOptions{
+		Parallelism: 8,
+		BlockSize:   1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, batch.parallelism, "Parallelism is bounded by MaxOpenConns")
+
+	var appendErr error
+	for i := 0; appendErr == nil && i < 10; i++ {
+		appendErr = batch.Append(i)
+	}
+	assert.ErrorContains(t, appendErr, "TOO_MANY_PARTS", "appends fail once a block failed")
+	assert.ErrorIs(t, batch.Append(1), appendErr)
+	err = batch.Send()
+	assert.ErrorContains(t, err, "TOO_MANY_PARTS")
+	for _, w := range batch.workers {
+		assert.True(t, w.batch.(*parallelTestBatch).aborted, "the failed INSERTs are aborted")
+	}
+
+	conn = &parallelConn{maxOpen: 2}
+	batch, err = newParallelBatch(context.Background(), conn, "INSERT INTO t", ParallelBatchOptions{})
+	require.NoError(t, err)
+	assert.Error(t, batch.Append(1, 2), "rejected rows fail the batch")
+	assert.Error(t, batch.Append(3))
+	require.NoError(t, batch.Abort())
+}
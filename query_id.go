@@ -0,0 +1,65 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// newQueryID returns a query ID for an operation sent without one.
+func (o *Options) newQueryID() string {
+	if o == nil {
+		return uuid.NewString()
+	}
+	return o.QueryIDPrefix + uuid.NewString()
+}
+
+// withQueryID gives the operation of ctx a query ID, unless WithQueryID set
+// one, so that it is known before it is sent: it is registered for
+// RunningQueries, traced and logged, and set on the errors of the operation.
+func (ch *clickhouse) withQueryID(ctx context.Context) (context.Context, string) {
+	return contextWithQueryID(ctx, ch.opt)
+}
+
+func contextWithQueryID(ctx context.Context, opt *Options) (context.Context, string) {
+	if queryID := queryOptions(ctx).queryID; queryID != "" {
+		return ctx, queryID
+	}
+	return withGeneratedQueryID(ctx, opt)
+}
+
+// retryQueryID gives the retry of an operation a new query ID, unless the
+// caller set one: the server may still run the failed attempt and rejects
+// another query with its ID (QUERY_WITH_SAME_ID_IS_ALREADY_RUNNING).
+func retryQueryID(ctx context.Context, opt *Options) (context.Context, string) {
+	if options := queryOptions(ctx); !options.generatedQueryID {
+		return ctx, options.queryID
+	}
+	return withGeneratedQueryID(ctx, opt)
+}
+
+func withGeneratedQueryID(ctx context.Context, opt *Options) (context.Context, string) {
+	queryID := opt.newQueryID()
+	return Context(ctx, func(o *QueryOptions) error {
+		o.queryID, o.generatedQueryID = queryID, true
+		return nil
+	}), queryID
+}
+
+// withQueryIDError sets queryID on the Exception and the OpError of err,
+// unless they have one already.
+func withQueryIDError(err error, queryID string) error {
+	if err == nil || queryID == "" {
+		return err
+	}
+	var exception *Exception
+	if errors.As(err, &exception) && exception.QueryID == "" {
+		exception.QueryID = queryID
+	}
+	var opErr *OpError
+	if errors.As(err, &opErr) && opErr.QueryID == "" {
+		opErr.QueryID = queryID
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithQueryID(t *testing.T) {
+	ch := &clickhouse{opt: &Options{QueryIDPrefix: "billing-"}}
+	ctx, queryID := ch.withQueryID(context.Background())
+	assert.True(t, strings.HasPrefix(queryID, "billing-"))
+	assert.Equal(t, queryID, queryOptions(ctx).queryID)
+
+	_, other := ch.withQueryID(context.Background())
+	assert.NotEqual(t, queryID, other, "every operation has its own query ID")
+
+	_, queryID = ch.withQueryID(Context(context.Background(), WithQueryID("q1")))
+	assert.Equal(t, "q1", queryID)
+}
+
+func TestWithQueryIDError(t *testing.T) {
+	exception := &Exception{Code: 60}
+	err := withQueryIDError(fmt.Errorf("sendQuery: %w", exception), "q1")
+	assert.Equal(t, "q1", exception.QueryID)
+	assert.EqualError(t, err, "sendQuery: code: 60, message: ")
+
+	opErr := &OpError{Op: "ScanStruct", Err: exception}
+	withQueryIDError(opErr, "q2")
+	assert.Equal(t, "q2", opErr.QueryID)
+	assert.Equal(t, "q1", exception.QueryID, "the query ID of the failed query is kept")
+
+	assert.NoError(t, withQueryIDError(nil, "q1"))
+}
+
+func TestExecErrorQueryID(t *testing.T) {
+	transport := &execErrTransport{mockTransport: newMockTransport(1), err: &Exception{Code: 60}}
+	ch := openSessionConn(t, func(int) nativeTransport { return transport })
+	ch.opt.QueryIDPrefix = "svc-"
+
+	var exception *Exception
+	require.ErrorAs(t, ch.Exec(context.Background(), "SELECT * FROM missing"), &exception)
+	assert.True(t, strings.HasPrefix(exception.QueryID, "svc-"))
+
+	transport.err = &Exception{Code: 60}
+	err := ch.Exec(Context(context.Background(), WithQueryID("q1")), "SELECT * FROM missing")
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, "q1", exception.QueryID)
+}
@@ -0,0 +1,271 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// ErrReplicaDelayExceeded is returned by queries using WithReplicaDelayCheck when every
+// server lags and fallback_to_stale_replicas_for_distributed_queries is disabled.
+var ErrReplicaDelayExceeded = errors.New("clickhouse: every replica lags beyond max_replica_delay_for_distributed_queries")
+
+// defaultMaxReplicaDelay is the server default of max_replica_delay_for_distributed_queries.
+const defaultMaxReplicaDelay = 300 * time.Second
+
+// WithReplicaDelayCheck makes Query, QueryRow and Exec run on a server whose replicas
+// of tables ("table" or "database.table") lag by at most max_replica_delay_for_distributed_queries
+// (query or connection setting, default 300s, 0 disables the check), as reported
+// by the native TablesStatus packet. The status of a pooled connection's server is
+// requested before every statement; lagging servers are skipped for this statement
+// and new connections are dialed to the other addresses. Like distributed queries,
+// when every server lags the least lagging one is used unless
+// fallback_to_stale_replicas_for_distributed_queries is 0, which returns ErrReplicaDelayExceeded.
+func WithReplicaDelayCheck(tables ...string) QueryOption {
+	return func(o *QueryOptions) error {
+		o.replicaTables = tables
+		return nil
+	}
+}
+
+func (ch *clickhouse) TablesStatus(ctx context.Context, tables ...string) ([]driver.TableStatus, error) {
+	conn, err := ch.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := qualifiedTableNames(tables)
+	statuses, err := conn.tablesStatus(ctx, names)
+	if err != nil {
+		ch.release(conn, err)
+		return nil, err
+	}
+	ch.release(conn, nil)
+	result := make([]driver.TableStatus, 0, len(names))
+	for _, name := range names {
+		status, ok := statuses[name]
+		if !ok {
+			continue
+		}
+		result = append(result, driver.TableStatus{
+			Database:      name.Database,
+			Table:         name.Table,
+			IsReplicated:  status.IsReplicated,
+			AbsoluteDelay: time.Duration(status.AbsoluteDelay) * time.Second,
+			IsReadonly:    status.IsReadonly,
+		})
+	}
+	return result, nil
+}
+
+// qualifiedTableNames splits "database.table" names, leaving the database of
+// bare table names empty for the server to resolve to the current database.
+func qualifiedTableNames(tables []string) []proto.QualifiedTableName {
+	names := make([]proto.QualifiedTableName, 0, len(tables))
+	for _, table := range tables {
+		name := proto.QualifiedTableName{Table: table}
+		if database, table, ok := strings.Cut(table, "."); ok {
+			name = proto.QualifiedTableName{Database: database, Table: table}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Connection::getTablesStatus
+// https://github.com/ClickHouse/ClickHouse/blob/master/src/Client/Connection.cpp
+func (c *connect) tablesStatus(ctx context.Context, tables []proto.QualifiedTableName) (map[proto.QualifiedTableName]proto.TableStatus, error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+		defer c.conn.SetDeadline(time.Time{})
+	}
+	c.buffer.PutByte(proto.ClientTablesStatusRequest)
+	(&proto.TablesStatusRequest{Tables: tables}).Encode(c.buffer, c.revision)
+	if err := c.flush(); err != nil {
+		return nil, fmt.Errorf("tables status: failed to send request to %s (conn_id=%d): %w",
+			c.conn.RemoteAddr(), c.id, err)
+	}
+	for {
+		packet, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("tables status: failed to read packet from %s (conn_id=%d): %w",
+				c.conn.RemoteAddr(), c.id, err)
+		}
+		switch packet {
+		case proto.ServerException:
+			return nil, c.exception()
+		case proto.ServerTablesStatus:
+			var response proto.TablesStatusResponse
+			if err := response.Decode(c.reader, c.revision); err != nil {
+				return nil, err
+			}
+			return response.Tables, nil
+		default:
+			return nil, fmt.Errorf("unexpected packet %d", packet)
+		}
+	}
+}
+
+// tablesStatus has no HTTP counterpart, system.replicas holds the same status.
+func (h *httpConnect) tablesStatus(ctx context.Context, tables []proto.QualifiedTableName) (map[proto.QualifiedTableName]proto.TableStatus, error) {
+	statuses := make(map[proto.QualifiedTableName]proto.TableStatus, len(tables))
+	for _, name := range tables {
+		database := name.Database
+		if database == "" {
+			database = "currentDatabase()"
+		} else {
+			database = "'" + stringQuoteReplacer.Replace(database) + "'"
+		}
+		query := fmt.Sprintf("SELECT engine LIKE 'Replicated%%', "+
+			"(SELECT any(absolute_delay) FROM system.replicas WHERE database = %[1]s AND table = %[2]s), "+
+			"(SELECT any(is_readonly) FROM system.replicas WHERE database = %[1]s AND table = %[2]s) "+
+			"FROM system.tables WHERE database = %[1]s AND name = %[2]s", database, "'"+stringQuoteReplacer.Replace(name.Table)+"'")
+		rows, err := h.query(ctx, func(nativeTransport, error) {}, query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				replicated, readonly uint8
+				delay                uint64 // absolute_delay is UInt64
+			)
+			if err := rows.Scan(&replicated, &delay, &readonly); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			var status proto.TableStatus
+			if replicated != 0 {
+				status = proto.TableStatus{IsReplicated: true, AbsoluteDelay: uint32(min(delay, math.MaxUint32)), IsReadonly: readonly != 0}
+			}
+			statuses[name] = status
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
+
+type excludedHostsKey struct{}
+
+// excludedHosts returns the addresses new connections must not be dialed to for ctx.
+func excludedHosts(ctx context.Context) map[string]struct{} {
+	excluded, _ := ctx.Value(excludedHostsKey{}).(map[string]struct{})
+	return excluded
+}
+
+// maxReplicaDelay returns max_replica_delay_for_distributed_queries and
+// fallback_to_stale_replicas_for_distributed_queries for the query.
+func (ch *clickhouse) maxReplicaDelay(options *QueryOptions) (time.Duration, bool) {
+	setting := func(name string) (string, bool) {
+		for _, settings := range []Settings{options.settings, ch.opt.Settings} {
+			if v, ok := settings[name]; ok {
+				if cv, ok := v.(CustomSetting); ok {
+					v = cv.Value
+				}
+				return fmt.Sprint(v), true
+			}
+		}
+		return "", false
+	}
+	maxDelay := defaultMaxReplicaDelay
+	if v, ok := setting("max_replica_delay_for_distributed_queries"); ok {
+		if seconds, err := strconv.ParseUint(v, 10, 64); err == nil {
+			maxDelay = time.Duration(seconds) * time.Second
+		}
+	}
+	fallback := true
+	if v, ok := setting("fallback_to_stale_replicas_for_distributed_queries"); ok {
+		fallback = v != "0" && v != "false"
+	}
+	return maxDelay, fallback
+}
+
+// acquireReplica acquires a connection, which with WithReplicaDelayCheck is
+// to a server whose replicas of the tables don't lag, see WithReplicaDelayCheck.
+// Connections to lagging servers are held until it returns, so the pool
+// doesn't hand them out again, and their addresses are excluded from dialing.
+func (ch *clickhouse) acquireReplica(ctx context.Context) (nativeTransport, error) {
+	options := queryOptions(ctx)
+	if len(options.replicaTables) == 0 {
+		return ch.acquire(ctx)
+	}
+	maxDelay, fallback := ch.maxReplicaDelay(&options)
+	if maxDelay == 0 {
+		return ch.acquire(ctx)
+	}
+	var (
+		tables    = qualifiedTableNames(options.replicaTables)
+		excluded  = make(map[string]struct{})
+		dialCtx   = context.WithValue(ctx, excludedHostsKey{}, excluded)
+		stale     []nativeTransport
+		best      nativeTransport
+		bestDelay time.Duration
+	)
+	defer func() {
+		for _, conn := range stale {
+			if conn != best {
+				ch.release(conn, nil)
+			}
+		}
+	}()
+	// one connection is left for the dial of the next host, once the first
+	// one was checked
+	for len(stale) == 0 || len(stale) < cap(ch.open)-1 {
+		conn, err := ch.acquire(dialCtx)
+		if err != nil {
+			if best == nil {
+				return nil, err
+			}
+			break
+		}
+		addr, tracked := ch.hosts.Load(conn.connID())
+		if tracked {
+			if _, ok := excluded[addr.(string)]; ok {
+				stale = append(stale, conn)
+				continue
+			}
+		}
+		statuses, err := conn.tablesStatus(ctx, tables)
+		if err != nil {
+			ch.release(conn, err)
+			return nil, err
+		}
+		var delay time.Duration
+		for _, status := range statuses {
+			if status.IsReplicated {
+				delay = max(delay, time.Duration(status.AbsoluteDelay)*time.Second)
+			}
+		}
+		if delay <= maxDelay {
+			return conn, nil
+		}
+		conn.getLogger().Debug("skipping lagging replica",
+			slog.Duration("delay", delay),
+			slog.Duration("max_delay", maxDelay))
+		stale = append(stale, conn)
+		if best == nil || delay < bestDelay {
+			best, bestDelay = conn, delay
+		}
+		if !tracked {
+			// without its address the same server can't be avoided
+			break
+		}
+		excluded[addr.(string)] = struct{}{}
+	}
+	if !fallback || best == nil {
+		best = nil
+		return nil, ErrReplicaDelayExceeded
+	}
+	return best, nil
+}
@@ -0,0 +1,135 @@
+package clickhouse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+func TestQualifiedTableNames(t *testing.T) {
+	assert.Equal(t, []proto.QualifiedTableName{
+		{Table: "events"},
+		{Database: "db", Table: "events"},
+	}, qualifiedTableNames([]string{"events", "db.events"}))
+}
+
+func TestMaxReplicaDelay(t *testing.T) {
+	ch := &clickhouse{opt: &Options{Settings: Settings{"max_replica_delay_for_distributed_queries": 60}}}
+
+	options := queryOptions(context.Background())
+	maxDelay, fallback := ch.maxReplicaDelay(&options)
+	assert.Equal(t, time.Minute, maxDelay)
+	assert.True(t, fallback)
+
+	options = queryOptions(Context(context.Background(), WithSettings(Settings{
+		"max_replica_delay_for_distributed_queries":          CustomSetting{"10"},
+		"fallback_to_stale_replicas_for_distributed_queries": 0,
+	})))
+	maxDelay, fallback = ch.maxReplicaDelay(&options)
+	assert.Equal(t, 10*time.Second, maxDelay)
+	assert.False(t, fallback)
+
+	ch.opt.Settings = nil
+	maxDelay, _ = ch.maxReplicaDelay(&options)
+	assert.Equal(t, 10*time.Second, maxDelay)
+	options = queryOptions(context.Background())
+	maxDelay, _ = ch.maxReplicaDelay(&options)
+	assert.Equal(t, defaultMaxReplicaDelay, maxDelay)
+}
+
+// lagTransport reports every replicated table lagging by delay seconds.
+type lagTransport struct {
+	*mockTransport
+	delay uint32
+}
+
+func (l *lagTransport) tablesStatus(ctx context.Context, tables []proto.QualifiedTableName) (map[proto.QualifiedTableName]proto.TableStatus, error) {
+	statuses := make(map[proto.QualifiedTableName]proto.TableStatus)
+	for _, table := range tables {
+		statuses[table] = proto.TableStatus{IsReplicated: true, AbsoluteDelay: l.delay}
+	}
+	return statuses, nil
+}
+
+func TestAcquireReplica(t *testing.T) {
+	open := func(delay uint32, maxOpenConns int) *clickhouse {
+		conn, err := Open(&Options{
+			MaxOpenConns: maxOpenConns,
+			DialStrategy: func(ctx context.Context, connID int, opt *Options, dial Dial) (DialResult, error) {
+				return DialResult{conn: &lagTransport{mockTransport: newMockTransport(connID), delay: delay}}, nil
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn.(*clickhouse)
+	}
+	check := WithReplicaDelayCheck("db.events")
+
+	conn, err := open(10, 0).acquireReplica(Context(context.Background(), check))
+	require.NoError(t, err)
+	assert.NotNil(t, conn)
+
+	// every server lags, the least lagging one is the fallback
+	ch := open(600, 0)
+	conn, err = ch.acquireReplica(Context(context.Background(), check))
+	require.NoError(t, err)
+	assert.NotNil(t, conn)
+	ch.release(conn, nil)
+
+	_, err = ch.acquireReplica(Context(context.Background(), check, WithSettings(Settings{
+		"fallback_to_stale_replicas_for_distributed_queries": 0,
+	})))
+	assert.ErrorIs(t, err, ErrReplicaDelayExceeded)
+	assert.Equal(t, 0, len(ch.open), "lagging connections are released")
+
+	_, err = ch.acquireReplica(Context(context.Background(), check, WithSettings(Settings{
+		"max_replica_delay_for_distributed_queries":          0,
+		"fallback_to_stale_replicas_for_distributed_queries": 0,
+	})))
+	assert.NoError(t, err, "a zero max delay disables the check")
+
+	// the connection of a single connection pool is checked
+	ch = open(10, 1)
+	conn, err = ch.acquireReplica(Context(context.Background(), check))
+	require.NoError(t, err)
+	require.NotNil(t, conn)
+	ch.release(conn, nil)
+	ch = open(600, 1)
+	conn, err = ch.acquireReplica(Context(context.Background(), check))
+	require.NoError(t, err)
+	require.NotNil(t, conn, "the lagging connection is the fallback")
+	ch.release(conn, nil)
+	_, err = ch.acquireReplica(Context(context.Background(), check, WithSettings(Settings{
+		"fallback_to_stale_replicas_for_distributed_queries": 0,
+	})))
+	assert.ErrorIs(t, err, ErrReplicaDelayExceeded)
+}
+
+func TestHTTPTablesStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		block := proto.NewBlock()
+		require.NoError(t, block.AddColumn("replicated", "UInt8"))
+		require.NoError(t, block.AddColumn("delay", "UInt64")) // absolute_delay
+		require.NoError(t, block.AddColumn("readonly", "UInt8"))
+		require.NoError(t, block.Append(uint8(1), uint64(42), uint8(1)))
+		buffer := new(chproto.Buffer)
+		require.NoError(t, block.Encode(buffer, 0))
+		_, _ = w.Write(buffer.Buf)
+	}))
+	defer srv.Close()
+
+	table := proto.QualifiedTableName{Database: "db", Table: "events"}
+	statuses, err := newTestHTTPConnect(t, srv.URL).tablesStatus(context.Background(), []proto.QualifiedTableName{table})
+	require.NoError(t, err)
+	assert.Equal(t, proto.TableStatus{IsReplicated: true, AbsoluteDelay: 42, IsReadonly: true}, statuses[table])
+}
@@ -0,0 +1,203 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetryPolicy retries transient failures of Exec, Query, QueryRow and
+// Batch.Send with exponential backoff. It is disabled unless set in
+// Options.RetryPolicy or per query with WithRetryPolicy.
+//
+// A failure is only retried when repeating the statement is safe:
+//   - errors establishing a connection, and server errors that reject a
+//     statement before it runs (TOO_MANY_SIMULTANEOUS_QUERIES, TOO_MANY_PARTS,
+//     HTTP 503, ...), are retried for every statement;
+//   - network errors and other transient server errors (NETWORK_ERROR,
+//     KEEPER_EXCEPTION, ...) are retried only for idempotent statements:
+//     queries, read-only Exec statements (SELECT, SHOW, DESCRIBE, EXISTS,
+//     EXPLAIN) and any Exec marked with WithIdempotent.
+//
+// Batch.Send is only retried with RetryInserts set. A batch is then re-sent on
+// a new connection with the same insert_deduplication_token, generated by the
+// driver unless set in the query settings, so the server discards blocks of a
+// failed attempt that were already written. The server only deduplicates inserts
+// into Replicated tables, or MergeTree tables with the
+// non_replicated_deduplication_window setting: on other tables a retried batch
+// whose response was lost is inserted twice. A batch is not retried once a
+// Flush has sent rows on the failed INSERT, as those rows are no longer buffered.
+//
+// Rows returned by Query are not retried once they have been handed to the caller.
+// database/sql connections rely on its own retry of driver.ErrBadConn instead.
+type RetryPolicy struct {
+	// MaxRetries is the number of attempts after the first one, zero disables retries.
+	MaxRetries int
+	// InitialBackoff is the wait before the first retry, default 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between retries, default 5s.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each retry, default 2.
+	Multiplier float64
+	// Retryable overrides the classification of errors, see IsRetryable.
+	Retryable func(err error, idempotent bool) bool
+	// RetryInserts retries Batch.Send. Only set it when the tables inserted
+	// into deduplicate inserts, see insert_deduplication_token above.
+	RetryInserts bool
+}
+
+func (p RetryPolicy) setDefaults() *RetryPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 5 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	return &p
+}
+
+// backoff returns the wait before the given retry (0 based). Half of it is
+// randomized so clients failing together do not retry in lockstep.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry))
+	if backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	half := time.Duration(backoff / 2)
+	return half + rand.N(half+1)
+}
+
+func (p *RetryPolicy) retryable(err error, idempotent bool) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err, idempotent)
+	}
+	return IsRetryable(err, idempotent)
+}
+
+// Server exception codes for statements rejected before they ran.
+var rejectedExceptionCodes = map[int32]struct{}{
+	202: {}, // TOO_MANY_SIMULTANEOUS_QUERIES
+	203: {}, // NO_FREE_CONNECTION
+	242: {}, // TABLE_IS_READ_ONLY
+	252: {}, // TOO_MANY_PARTS
+}
+
+// Transient server exception codes for statements that may have partially run.
+var transientExceptionCodes = map[int32]struct{}{
+	209: {}, // SOCKET_TIMEOUT
+	210: {}, // NETWORK_ERROR
+	225: {}, // NO_ZOOKEEPER
+	319: {}, // UNKNOWN_STATUS_OF_INSERT
+	999: {}, // KEEPER_EXCEPTION
+}
+
+// IsRetryable reports whether err is a transient failure the default
+// RetryPolicy retries. idempotent reports whether the failed statement
+// can safely run again after it may have reached the server.
+func IsRetryable(err error, idempotent bool) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var exception *Exception
+	if errors.As(err, &exception) {
+		if _, ok := rejectedExceptionCodes[exception.Code]; ok {
+			return true
+		}
+		_, ok := transientExceptionCodes[exception.Code]
+		return ok && idempotent
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return idempotent
+		}
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE):
+		return idempotent
+	}
+	return false
+}
+
+// retryPolicy returns the policy in effect for ctx, nil if retries are disabled.
+func retryPolicy(ctx context.Context, opt *Options) *RetryPolicy {
+	policy := opt.RetryPolicy
+	if o, ok := ctx.Value(_contextOptionKey).(QueryOptions); ok && o.retry.override {
+		policy = o.retry.policy
+	}
+	if policy == nil || policy.MaxRetries <= 0 {
+		return nil
+	}
+	return policy
+}
+
+// insertRetryPolicy returns the policy in effect for the batches of ctx, nil
+// unless it retries inserts.
+func insertRetryPolicy(ctx context.Context, opt *Options) *RetryPolicy {
+	if policy := retryPolicy(ctx, opt); policy != nil && policy.RetryInserts {
+		return policy
+	}
+	return nil
+}
+
+// idempotentStatement reports whether an Exec statement only reads, so that
+// repeating it cannot change data.
+func idempotentStatement(ctx context.Context, query string) bool {
+	if o, ok := ctx.Value(_contextOptionKey).(QueryOptions); ok && o.retry.idempotent {
+		return true
+	}
+	keyword, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	switch strings.ToUpper(strings.TrimSpace(keyword)) {
+	case "SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXISTS", "EXPLAIN":
+		return true
+	}
+	return false
+}
+
+// deduplicationToken returns the insert_deduplication_token a retried batch is
+// sent with, empty when retries are disabled or the caller set their own.
+func deduplicationToken(policy *RetryPolicy, options *QueryOptions) string {
+	if policy == nil {
+		return ""
+	}
+	if _, ok := options.settings["insert_deduplication_token"]; ok {
+		return ""
+	}
+	return uuid.NewString()
+}
+
+// sleepContext waits for d or until ctx is done, returning the context error in the latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
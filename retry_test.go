@@ -0,0 +1,267 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		idempotent    bool
+		nonIdempotent bool
+	}{
+		{"nil", nil, false, false},
+		{"canceled", context.Canceled, false, false},
+		{"deadline", fmt.Errorf("read: %w", context.DeadlineExceeded), false, false},
+		{"too many simultaneous queries", &Exception{Code: 202}, true, true},
+		{"too many parts", &Exception{Code: 252}, true, true},
+		{"network error exception", &Exception{Code: 210}, true, false},
+		{"keeper exception", &Exception{Code: 999}, true, false},
+		{"syntax error", &Exception{Code: 62}, false, false},
+		{"http 503", &HTTPError{StatusCode: 503, Err: errors.New("unavailable")}, true, true},
+		{"http 502", &HTTPError{StatusCode: 502, Err: errors.New("bad gateway")}, true, false},
+		{"http 404", &HTTPError{StatusCode: 404, Err: errors.New("not found")}, false, false},
+		{"http exception", &HTTPError{StatusCode: 500, Err: &Exception{Code: 202}}, true, true},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true, false},
+		{"unexpected eof", io.ErrUnexpectedEOF, true, false},
+		{"other", errors.New("boom"), false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.idempotent, IsRetryable(test.err, true))
+			assert.Equal(t, test.nonIdempotent, IsRetryable(test.err, false))
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}.setDefaults()
+	assert.Equal(t, float64(2), policy.Multiplier)
+	for retry, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		backoff := policy.backoff(retry)
+		assert.GreaterOrEqual(t, backoff, max/2, "retry %d", retry)
+		assert.LessOrEqual(t, backoff, max, "retry %d", retry)
+	}
+}
+
+func TestIdempotentStatement(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, idempotentStatement(ctx, "  select 1"))
+	assert.True(t, idempotentStatement(ctx, "SHOW TABLES"))
+	assert.False(t, idempotentStatement(ctx, "INSERT INTO t VALUES (1)"))
+	assert.False(t, idempotentStatement(ctx, "ALTER TABLE t DELETE WHERE 1"))
+	assert.True(t, idempotentStatement(Context(ctx, WithIdempotent()), "ALTER TABLE t DELETE WHERE 1"))
+}
+
+// execTransport fails exec with errs in order, then succeeds. It records
+// the query ID of every call in queryIDs, if set.
+type execTransport struct {
+	*mockTransport
+	errs     *[]error
+	calls    *int
+	queryIDs *[]string
+}
+
+func (e *execTransport) exec(ctx context.Context, query string, args ...any) error {
+	*e.calls++
+	if e.queryIDs != nil {
+		*e.queryIDs = append(*e.queryIDs, queryOptions(ctx).queryID)
+	}
+	if len(*e.errs) == 0 {
+		return nil
+	}
+	err := (*e.errs)[0]
+	*e.errs = (*e.errs)[1:]
+	return err
+}
+
+func openRetryTestConn(t *testing.T, policy *RetryPolicy, errs ...error) (*clickhouse, *int) {
+	t.Helper()
+	calls := 0
+	conn, err := Open(&Options{
+		RetryPolicy: policy,
+		DialStrategy: func(ctx context.Context, connID int, opt *Options, dial Dial) (DialResult, error) {
+			return DialResult{conn: &execTransport{mockTransport: newMockTransport(connID), errs: &errs, calls: &calls}}, nil
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn.(*clickhouse), &calls
+}
+
+func TestExecRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := &RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}
+	busy := &Exception{Code: 202, Message: "too many simultaneous queries"}
+	reset := fmt.Errorf("write: %w", syscall.ECONNRESET)
+
+	t.Run("rejected statement is retried", func(t *testing.T) {
+		conn, calls := openRetryTestConn(t, policy, busy, busy)
+		require.NoError(t, conn.Exec(ctx, "INSERT INTO t VALUES (1)"))
+		assert.Equal(t, 3, *calls)
+	})
+	t.Run("retries are bounded", func(t *testing.T) {
+		conn, calls := openRetryTestConn(t, policy, busy, busy, busy)
+		assert.ErrorIs(t, conn.Exec(ctx, "INSERT INTO t VALUES (1)"), busy)
+		assert.Equal(t, 3, *calls)
+	})
+	t.Run("network error only retried when idempotent", func(t *testing.T) {
+		conn, calls := openRetryTestConn(t, policy, reset)
+		assert.ErrorIs(t, conn.Exec(ctx, "INSERT INTO t VALUES (1)"), syscall.ECONNRESET)
+		assert.Equal(t, 1, *calls)
+
+		conn, calls = openRetryTestConn(t, policy, reset)
+		require.NoError(t, conn.Exec(ctx, "SELECT 1"))
+		assert.Equal(t, 2, *calls)
+	})
+	t.Run("query option disables retries", func(t *testing.T) {
+		conn, calls := openRetryTestConn(t, policy, busy)
+		assert.ErrorIs(t, conn.Exec(Context(ctx, WithRetryPolicy(nil)), "SELECT 1"), busy)
+		assert.Equal(t, 1, *calls)
+	})
+	t.Run("query option enables retries", func(t *testing.T) {
+		conn, calls := openRetryTestConn(t, nil, busy)
+		require.NoError(t, conn.Exec(Context(ctx, WithRetryPolicy(policy)), "SELECT 1"))
+		assert.Equal(t, 2, *calls)
+	})
+	t.Run("custom classification", func(t *testing.T) {
+		custom := &RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond, Retryable: func(err error, idempotent bool) bool {
+			return false
+		}}
+		conn, calls := openRetryTestConn(t, custom, busy)
+		assert.ErrorIs(t, conn.Exec(ctx, "SELECT 1"), busy)
+		assert.Equal(t, 1, *calls)
+	})
+}
+
+func TestRetryQueryID(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		busy     = &Exception{Code: 202, Message: "too many simultaneous queries"}
+		queryIDs []string
+	)
+	open := func(errs ...error) *clickhouse {
+		calls := 0
+		queryIDs = nil
+		conn, err := Open(&Options{
+			RetryPolicy: &RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond},
+			DialStrategy: func(ctx context.Context, connID int, opt *Options, dial Dial) (DialResult, error) {
+				return DialResult{conn: &execTransport{mockTransport: newMockTransport(connID), errs: &errs, calls: &calls, queryIDs: &queryIDs}}, nil
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn.(*clickhouse)
+	}
+
+	err := open(busy, busy, busy).Exec(ctx, "SELECT 1")
+	require.Len(t, queryIDs, 3)
+	assert.NotEqual(t, queryIDs[0], queryIDs[1], "every attempt runs with a new query ID")
+	assert.NotEqual(t, queryIDs[1], queryIDs[2])
+	var exception *Exception
+	require.ErrorAs(t, err, &exception)
+	assert.Equal(t, queryIDs[2], exception.QueryID, "the error carries the query ID of the last attempt")
+
+	require.NoError(t, open(busy).Exec(Context(ctx, WithQueryID("my-query")), "SELECT 1"))
+	assert.Equal(t, []string{"my-query", "my-query"}, queryIDs, "the query ID of the caller is kept")
+}
+
+// insertServer answers a client hello and the INSERT that follows with the
+// header of a UInt64 column, then closes conn.
+func insertServer(conn net.Conn) {
+	go func() {
+		defer conn.Close()
+		var (
+			reader = chproto.NewReader(conn)
+			buffer = new(chproto.Buffer)
+		)
+		_, _ = reader.ReadByte()
+		_, _ = reader.Str() // client name
+		for range 3 {
+			_, _ = reader.UVarInt()
+		}
+		for range 3 {
+			_, _ = reader.Str() // database, username, password
+		}
+		buffer.PutByte(proto.ServerHello)
+		buffer.PutString("ClickHouse")
+		buffer.PutUVarInt(25)
+		buffer.PutUVarInt(8)
+		buffer.PutUVarInt(proto.DBMS_TCP_PROTOCOL_VERSION)
+		buffer.PutString("UTC")
+		buffer.PutString("server")
+		buffer.PutUVarInt(1)
+		conn.Write(buffer.Buf)
+		_, _ = reader.Str() // addendum quota key
+		go io.Copy(io.Discard, reader)
+
+		block := &proto.Block{ServerContext: &column.ServerContext{Timezone: time.UTC}}
+		_ = block.AddColumn("a", "UInt64")
+		buffer.Reset()
+		buffer.PutByte(proto.ServerData)
+		buffer.PutString("")
+		_ = block.Encode(buffer, proto.DBMS_TCP_PROTOCOL_VERSION)
+		conn.Write(buffer.Buf)
+	}()
+}
+
+func TestBatchRetryWithoutAcquire(t *testing.T) {
+	var (
+		release = func(nativeTransport, error) {}
+		ctx     = Context(context.Background(), WithRetryPolicy(&RetryPolicy{
+			MaxRetries:     2,
+			InitialBackoff: time.Millisecond,
+			RetryInserts:   true,
+			Retryable:      func(error, bool) bool { return true },
+		}))
+	)
+	dialInsertServer := func(t *testing.T) *connect {
+		client, server := net.Pipe()
+		insertServer(server)
+		conn, err := dial(context.Background(), "127.0.0.1:9000", 1, (&Options{
+			DialContext: func(context.Context, string) (net.Conn, error) { return client, nil },
+		}).setDefaults())
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.close() })
+		return conn
+	}
+
+	t.Run("database/sql batch", func(t *testing.T) {
+		conn := dialInsertServer(t)
+		b, err := conn.prepareBatch(ctx, release, nil, "INSERT INTO t", driver.PrepareBatchOptions{})
+		require.NoError(t, err)
+		assert.Nil(t, b.(*batch).retry, "the batch can't acquire another connection")
+		require.NoError(t, b.Append(uint64(1)))
+		assert.Error(t, b.Send())
+	})
+	t.Run("InsertFormat", func(t *testing.T) {
+		conn := dialInsertServer(t)
+		assert.Error(t, conn.insertFormat(ctx, release, "CSV", "INSERT INTO t FORMAT CSV", strings.NewReader("1\n")))
+	})
+}
+
+func TestBatchRetryOptIn(t *testing.T) {
+	ctx := Context(context.Background(), WithRetryPolicy(&RetryPolicy{MaxRetries: 1}))
+	assert.Nil(t, insertRetryPolicy(ctx, &Options{}), "batches are not retried unless RetryInserts is set")
+	ctx = Context(context.Background(), WithRetryPolicy(&RetryPolicy{MaxRetries: 1, RetryInserts: true}))
+	assert.NotNil(t, insertRetryPolicy(ctx, &Options{}))
+}
@@ -0,0 +1,246 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+var ErrSessionClosed = errors.New("clickhouse: session is closed")
+
+// defaultSessionKeepAlive is half of the server default session_timeout.
+const defaultSessionKeepAlive = 30 * time.Second
+
+func (ch *clickhouse) Session(ctx context.Context, opts driver.SessionOptions) (driver.Session, error) {
+	conn, err := ch.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		ch:   ch,
+		conn: conn,
+		lock: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	if h, ok := conn.(*httpConnect); ok {
+		if opts.ID == "" {
+			opts.ID = uuid.NewString()
+		}
+		s.id = opts.ID
+		h.session = &opts
+	}
+	keepAlive := opts.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = defaultSessionKeepAlive
+		if opts.Timeout > 0 {
+			keepAlive = opts.Timeout / 2
+		}
+	}
+	s.stopped = make(chan struct{})
+	if keepAlive > 0 {
+		go s.keepAlive(keepAlive)
+	} else {
+		close(s.stopped)
+	}
+	conn.getLogger().Debug("session started", slog.String("session_id", s.id))
+	return s, nil
+}
+
+// session serialises statements on a pinned connection. lock is held from the
+// start of a statement until the connection is released by it.
+type session struct {
+	ch   *clickhouse
+	conn nativeTransport
+	id   string
+
+	lock    chan struct{}
+	closed  bool  // guarded by lock
+	err     error // guarded by lock, breaks the session
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func (s *session) ID() string {
+	return s.id
+}
+
+// acquire takes the lock for a statement, see nativeTransportAcquire.
+func (s *session) acquire(ctx context.Context) (nativeTransport, error) {
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+	switch {
+	case s.closed:
+		<-s.lock
+		return nil, ErrSessionClosed
+	case s.err != nil:
+		err := s.err
+		<-s.lock
+		return nil, err
+	}
+	s.conn.setReleased(false)
+	return s.conn, nil
+}
+
+// release ends a statement, see nativeTransportRelease. Server exceptions and
+// drained cancellations leave the connection usable; over the native protocol
+// any other error leaves it in an unknown state, which breaks the session and
+// its state with it.
+func (s *session) release(_ nativeTransport, err error) {
+	if s.conn.isReleased() {
+		return
+	}
+	s.conn.setReleased(true)
+	var (
+		exception *Exception
+		cancelled *cancelledError
+	)
+	if err != nil && !errors.As(err, &exception) && !errors.As(err, &cancelled) {
+		if _, ok := s.conn.(*httpConnect); !ok {
+			s.err = err
+		}
+	}
+	<-s.lock
+}
+
+func (s *session) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, queryID := s.ch.withQueryID(ctx)
+	conn.getLogger().Debug("executing query in session", slog.String("sql", query), slog.String("query_id", queryID))
+	r, err := conn.query(ctx, s.release, query, args...)
+	if err != nil {
+		return nil, withQueryIDError(err, queryID)
+	}
+	return r, nil
+}
+
+func (s *session) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return &row{
+			err: err,
+		}
+	}
+	ctx, queryID := s.ch.withQueryID(ctx)
+	conn.getLogger().Debug("executing query row in session", slog.String("sql", query), slog.String("query_id", queryID))
+	r := conn.queryRow(ctx, s.release, query, args...)
+	r.err = withQueryIDError(r.err, queryID)
+	return r
+}
+
+func (s *session) Select(ctx context.Context, dest any, query string, args ...any) error {
+	return scanSelect(s.Query, ctx, dest, query, args...)
+}
+
+func (s *session) Exec(ctx context.Context, query string, args ...any) error {
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, queryID := s.ch.withQueryID(ctx)
+	conn.getLogger().Debug("executing statement in session", slog.String("sql", query), slog.String("query_id", queryID))
+	if asyncOpt := queryOptionsAsync(ctx); asyncOpt.ok {
+		err = conn.asyncInsert(ctx, query, asyncOpt.wait, args...)
+	} else {
+		err = conn.exec(ctx, query, args...)
+	}
+	s.release(conn, err)
+	return withQueryIDError(err, queryID)
+}
+
+func (s *session) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, queryID := s.ch.withQueryID(ctx)
+	conn.getLogger().Debug("preparing batch in session", slog.String("sql", query), slog.String("query_id", queryID))
+	batch, err := conn.prepareBatch(ctx, s.release, s.acquire, query, getPrepareBatchOptions(opts...))
+	if err != nil {
+		return nil, withQueryIDError(err, queryID)
+	}
+	return batch, nil
+}
+
+func (s *session) Ping(ctx context.Context) error {
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = conn.ping(ctx)
+	s.release(conn, err)
+	return err
+}
+
+// keepAlive pings the idle session every interval, so neither the server
+// session nor the connection time out between statements.
+func (s *session) keepAlive(interval time.Duration) {
+	defer close(s.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		select {
+		case s.lock <- struct{}{}:
+		default:
+			// a statement is running and keeps the session alive
+			continue
+		}
+		if !s.closed && s.err == nil {
+			s.conn.setReleased(false)
+			ctx, cancel := context.WithTimeout(context.Background(), s.ch.opt.ReadTimeout)
+			err := s.conn.ping(ctx)
+			cancel()
+			if err != nil {
+				s.conn.getLogger().Debug("session keep alive failed", slog.Any("error", err))
+			}
+			s.release(s.conn, err)
+			continue
+		}
+		<-s.lock
+	}
+}
+
+func (s *session) Close() error {
+	s.lock <- struct{}{}
+	if s.closed {
+		<-s.lock
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	<-s.lock
+	<-s.stopped
+
+	s.conn.getLogger().Debug("session closed", slog.String("session_id", s.id))
+	s.conn.setReleased(false)
+	if h, ok := s.conn.(*httpConnect); ok {
+		h.session = nil
+		s.ch.release(s.conn, nil)
+		return nil
+	}
+	if s.err != nil {
+		s.ch.release(s.conn, s.err)
+		return nil
+	}
+	// the temporary tables of the session must not outlive it
+	select {
+	case <-s.ch.open:
+	default:
+	}
+	return s.conn.close()
+}
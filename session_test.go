@@ -0,0 +1,106 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// execErrTransport fails every exec with err.
+type execErrTransport struct {
+	*mockTransport
+	err error
+}
+
+func (e *execErrTransport) exec(ctx context.Context, query string, args ...any) error {
+	return e.err
+}
+
+func openSessionConn(t *testing.T, transport func(connID int) nativeTransport) *clickhouse {
+	conn, err := Open(&Options{
+		DialStrategy: func(ctx context.Context, connID int, opt *Options, dial Dial) (DialResult, error) {
+			return DialResult{conn: transport(connID)}, nil
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn.(*clickhouse)
+}
+
+func TestSession(t *testing.T) {
+	var mock *mockTransport
+	ch := openSessionConn(t, func(connID int) nativeTransport {
+		mock = newMockTransport(connID)
+		return mock
+	})
+	ctx := context.Background()
+
+	s, err := ch.Session(ctx, driver.SessionOptions{KeepAlive: -1})
+	require.NoError(t, err)
+	assert.Empty(t, s.ID(), "native sessions have no session_id")
+	assert.Equal(t, 1, ch.Stats().Open, "the connection is pinned")
+	require.NoError(t, s.Exec(ctx, "CREATE TEMPORARY TABLE t (id UInt64)"))
+	require.NoError(t, s.Ping(ctx))
+
+	// statements wait for the running one
+	_, err = s.(*session).acquire(ctx)
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Exec(waitCtx, "SELECT 1"), context.DeadlineExceeded)
+	s.(*session).release(mock, nil)
+	require.NoError(t, s.Exec(ctx, "SELECT 1"))
+
+	require.NoError(t, s.Close())
+	assert.True(t, mock.closed, "the temporary tables don't return to the pool")
+	assert.Equal(t, 0, ch.Stats().Open)
+	assert.Equal(t, 0, ch.Stats().Idle)
+	assert.ErrorIs(t, s.Exec(ctx, "SELECT 1"), ErrSessionClosed)
+	assert.NoError(t, s.Close())
+}
+
+func TestSessionBroken(t *testing.T) {
+	transport := &execErrTransport{mockTransport: newMockTransport(1)}
+	ch := openSessionConn(t, func(int) nativeTransport { return transport })
+	ctx := context.Background()
+
+	s, err := ch.Session(ctx, driver.SessionOptions{KeepAlive: -1})
+	require.NoError(t, err)
+	defer s.Close()
+
+	transport.err = &Exception{Code: 60, Message: "Table doesn't exist"}
+	assert.Error(t, s.Exec(ctx, "SELECT * FROM missing"))
+	transport.err = nil
+	require.NoError(t, s.Exec(ctx, "SELECT 1"), "a server exception keeps the session")
+
+	broken := errors.New("connection reset by peer")
+	transport.err = broken
+	assert.ErrorIs(t, s.Exec(ctx, "SELECT 1"), broken)
+	transport.err = nil
+	assert.ErrorIs(t, s.Exec(ctx, "SELECT 1"), broken, "the session state is lost with the connection")
+}
+
+func TestSessionHTTPRequest(t *testing.T) {
+	h := &httpConnect{
+		opt: &Options{},
+		url: &url.URL{Scheme: "http", Host: "127.0.0.1:8123"},
+	}
+	h.session = &driver.SessionOptions{ID: "session-1", Timeout: 2 * time.Minute}
+	options := queryOptions(context.Background())
+	req, err := h.createRequest(context.Background(), h.url.String(), nil, &options, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "session-1", req.URL.Query().Get("session_id"))
+	assert.Equal(t, "120", req.URL.Query().Get("session_timeout"))
+
+	h.session = nil
+	req, err = h.createRequest(context.Background(), h.url.String(), nil, &options, nil)
+	require.NoError(t, err)
+	assert.False(t, req.URL.Query().Has("session_id"))
+}
@@ -0,0 +1,446 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/cityhash102"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// Shard is a shard of a cluster the rows of a ShardedBatch are inserted into.
+type Shard struct {
+	Num uint32
+	// Weight is the share of the rows the shard receives, like shard_weight
+	// of a Distributed table. A zero weight receives no rows.
+	Weight uint32
+	// Replicas are the native protocol addresses of the replicas of the shard.
+	Replicas []string
+	// Conn inserts the rows of the shard, typically opened with Replicas as Addr.
+	Conn driver.Conn
+}
+
+// ClusterShards reads the shards of cluster from system.clusters. Conn of the
+// returned shards is nil and must be set before use.
+func ClusterShards(ctx context.Context, conn driver.Conn, cluster string) ([]Shard, error) {
+	rows, err := conn.Query(ctx, "SELECT shard_num, shard_weight, host_name, port FROM system.clusters WHERE cluster = ? ORDER BY shard_num, replica_num", cluster)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var shards []Shard
+	for rows.Next() {
+		var (
+			num, weight uint32
+			host        string
+			port        uint16
+		)
+		if err := rows.Scan(&num, &weight, &host, &port); err != nil {
+			return nil, err
+		}
+		if len(shards) == 0 || shards[len(shards)-1].Num != num {
+			shards = append(shards, Shard{Num: num, Weight: weight})
+		}
+		shard := &shards[len(shards)-1]
+		shard.Replicas = append(shard.Replicas, net.JoinHostPort(host, strconv.Itoa(int(port))))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("clickhouse: cluster %q not found in system.clusters", cluster)
+	}
+	return shards, nil
+}
+
+type shardingFunc int
+
+const (
+	shardByColumn shardingFunc = iota
+	shardByRand
+	shardByCityHash64
+	shardByIntHash64
+)
+
+// ShardingKey is the sharding_key expression of a Distributed table. The key of
+// a row modulo the total weight of the shards selects its shard, as the server does.
+type ShardingKey struct {
+	fn      shardingFunc
+	columns []string
+}
+
+// ShardByColumn uses the value of an integer column as the sharding key.
+func ShardByColumn(column string) ShardingKey {
+	return ShardingKey{fn: shardByColumn, columns: []string{column}}
+}
+
+// ShardByRand distributes rows randomly, like rand().
+func ShardByRand() ShardingKey {
+	return ShardingKey{fn: shardByRand}
+}
+
+// ShardByCityHash64 computes the sharding key like cityHash64(columns...).
+func ShardByCityHash64(columns ...string) ShardingKey {
+	return ShardingKey{fn: shardByCityHash64, columns: columns}
+}
+
+// ShardByIntHash64 computes the sharding key like intHash64(column).
+func ShardByIntHash64(column string) ShardingKey {
+	return ShardingKey{fn: shardByIntHash64, columns: []string{column}}
+}
+
+var shardingKeyMatch = regexp.MustCompile(`^(\w+)\s*\(([^()]*)\)$`)
+
+// ParseShardingKey parses a sharding key expression: rand(), cityHash64(a, ...),
+// intHash64(a) or a column name.
+func ParseShardingKey(expr string) (ShardingKey, error) {
+	expr = strings.TrimSpace(expr)
+	match := shardingKeyMatch.FindStringSubmatch(expr)
+	if match == nil {
+		if name := unquoteIdentifier(expr); name != "" && !strings.ContainsAny(name, " (),") {
+			return ShardByColumn(name), nil
+		}
+		return ShardingKey{}, fmt.Errorf("clickhouse: unsupported sharding key %q", expr)
+	}
+	var args []string
+	if strings.TrimSpace(match[2]) != "" {
+		for _, arg := range strings.Split(match[2], ",") {
+			args = append(args, unquoteIdentifier(strings.TrimSpace(arg)))
+		}
+	}
+	switch {
+	case match[1] == "rand" && len(args) == 0:
+		return ShardByRand(), nil
+	case match[1] == "cityHash64" && len(args) != 0:
+		return ShardByCityHash64(args...), nil
+	case match[1] == "intHash64" && len(args) == 1:
+		return ShardByIntHash64(args[0]), nil
+	}
+	return ShardingKey{}, fmt.Errorf("clickhouse: unsupported sharding key %q", expr)
+}
+
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 && (name[0] == '`' && name[len(name)-1] == '`' || name[0] == '"' && name[len(name)-1] == '"') {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// shardingColumn converts appended values of a key column to the value the server hashes.
+type shardingColumn struct {
+	index   int
+	typ     string // type without LowCardinality
+	scratch column.Interface
+}
+
+// shardingValue is a value of a key column as seen by the hash functions.
+type shardingValue struct {
+	raw     uint64 // bits of the value zero extended to 64 bits
+	value   uint64 // the value converted to UInt64
+	bytes   []byte // of strings, hashed as is
+	str     bool
+	integer bool
+}
+
+func newShardingColumn(index int, col column.Interface) (*shardingColumn, error) {
+	typ := string(col.Type())
+	if strings.HasPrefix(typ, "LowCardinality(") {
+		typ = strings.TrimSuffix(strings.TrimPrefix(typ, "LowCardinality("), ")")
+	}
+	switch {
+	case typ == "String", strings.HasPrefix(typ, "FixedString("), typ == "Float32", typ == "Float64",
+		typ == "Date", typ == "Date32", typ == "DateTime", strings.HasPrefix(typ, "DateTime("):
+	case slices.Contains([]string{"Int8", "Int16", "Int32", "Int64", "UInt8", "UInt16", "UInt32", "UInt64"}, typ):
+	default:
+		return nil, fmt.Errorf("clickhouse: column %s of type %s is not supported in a sharding key", col.Name(), col.Type())
+	}
+	scratch, err := column.Type(typ).Column(col.Name(), &column.ServerContext{Timezone: time.UTC})
+	if err != nil {
+		return nil, err
+	}
+	return &shardingColumn{index: index, typ: typ, scratch: scratch}, nil
+}
+
+func (c *shardingColumn) value(v any) (shardingValue, error) {
+	c.scratch.Reset()
+	if err := c.scratch.AppendRow(v); err != nil {
+		return shardingValue{}, &OpError{Op: "ShardedBatch.Append", ColumnName: c.scratch.Name(), Err: err}
+	}
+	switch v := c.scratch.Row(0, false).(type) {
+	case int8:
+		return shardingValue{raw: uint64(uint8(v)), value: uint64(v), integer: true}, nil
+	case int16:
+		return shardingValue{raw: uint64(uint16(v)), value: uint64(v), integer: true}, nil
+	case int32:
+		return shardingValue{raw: uint64(uint32(v)), value: uint64(v), integer: true}, nil
+	case int64:
+		return shardingValue{raw: uint64(v), value: uint64(v), integer: true}, nil
+	case uint8:
+		return shardingValue{raw: uint64(v), value: uint64(v), integer: true}, nil
+	case uint16:
+		return shardingValue{raw: uint64(v), value: uint64(v), integer: true}, nil
+	case uint32:
+		return shardingValue{raw: uint64(v), value: uint64(v), integer: true}, nil
+	case uint64:
+		return shardingValue{raw: v, value: v, integer: true}, nil
+	case float32:
+		return shardingValue{raw: uint64(math.Float32bits(v))}, nil
+	case float64:
+		return shardingValue{raw: math.Float64bits(v)}, nil
+	case string:
+		return shardingValue{bytes: []byte(v), str: true}, nil
+	case time.Time:
+		switch c.typ {
+		case "Date":
+			days := uint16(v.Unix() / 86400)
+			return shardingValue{raw: uint64(days), value: uint64(days), integer: true}, nil
+		case "Date32":
+			days := int32(math.Floor(float64(v.Unix()) / 86400))
+			return shardingValue{raw: uint64(uint32(days)), value: uint64(days), integer: true}, nil
+		default:
+			seconds := uint32(v.Unix())
+			return shardingValue{raw: uint64(seconds), value: uint64(seconds), integer: true}, nil
+		}
+	}
+	return shardingValue{}, fmt.Errorf("clickhouse: column %s of type %s is not supported in a sharding key", c.scratch.Name(), c.scratch.Type())
+}
+
+// intHash64 is the intHash64 function of ClickHouse, the mixer of MurmurHash3
+// applied to x XORed with a salt.
+func intHash64(x uint64) uint64 {
+	x ^= 0x4CF2D2BAAE6DA887
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// cityHash64 is the cityHash64 function of ClickHouse, which hashes numbers
+// with intHash64 and combines the hashes of several arguments.
+func cityHash64(values ...shardingValue) uint64 {
+	var hash uint64
+	for i, v := range values {
+		var h uint64
+		if v.str {
+			h = cityhash102.CityHash64(v.bytes, uint32(len(v.bytes)))
+		} else {
+			h = intHash64(v.raw)
+		}
+		if i == 0 {
+			hash = h
+		} else {
+			hash = cityhash102.Hash128to64(cityhash102.Uint128{hash, h})
+		}
+	}
+	return hash
+}
+
+// ShardedBatchOptions configures PrepareShardedBatch.
+type ShardedBatchOptions struct {
+	Shards []Shard
+	Key    ShardingKey
+	// BatchOptions are passed to PrepareBatch of every shard.
+	BatchOptions []driver.PrepareBatchOption
+}
+
+// ShardedBatchError reports the shards a Send failed on, the other shards succeeded.
+type ShardedBatchError struct {
+	Errors map[uint32]error // by shard number
+}
+
+func (e *ShardedBatchError) Error() string {
+	nums := make([]uint32, 0, len(e.Errors))
+	for num := range e.Errors {
+		nums = append(nums, num)
+	}
+	slices.Sort(nums)
+	messages := make([]string, 0, len(nums))
+	for _, num := range nums {
+		messages = append(messages, fmt.Sprintf("shard %d: %s", num, e.Errors[num]))
+	}
+	return "clickhouse [ShardedBatch.Send]: " + strings.Join(messages, "; ")
+}
+
+func (e *ShardedBatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// ShardedBatch splits the rows of an INSERT into the local tables of the shards
+// of a Distributed table client-side, bypassing its server-side fan-out. Rows
+// go to the shard selected by the sharding key, using the same hash functions
+// and shard weights as the Distributed table, so they end up where inserting
+// into it would have put them.
+type ShardedBatch struct {
+	shards  []Shard
+	batches []driver.Batch
+	slots   []int // shard index by slot, a shard has Weight slots
+	key     ShardingKey
+	columns []*shardingColumn
+	sent    bool
+}
+
+// PrepareShardedBatch prepares the INSERT query, against the local table, on
+// every shard. Rows are appended in the column order of the query.
+func PrepareShardedBatch(ctx context.Context, query string, opts ShardedBatchOptions) (*ShardedBatch, error) {
+	b := &ShardedBatch{
+		shards: opts.Shards,
+		key:    opts.Key,
+	}
+	for i, shard := range opts.Shards {
+		if shard.Conn == nil {
+			return nil, fmt.Errorf("clickhouse: shard %d has no connection", shard.Num)
+		}
+		for range shard.Weight {
+			b.slots = append(b.slots, i)
+		}
+	}
+	if len(b.slots) == 0 {
+		return nil, errors.New("clickhouse: a sharded batch needs a shard with a weight")
+	}
+	for _, shard := range opts.Shards {
+		batch, err := shard.Conn.PrepareBatch(ctx, query, opts.BatchOptions...)
+		if err != nil {
+			b.Abort()
+			return nil, fmt.Errorf("clickhouse: prepare batch on shard %d: %w", shard.Num, err)
+		}
+		b.batches = append(b.batches, batch)
+	}
+	columns := b.batches[0].Columns()
+	for _, name := range opts.Key.columns {
+		index := slices.IndexFunc(columns, func(col column.Interface) bool { return col.Name() == name })
+		if index == -1 {
+			b.Abort()
+			return nil, fmt.Errorf("clickhouse: sharding key column %s is not inserted", name)
+		}
+		col, err := newShardingColumn(index, columns[index])
+		if err != nil {
+			b.Abort()
+			return nil, err
+		}
+		b.columns = append(b.columns, col)
+	}
+	return b, nil
+}
+
+// shard returns the index of the shard of a row.
+func (b *ShardedBatch) shard(v []any) (int, error) {
+	values := make([]shardingValue, 0, len(b.columns))
+	for _, col := range b.columns {
+		if col.index >= len(v) {
+			return 0, fmt.Errorf("clickhouse: sharding key column %s is missing in the row", col.scratch.Name())
+		}
+		value, err := col.value(v[col.index])
+		if err != nil {
+			return 0, err
+		}
+		values = append(values, value)
+	}
+	var key uint64
+	switch b.key.fn {
+	case shardByRand:
+		key = uint64(rand.Uint32())
+	case shardByCityHash64:
+		key = cityHash64(values...)
+	case shardByIntHash64:
+		if !values[0].integer {
+			return 0, fmt.Errorf("clickhouse: intHash64 sharding key column %s is not an integer", b.columns[0].scratch.Name())
+		}
+		key = intHash64(values[0].value)
+	default:
+		if !values[0].integer {
+			return 0, fmt.Errorf("clickhouse: sharding key column %s is not an integer", b.columns[0].scratch.Name())
+		}
+		// a signed key is used as the unsigned integer of the same width
+		key = values[0].raw
+	}
+	return b.slots[key%uint64(len(b.slots))], nil
+}
+
+// Append appends a row to the batch of its shard.
+func (b *ShardedBatch) Append(v ...any) error {
+	if b.sent {
+		return ErrBatchAlreadySent
+	}
+	shard, err := b.shard(v)
+	if err != nil {
+		return err
+	}
+	return b.batches[shard].Append(v...)
+}
+
+// Rows returns the number of rows appended to all shards.
+func (b *ShardedBatch) Rows() int {
+	var rows int
+	for _, batch := range b.batches {
+		rows += batch.Rows()
+	}
+	return rows
+}
+
+// ShardRows returns the number of rows appended to each shard, by shard number.
+func (b *ShardedBatch) ShardRows() map[uint32]int {
+	rows := make(map[uint32]int, len(b.batches))
+	for i, batch := range b.batches {
+		rows[b.shards[i].Num] = batch.Rows()
+	}
+	return rows
+}
+
+// Send sends the batches of all shards in parallel. If some of them fail it
+// returns a *ShardedBatchError, the batches of the other shards were inserted.
+func (b *ShardedBatch) Send() error {
+	if b.sent {
+		return ErrBatchAlreadySent
+	}
+	b.sent = true
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[uint32]error)
+	)
+	for i, batch := range b.batches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := batch.Send(); err != nil {
+				mu.Lock()
+				errs[b.shards[i].Num] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return &ShardedBatchError{Errors: errs}
+	}
+	return nil
+}
+
+// Abort ends the INSERT on every shard without sending the rows.
+func (b *ShardedBatch) Abort() error {
+	b.sent = true
+	var errs []error
+	for _, batch := range b.batches {
+		if err := batch.Abort(); err != nil && !errors.Is(err, ErrBatchAlreadySent) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
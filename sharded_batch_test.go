@@ -0,0 +1,146 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+func TestParseShardingKey(t *testing.T) {
+	tests := map[string]ShardingKey{
+		"rand()":                  ShardByRand(),
+		"cityHash64(id)":          ShardByCityHash64("id"),
+		" cityHash64(`id`, name)": ShardByCityHash64("id", "name"),
+		"intHash64(user_id)":      ShardByIntHash64("user_id"),
+		"user_id":                 ShardByColumn("user_id"),
+	}
+	for expr, expected := range tests {
+		key, err := ParseShardingKey(expr)
+		require.NoError(t, err, expr)
+		assert.Equal(t, expected, key, expr)
+	}
+	for _, expr := range []string{"", "sipHash64(id)", "intHash64(a, b)", "rand(1)", "id % 2"} {
+		_, err := ParseShardingKey(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestShardingHashes(t *testing.T) {
+	// SELECT cityHash64('')
+	assert.Equal(t, uint64(11160318154034397263), cityHash64(shardingValue{str: true}))
+	// SELECT intHash64(0), cityHash64(42)
+	assert.Equal(t, uint64(4761183170873013810), intHash64(0))
+	assert.Equal(t, uint64(11490350930367293593), cityHash64(shardingValue{raw: 42}), "numbers are hashed with intHash64")
+	assert.NotEqual(t, cityHash64(shardingValue{raw: 1}, shardingValue{raw: 2}), cityHash64(shardingValue{raw: 2}, shardingValue{raw: 1}))
+}
+
+type fakeShardBatch struct {
+	driver.Batch
+	columns []column.Interface
+	rows    [][]any
+	sendErr error
+	sent    bool
+}
+
+func (b *fakeShardBatch) Append(v ...any) error       { b.rows = append(b.rows, v); return nil }
+func (b *fakeShardBatch) Rows() int                   { return len(b.rows) }
+func (b *fakeShardBatch) Columns() []column.Interface { return b.columns }
+func (b *fakeShardBatch) Send() error                 { b.sent = true; return b.sendErr }
+func (b *fakeShardBatch) Abort() error                { b.sent = true; return nil }
+
+type fakeShardConn struct {
+	driver.Conn
+	batch *fakeShardBatch
+}
+
+func (c *fakeShardConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	return c.batch, nil
+}
+
+func newFakeShards(t *testing.T, weights ...uint32) ([]Shard, []*fakeShardBatch) {
+	var (
+		shards  []Shard
+		batches []*fakeShardBatch
+	)
+	for i, weight := range weights {
+		id, err := column.Type("Int32").Column("id", nil)
+		require.NoError(t, err)
+		name, err := column.Type("String").Column("name", nil)
+		require.NoError(t, err)
+		batch := &fakeShardBatch{columns: []column.Interface{id, name}}
+		batches = append(batches, batch)
+		shards = append(shards, Shard{Num: uint32(i + 1), Weight: weight, Conn: &fakeShardConn{batch: batch}})
+	}
+	return shards, batches
+}
+
+func TestShardedBatchWeights(t *testing.T) {
+	shards, batches := newFakeShards(t, 1, 2)
+	batch, err := PrepareShardedBatch(context.Background(), "INSERT INTO events_local", ShardedBatchOptions{
+		Shards: shards,
+		Key:    ShardByColumn("id"),
+	})
+	require.NoError(t, err)
+
+	for _, id := range []int32{0, 1, 2, 3, -1} {
+		require.NoError(t, batch.Append(id, "name"))
+	}
+	// slots are [shard 1, shard 2, shard 2], -1 is 4294967295 as UInt32
+	assert.Equal(t, [][]any{{int32(0), "name"}, {int32(3), "name"}, {int32(-1), "name"}}, batches[0].rows)
+	assert.Len(t, batches[1].rows, 2)
+	assert.Equal(t, map[uint32]int{1: 3, 2: 2}, batch.ShardRows())
+	assert.Equal(t, 5, batch.Rows())
+
+	require.NoError(t, batch.Send())
+	assert.True(t, batches[0].sent)
+	assert.True(t, batches[1].sent)
+	assert.ErrorIs(t, batch.Append(int32(1), "name"), ErrBatchAlreadySent)
+}
+
+func TestShardedBatchCityHash64(t *testing.T) {
+	shards, batches := newFakeShards(t, 1, 1, 1)
+	batch, err := PrepareShardedBatch(context.Background(), "INSERT INTO events_local", ShardedBatchOptions{
+		Shards: shards,
+		Key:    ShardByCityHash64("name"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, batch.Append(int32(1), ""))
+	// cityHash64('') % 3 = 2
+	assert.Len(t, batches[2].rows, 1)
+
+	_, err = PrepareShardedBatch(context.Background(), "INSERT INTO events_local", ShardedBatchOptions{
+		Shards: shards,
+		Key:    ShardByCityHash64("missing"),
+	})
+	assert.Error(t, err)
+	_, err = PrepareShardedBatch(context.Background(), "INSERT INTO events_local", ShardedBatchOptions{
+		Shards: shards,
+		Key:    ShardByColumn("id"),
+	})
+	require.NoError(t, err)
+}
+
+func TestShardedBatchSendErrors(t *testing.T) {
+	shards, batches := newFakeShards(t, 1, 1)
+	refused := errors.New("connection refused")
+	batches[1].sendErr = refused
+	batch, err := PrepareShardedBatch(context.Background(), "INSERT INTO events_local", ShardedBatchOptions{
+		Shards: shards,
+		Key:    ShardByRand(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, batch.Append(int32(1), "a"))
+
+	err = batch.Send()
+	var shardErr *ShardedBatchError
+	require.ErrorAs(t, err, &shardErr)
+	assert.Equal(t, map[uint32]error{2: refused}, shardErr.Errors)
+	assert.ErrorIs(t, err, refused)
+	assert.True(t, batches[0].sent)
+}
@@ -0,0 +1,584 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/ch-go/compress"
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/google/uuid"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+var (
+	ErrSpoolFull   = errors.New("clickhouse: spool is full")
+	ErrSpoolClosed = errors.New("clickhouse: spool is closed")
+)
+
+const (
+	defaultSpoolRetryInterval = 10 * time.Second
+	// spoolFrameSize bounds the uncompressed frames of an entry, the
+	// decompression of ch-go rejects frames above 128 MiB.
+	spoolFrameSize = 1 << 20
+
+	spoolExt     = ".spool"
+	spoolDeadExt = ".dead"
+	spoolTempExt = ".tmp"
+)
+
+var spoolMagic = []byte("CHSPOOL\x01")
+
+// SpoolOptions configures OpenSpool.
+type SpoolOptions struct {
+	// Dir holds the entries, one file per batch. It is created if missing
+	// and must not be shared by two Spools.
+	Dir string
+	// MaxBytes bounds the size on disk of the entries, batches beyond it are
+	// rejected with ErrSpoolFull. Unbounded when 0 (default).
+	MaxBytes int64
+	// MaxAge drops the entries older than it instead of replaying them. Kept
+	// until replayed when 0 (default).
+	MaxAge time.Duration
+	// RetryInterval is the time between replays while entries are pending,
+	// default 10s.
+	RetryInterval time.Duration
+	// Compression of the entries, LZ4 by default. GZIP, Deflate and Brotli
+	// are not supported.
+	Compression *Compression
+}
+
+// SpoolStatus is a snapshot of a Spool.
+type SpoolStatus struct {
+	Entries    int   // pending entries
+	Rows       int   // rows of the pending entries
+	Bytes      int64 // size on disk of the pending entries
+	Oldest     time.Time
+	Replayed   int // entries sent since OpenSpool
+	Expired    int // entries dropped for MaxAge since OpenSpool
+	Dead       int // entries the server rejected since OpenSpool, kept with the .dead extension
+	LastReplay time.Time
+	LastError  error // of the last replay, nil once one succeeds
+}
+
+// Spool keeps the batches that could not reach ClickHouse on disk and
+// replays them in order once it is reachable again. Entries are native blocks,
+// compressed, and their INSERTs carry an insert_deduplication_token so that a
+// replay the server applied without acknowledging is not inserted twice on
+// tables with deduplication.
+type Spool struct {
+	opts       SpoolOptions
+	send       func(ctx context.Context, query string, block *proto.Block) (string, error)
+	logger     *slog.Logger
+	compressor *compress.Writer // guarded by mu
+
+	replaying sync.Mutex // held by a replay
+
+	mu      sync.Mutex
+	entries []*spoolEntry
+	last    int64 // sequence of the newest entry
+	status  SpoolStatus
+	closed  bool
+
+	ctx     context.Context // of the replays, cancelled by Close
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// spoolEntry is a file of Spool.Dir, the header of which is kept in memory.
+type spoolEntry struct {
+	path    string
+	seq     int64
+	size    int64
+	header  spoolHeader
+	created time.Time
+}
+
+// spoolHeader precedes the compressed block of an entry.
+type spoolHeader struct {
+	query    string
+	token    string
+	created  int64 // unix nanoseconds
+	revision uint64
+	server   column.ServerContext
+	rows     uint64
+}
+
+// OpenSpool opens the spool of opts.Dir on conn, a Conn of Open with the
+// native protocol, and starts replaying the entries it holds.
+func OpenSpool(conn driver.Conn, opts SpoolOptions) (*Spool, error) {
+	ch, ok := conn.(*clickhouse)
+	if !ok || ch.opt.Protocol != Native {
+		return nil, errors.New("clickhouse: Spool requires a native protocol Conn of Open")
+	}
+	return newSpool(opts, ch.opt.logger(), ch.insertBlock)
+}
+
+func newSpool(opts SpoolOptions, logger *slog.Logger, send func(context.Context, string, *proto.Block) (string, error)) (*Spool, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("clickhouse: SpoolOptions.Dir is required")
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultSpoolRetryInterval
+	}
+	compression := Compression{Method: CompressionLZ4}
+	if opts.Compression != nil {
+		compression = *opts.Compression
+	}
+	switch compression.Method {
+	case CompressionNone, CompressionLZ4, CompressionLZ4HC, CompressionZSTD:
+	default:
+		return nil, fmt.Errorf("clickhouse: spool compression %s is not supported", compression.Method)
+	}
+	if logger == nil {
+		logger = newNoopLogger()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Spool{
+		opts:       opts,
+		send:       send,
+		logger:     logger.With(slog.String("component", "spool")),
+		compressor: compress.NewWriter(compress.Level(compression.Level), compress.Method(compression.Method)),
+		ctx:        ctx,
+		cancel:     cancel,
+		stopped:    make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+// load reads the headers of the entries of the directory and removes the
+// files of interrupted writes.
+func (s *Spool) load() error {
+	if err := os.MkdirAll(s.opts.Dir, 0o700); err != nil {
+		return &OpError{Op: "OpenSpool", Err: err}
+	}
+	files, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return &OpError{Op: "OpenSpool", Err: err}
+	}
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(s.opts.Dir, name)
+		switch filepath.Ext(name) {
+		case spoolTempExt:
+			if err := os.Remove(path); err != nil {
+				return &OpError{Op: "OpenSpool", Err: err}
+			}
+			continue
+		case spoolExt:
+		default:
+			continue
+		}
+		seq, err := strconv.ParseInt(strings.TrimSuffix(name, spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.last = max(s.last, seq)
+		entry, err := readSpoolEntry(path, seq)
+		if err != nil {
+			s.logger.Error("unreadable spool entry", slog.String("path", path), slog.Any("error", err))
+			s.kill(&spoolEntry{path: path}, err)
+			continue
+		}
+		s.entries = append(s.entries, entry)
+		s.add(entry, 1)
+	}
+	// os.ReadDir sorts by name, the zero-padded sequence
+	return nil
+}
+
+func readSpoolEntry(path string, seq int64) (*spoolEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var header spoolHeader
+	if err := header.decode(chproto.NewReader(file)); err != nil {
+		return nil, err
+	}
+	return &spoolEntry{
+		path:    path,
+		seq:     seq,
+		size:    info.Size(),
+		header:  header,
+		created: time.Unix(0, header.created),
+	}, nil
+}
+
+func (h *spoolHeader) encode(buffer *chproto.Buffer) {
+	buffer.PutRaw(spoolMagic)
+	buffer.PutString(h.query)
+	buffer.PutString(h.token)
+	buffer.PutInt64(h.created)
+	buffer.PutUVarInt(h.revision)
+	buffer.PutUVarInt(h.server.Revision)
+	buffer.PutUVarInt(h.server.VersionMajor)
+	buffer.PutUVarInt(h.server.VersionMinor)
+	buffer.PutUVarInt(h.server.VersionPatch)
+	var timezone string
+	if h.server.Timezone != nil {
+		timezone = h.server.Timezone.String()
+	}
+	buffer.PutString(timezone)
+	buffer.PutUVarInt(h.rows)
+}
+
+func (h *spoolHeader) decode(reader *chproto.Reader) (err error) {
+	magic, err := reader.ReadRaw(len(spoolMagic))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(magic, spoolMagic) {
+		return errors.New("not a spool entry")
+	}
+	if h.query, err = reader.Str(); err != nil {
+		return err
+	}
+	if h.token, err = reader.Str(); err != nil {
+		return err
+	}
+	if h.created, err = reader.Int64(); err != nil {
+		return err
+	}
+	for _, v := range []*uint64{&h.revision, &h.server.Revision, &h.server.VersionMajor, &h.server.VersionMinor, &h.server.VersionPatch} {
+		if *v, err = reader.UVarInt(); err != nil {
+			return err
+		}
+	}
+	timezone, err := reader.Str()
+	if err != nil {
+		return err
+	}
+	if h.server.Timezone, err = time.LoadLocation(timezone); err != nil {
+		return err
+	}
+	h.rows, err = reader.UVarInt()
+	return err
+}
+
+// Send sends batch, a batch of the Conn of the Spool, and spools its rows
+// when ClickHouse can't be reached: on connection errors and on the errors
+// IsRetryable reports for idempotent statements, once Options.RetryPolicy
+// gave up. Other errors are returned. Rows sent with Batch.Flush before the
+// failure are not spooled. Entries are replayed in order between themselves,
+// not with the batches Send inserts directly.
+func (s *Spool) Send(batch driver.Batch) error {
+	b, err := spoolBatch(batch)
+	if err != nil {
+		return err
+	}
+	sendErr := batch.Send()
+	if !spoolable(sendErr) {
+		return sendErr
+	}
+	s.logger.Warn("spooling batch after failed send",
+		slog.String("query_id", b.queryID),
+		slog.Int("rows", b.block.Rows()),
+		slog.Any("error", sendErr))
+	if err := s.write(b); err != nil {
+		return errors.Join(sendErr, err)
+	}
+	return nil
+}
+
+// Defer spools the rows of batch without sending them and aborts it.
+func (s *Spool) Defer(batch driver.Batch) error {
+	b, err := spoolBatch(batch)
+	if err != nil {
+		return err
+	}
+	if b.sent {
+		return ErrBatchAlreadySent
+	}
+	if err := s.write(b); err != nil {
+		return err
+	}
+	return batch.Abort()
+}
+
+func spoolBatch(b driver.Batch) (*batch, error) {
+	if traced, ok := b.(*tracedBatch); ok {
+		b = traced.Batch
+	}
+	nb, ok := b.(*batch)
+	if !ok {
+		return nil, errors.New("clickhouse: Spool requires a native protocol batch")
+	}
+	return nb, nil
+}
+
+// spoolable reports whether err of a send is ClickHouse being unreachable.
+func spoolable(err error) bool {
+	return err != nil && (IsRetryable(err, true) ||
+		isConnBrokenError(err) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrAcquireConnTimeout))
+}
+
+// write stores the rows of b as the newest entry.
+func (s *Spool) write(b *batch) error {
+	if b.block.Rows() == 0 {
+		return nil
+	}
+	token := b.dedupToken
+	if token == "" {
+		token = uuid.NewString()
+	}
+	header := spoolHeader{
+		query:    b.query,
+		token:    token,
+		created:  time.Now().UnixNano(),
+		revision: proto.DBMS_TCP_PROTOCOL_VERSION,
+		rows:     uint64(b.block.Rows()),
+	}
+	if b.block.ServerContext != nil {
+		header.server = *b.block.ServerContext
+	}
+	var (
+		buffer = new(chproto.Buffer)
+		data   = new(chproto.Buffer)
+	)
+	header.encode(buffer)
+	if err := b.block.Encode(data, header.revision); err != nil {
+		return &OpError{Op: "Spool.write", Err: err}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSpoolClosed
+	}
+	for frame := range slices.Chunk(data.Buf, spoolFrameSize) {
+		if err := s.compressor.Compress(frame); err != nil {
+			return &OpError{Op: "Spool.write", Err: fmt.Errorf("compress: %w", err)}
+		}
+		buffer.Buf = append(buffer.Buf, s.compressor.Data...)
+	}
+	if s.opts.MaxBytes > 0 && s.status.Bytes+int64(len(buffer.Buf)) > s.opts.MaxBytes {
+		return ErrSpoolFull
+	}
+
+	seq := max(header.created, s.last+1)
+	entry := &spoolEntry{
+		path:    filepath.Join(s.opts.Dir, fmt.Sprintf("%019d%s", seq, spoolExt)),
+		seq:     seq,
+		size:    int64(len(buffer.Buf)),
+		header:  header,
+		created: time.Unix(0, header.created),
+	}
+	if err := writeFileSync(entry.path, buffer.Buf); err != nil {
+		return &OpError{Op: "Spool.write", Err: err}
+	}
+	s.last = seq
+	s.entries = append(s.entries, entry)
+	s.add(entry, 1)
+	return nil
+}
+
+// writeFileSync writes path through a temporary file synced before its
+// rename, so that path is either missing or complete after a crash.
+func writeFileSync(path string, data []byte) error {
+	temp := path + spoolTempExt
+	file, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, path)
+	}
+	if err != nil {
+		os.Remove(temp)
+	}
+	return err
+}
+
+// Replay sends the pending entries in order. It stops at the first entry
+// ClickHouse can't be reached for and returns the error, the entry is kept.
+// Entries the server rejects are renamed with the .dead extension.
+func (s *Spool) Replay(ctx context.Context) error {
+	s.replaying.Lock()
+	defer s.replaying.Unlock()
+	for {
+		s.mu.Lock()
+		if len(s.entries) == 0 {
+			s.status.LastReplay, s.status.LastError = time.Now(), nil
+			s.mu.Unlock()
+			return nil
+		}
+		entry := s.entries[0]
+		s.mu.Unlock()
+
+		if s.opts.MaxAge > 0 && time.Since(entry.created) > s.opts.MaxAge {
+			s.logger.Warn("dropping expired spool entry",
+				slog.String("path", entry.path),
+				slog.Time("created", entry.created))
+			s.remove(entry, &s.status.Expired)
+			continue
+		}
+		block, err := entry.block()
+		if err != nil {
+			s.logger.Error("unreadable spool entry", slog.String("path", entry.path), slog.Any("error", err))
+			s.kill(entry, err)
+			continue
+		}
+		settings := maps.Clone(queryOptions(ctx).settings)
+		if settings == nil {
+			settings = make(Settings)
+		}
+		settings["insert_deduplication_token"] = entry.header.token
+		queryID, err := s.send(Context(ctx, WithSettings(settings)), entry.header.query, block)
+		switch {
+		case err == nil:
+			s.logger.Debug("spool entry replayed",
+				slog.String("path", entry.path),
+				slog.String("query_id", queryID),
+				slog.Uint64("rows", entry.header.rows))
+			s.remove(entry, &s.status.Replayed)
+		case spoolable(err) || ctx.Err() != nil:
+			s.mu.Lock()
+			s.status.LastReplay, s.status.LastError = time.Now(), err
+			s.mu.Unlock()
+			return err
+		default:
+			s.logger.Error("spool entry rejected",
+				slog.String("path", entry.path),
+				slog.String("query_id", queryID),
+				slog.Any("error", err))
+			s.kill(entry, err)
+		}
+	}
+}
+
+// block reads the rows of the entry.
+func (e *spoolEntry) block() (*proto.Block, error) {
+	file, err := os.Open(e.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var (
+		reader = chproto.NewReader(file)
+		header spoolHeader
+	)
+	if err := header.decode(reader); err != nil {
+		return nil, err
+	}
+	reader.EnableCompression()
+	block := &proto.Block{ServerContext: &header.server}
+	if err := block.Decode(reader, header.revision); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// add counts n entries, 1 or -1, in the status.
+func (s *Spool) add(entry *spoolEntry, n int) {
+	s.status.Entries += n
+	s.status.Rows += n * int(entry.header.rows)
+	s.status.Bytes += int64(n) * entry.size
+}
+
+// forget drops entry from the pending entries, if it is one. s.mu is held.
+func (s *Spool) forget(entry *spoolEntry) {
+	n := len(s.entries)
+	if s.entries = slices.DeleteFunc(s.entries, func(e *spoolEntry) bool { return e == entry }); len(s.entries) < n {
+		s.add(entry, -1)
+	}
+}
+
+// remove deletes the file of entry and increments counter.
+func (s *Spool) remove(entry *spoolEntry, counter *int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forget(entry)
+	*counter++
+	if err := os.Remove(entry.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Error("failed to remove spool entry", slog.String("path", entry.path), slog.Any("error", err))
+	}
+}
+
+// kill renames the file of entry with the .dead extension, for inspection.
+func (s *Spool) kill(entry *spoolEntry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forget(entry)
+	s.status.Dead++
+	s.status.LastError = err
+	dead := strings.TrimSuffix(entry.path, spoolExt) + spoolDeadExt
+	if err := os.Rename(entry.path, dead); err != nil {
+		s.logger.Error("failed to rename spool entry", slog.String("path", entry.path), slog.Any("error", err))
+	}
+}
+
+// Status returns a snapshot of the spool.
+func (s *Spool) Status() SpoolStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	if len(s.entries) != 0 {
+		status.Oldest = s.entries[0].created
+	}
+	return status
+}
+
+func (s *Spool) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.opts.RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s.Status().Entries != 0 {
+			if err := s.Replay(s.ctx); err != nil {
+				s.logger.Debug("spool replay interrupted", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Close stops the replays, the pending entries stay on disk for the next
+// OpenSpool of the directory.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	s.cancel()
+	<-s.stopped
+	return nil
+}
@@ -0,0 +1,154 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// spoolServer stands for the server a Spool replays to, it records the rows
+// and tokens sent and fails with err while set.
+type spoolServer struct {
+	mu     sync.Mutex
+	rows   [][]any
+	tokens []string
+	err    error
+}
+
+func (s *spoolServer) send(ctx context.Context, query string, block *proto.Block) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	s.tokens = append(s.tokens, queryOptions(ctx).settings["insert_deduplication_token"].(string))
+	for i := range block.Rows() {
+		row := []any{query}
+		for _, col := range block.Columns {
+			row = append(row, col.Row(i, false))
+		}
+		s.rows = append(s.rows, row)
+	}
+	return "q", nil
+}
+
+// spoolTestBatch returns a native batch of rows for the INSERT of query,
+// the connections of which fail with a refused dial.
+func spoolTestBatch(t *testing.T, query string, rows ...[]any) *batch {
+	block := &proto.Block{ServerContext: &column.ServerContext{Timezone: time.UTC}}
+	require.NoError(t, block.AddColumn("id", "UInt64"))
+	require.NoError(t, block.AddColumn("name", "LowCardinality(String)"))
+	require.NoError(t, block.AddColumn("ts", "DateTime"))
+	for _, row := range rows {
+		require.NoError(t, block.Append(row...))
+	}
+	return &batch{
+		ctx:         context.Background(),
+		query:       query,
+		block:       block,
+		released:    true,
+		connRelease: func(*connect, error) {},
+		connAcquire: func(context.Context) (*connect, error) {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		},
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		dir    = t.TempDir()
+		server = &spoolServer{err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+		ts     = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	)
+	spool, err := newSpool(SpoolOptions{Dir: dir, RetryInterval: time.Hour}, nil, server.send)
+	require.NoError(t, err)
+
+	require.NoError(t, spool.Send(spoolTestBatch(t, "INSERT INTO events", []any{uint64(1), "a", ts}, []any{uint64(2), "b", ts})),
+		"batches that can't reach the server are spooled")
+	deferred := spoolTestBatch(t, "INSERT INTO logs", []any{uint64(3), "c", ts})
+	deferred.dedupToken = "token"
+	require.NoError(t, spool.Defer(deferred))
+	assert.True(t, deferred.IsSent())
+
+	status := spool.Status()
+	assert.Equal(t, 2, status.Entries)
+	assert.Equal(t, 3, status.Rows)
+	assert.NotZero(t, status.Bytes)
+	assert.False(t, status.Oldest.IsZero())
+
+	assert.Error(t, spool.Replay(ctx), "entries are kept while the server is unreachable")
+	assert.Equal(t, 2, spool.Status().Entries)
+	require.NoError(t, spool.Close())
+
+	// the entries outlive the Spool
+	spool, err = newSpool(SpoolOptions{Dir: dir, RetryInterval: time.Hour}, nil, server.send)
+	require.NoError(t, err)
+	defer spool.Close()
+	assert.Equal(t, status.Bytes, spool.Status().Bytes)
+	server.err = nil
+	require.NoError(t, spool.Replay(ctx))
+	assert.Equal(t, [][]any{
+		{"INSERT INTO events", uint64(1), "a", ts},
+		{"INSERT INTO events", uint64(2), "b", ts},
+		{"INSERT INTO logs", uint64(3), "c", ts},
+	}, server.rows, "entries are replayed in order")
+	require.Len(t, server.tokens, 2)
+	assert.NotEmpty(t, server.tokens[0])
+	assert.Equal(t, "token", server.tokens[1], "the deduplication token of the batch is kept")
+
+	status = spool.Status()
+	assert.Equal(t, 0, status.Entries)
+	assert.Equal(t, 2, status.Replayed)
+	assert.NoError(t, status.LastError)
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestSpoolLimits(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		dir    = t.TempDir()
+		server = &spoolServer{}
+		row    = []any{uint64(1), "a", time.Now()}
+	)
+	spool, err := newSpool(SpoolOptions{Dir: dir, MaxAge: time.Hour, RetryInterval: time.Hour}, nil, server.send)
+	require.NoError(t, err)
+	defer spool.Close()
+	require.NoError(t, spool.Defer(spoolTestBatch(t, "INSERT INTO events", row)))
+	spool.entries[0].created = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, spool.Replay(ctx))
+	assert.Empty(t, server.rows, "entries older than MaxAge are dropped")
+	assert.Equal(t, 1, spool.Status().Expired)
+
+	require.NoError(t, spool.Defer(spoolTestBatch(t, "INSERT INTO events", row)))
+	spool.opts.MaxBytes = spool.Status().Bytes + 1
+	assert.ErrorIs(t, spool.Defer(spoolTestBatch(t, "INSERT INTO events", row)), ErrSpoolFull)
+
+	server.err = &Exception{Code: 60, Message: "Table default.events does not exist"}
+	require.NoError(t, spool.Replay(ctx), "entries the server rejects do not block the others")
+	status := spool.Status()
+	assert.Equal(t, 0, status.Entries)
+	assert.Equal(t, 1, status.Dead)
+	dead, err := filepath.Glob(filepath.Join(dir, "*"+spoolDeadExt))
+	require.NoError(t, err)
+	assert.Len(t, dead, 1)
+
+	batch := spoolTestBatch(t, "INSERT INTO events", row)
+	batch.connAcquire = func(context.Context) (*connect, error) { return nil, errors.New("code: 60") }
+	assert.Error(t, spool.Send(batch), "other errors are returned")
+	assert.Equal(t, 0, spool.Status().Entries)
+}
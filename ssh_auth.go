@@ -0,0 +1,89 @@
+package clickhouse
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// sshKeyAuthMarker prefixes the username of the hello of users IDENTIFIED
+// WITH ssh_key, the server then answers a challenge instead of checking the
+// password.
+const sshKeyAuthMarker = " SSH KEY AUTHENTICATION "
+
+// SSHKeyFromFile reads a PEM encoded private key for Auth.SSHKey, decrypting
+// it with passphrase when it is not empty.
+func SSHKeyFromFile(path, passphrase string) (ssh.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	}
+	return ssh.ParsePrivateKey(pem)
+}
+
+// sshChallengeMessage returns the message to sign for challenge, it binds
+// the signature to the protocol version, database and user of the hello.
+func sshChallengeMessage(revision uint64, auth Auth, challenge string) []byte {
+	return []byte(strconv.FormatUint(revision, 10) + auth.Database + auth.Username + challenge)
+}
+
+// signSSHChallenge signs message with key and returns the signature in the
+// SSH wire format.
+func signSSHChallenge(key ssh.Signer, message []byte) ([]byte, error) {
+	var (
+		signature *ssh.Signature
+		err       error
+	)
+	if signer, ok := key.(ssh.AlgorithmSigner); ok && key.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// ssh-rsa signatures are SHA-1, which servers may refuse
+		signature, err = signer.SignWithAlgorithm(rand.Reader, message, ssh.KeyAlgoRSASHA512)
+	} else {
+		signature, err = key.Sign(rand.Reader, message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ssh.Marshal(signature), nil
+}
+
+// sshChallenge answers the challenge of the server to the hello of auth, it
+// follows the hello and precedes the server hello.
+func (c *connect) sshChallenge(auth Auth) error {
+	c.buffer.PutByte(proto.ClientSSHChallengeRequest)
+	if err := c.flush(); err != nil {
+		return fmt.Errorf("handshake: failed to request ssh challenge (conn_id=%d): %w", c.id, err)
+	}
+	packet, err := c.reader.ReadByte()
+	if err != nil {
+		return fmt.Errorf("handshake: failed to read ssh challenge (conn_id=%d): %w", c.id, err)
+	}
+	switch packet {
+	case proto.ServerException:
+		return c.exception()
+	case proto.ServerSSHChallenge:
+	default:
+		return fmt.Errorf("[handshake] unexpected packet [%d] from server, expected ssh challenge", packet)
+	}
+	challenge, err := c.reader.Str()
+	if err != nil {
+		return fmt.Errorf("handshake: failed to read ssh challenge (conn_id=%d): %w", c.id, err)
+	}
+	signature, err := signSSHChallenge(auth.SSHKey, sshChallengeMessage(c.revision, auth, challenge))
+	if err != nil {
+		return fmt.Errorf("handshake: failed to sign ssh challenge (conn_id=%d): %w", c.id, err)
+	}
+	c.buffer.PutByte(proto.ClientSSHChallengeResponse)
+	c.buffer.PutString(string(signature))
+	if err := c.flush(); err != nil {
+		return fmt.Errorf("handshake: failed to send ssh challenge response (conn_id=%d): %w", c.id, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	chproto "github.com/ClickHouse/ch-go/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+func writeSSHKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	}
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return path, signer.PublicKey()
+}
+
+func TestSSHKeyFromFile(t *testing.T) {
+	path, public := writeSSHKey(t, "secret")
+	_, err := SSHKeyFromFile(path, "")
+	assert.Error(t, err, "the key is encrypted")
+	key, err := SSHKeyFromFile(path, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, public.Marshal(), key.PublicKey().Marshal())
+
+	opts, err := ParseDSN("clickhouse://svc@127.0.0.1:9000?ssh_key_path=" + path + "&ssh_key_passphrase=secret")
+	require.NoError(t, err)
+	require.NotNil(t, opts.Auth.SSHKey)
+	assert.Equal(t, public.Marshal(), opts.Auth.SSHKey.PublicKey().Marshal())
+
+	_, err = ParseDSN("clickhouse://svc@127.0.0.1:9000?ssh_key_path=" + path)
+	assert.ErrorContains(t, err, "ssh_key_path")
+}
+
+// sshServer answers the hello of conn as a server authenticating user with
+// the SSH key public, it sends the result of the signature verification.
+func sshServer(t *testing.T, conn net.Conn, public ssh.PublicKey) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		defer conn.Close()
+		var (
+			reader = chproto.NewReader(conn)
+			buffer = new(chproto.Buffer)
+		)
+		packet, _ := reader.ReadByte()
+		assert.Equal(t, byte(proto.ClientHello), packet)
+		_, _ = reader.Str() // client name
+		_, _ = reader.UVarInt()
+		_, _ = reader.UVarInt()
+		revision, _ := reader.UVarInt()
+		database, _ := reader.Str()
+		username, _ := reader.Str()
+		_, _ = reader.Str() // password
+		assert.Equal(t, uint64(proto.DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION), revision)
+		assert.Equal(t, sshKeyAuthMarker+"svc", username)
+
+		packet, _ = reader.ReadByte()
+		assert.Equal(t, byte(proto.ClientSSHChallengeRequest), packet)
+		buffer.PutByte(proto.ServerSSHChallenge)
+		buffer.PutString("challenge")
+		conn.Write(buffer.Buf)
+		buffer.Reset()
+
+		packet, _ = reader.ReadByte()
+		assert.Equal(t, byte(proto.ClientSSHChallengeResponse), packet)
+		blob, _ := reader.Str()
+		var signature ssh.Signature
+		if err := ssh.Unmarshal([]byte(blob), &signature); err != nil {
+			done <- err
+			return
+		}
+		done <- public.Verify(sshChallengeMessage(revision, Auth{Database: database, Username: "svc"}, "challenge"), &signature)
+
+		buffer.PutByte(proto.ServerHello)
+		buffer.PutString("ClickHouse")
+		buffer.PutUVarInt(25)
+		buffer.PutUVarInt(8)
+		buffer.PutUVarInt(proto.DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION)
+		buffer.PutString("UTC")
+		buffer.PutString("server")
+		buffer.PutUVarInt(1)
+		buffer.PutUVarInt(0) // password complexity rules
+		buffer.PutUInt64(42) // nonce
+		conn.Write(buffer.Buf)
+	}()
+	return done
+}
+
+func TestSSHKeyHandshake(t *testing.T) {
+	path, public := writeSSHKey(t, "")
+	key, err := SSHKeyFromFile(path, "")
+	require.NoError(t, err)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	verified := sshServer(t, server, public)
+	c := &connect{
+		id:          1,
+		opt:         &Options{DialTimeout: time.Second},
+		conn:        client,
+		logger:      newNoopLogger(),
+		buffer:      new(chproto.Buffer),
+		reader:      chproto.NewReader(client),
+		revision:    proto.DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION,
+		readTimeout: time.Second,
+	}
+	require.NoError(t, c.handshake(Auth{Database: "db", Username: "svc", SSHKey: key}))
+	assert.NoError(t, <-verified, "the server verifies the signature of the challenge")
+	assert.Equal(t, uint64(proto.DBMS_MIN_REVISION_WITH_SSH_AUTHENTICATION), c.server.Revision)
+	assert.Equal(t, uint64(42), c.server.Nonce)
+}
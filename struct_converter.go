@@ -0,0 +1,81 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// converter converts a struct field to and from the value of its column.
+type converter struct {
+	column reflect.Type // type the column is scanned into
+	append func(field reflect.Value) (any, error)
+	scan   func(column, field reflect.Value) error
+}
+
+var converters sync.Map // reflect.Type -> *converter
+
+// RegisterConverter registers the conversion of struct fields of type T by
+// AppendStruct, ScanStruct, Select and RowsIter: toColumn returns the value
+// appended to the column, fromColumn the field value of the column scanned
+// into C. Struct types are mapped once per connection, register converters
+// before using them (e.g. in init).
+//
+//	clickhouse.RegisterConverter(
+//		func(m Money) (decimal.Decimal, error) { return m.Decimal(), nil },
+//		func(d decimal.Decimal) (Money, error) { return MoneyFromDecimal(d), nil },
+//	)
+func RegisterConverter[T, C any](toColumn func(T) (C, error), fromColumn func(C) (T, error)) {
+	converters.Store(reflect.TypeFor[T](), &converter{
+		column: reflect.TypeFor[C](),
+		append: func(field reflect.Value) (any, error) {
+			return toColumn(field.Interface().(T))
+		},
+		scan: func(column, field reflect.Value) error {
+			value, err := fromColumn(column.Interface().(C))
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(&value).Elem())
+			return nil
+		},
+	})
+}
+
+func lookupConverter(t reflect.Type) *converter {
+	if c, found := converters.Load(t); found {
+		return c.(*converter)
+	}
+	return nil
+}
+
+// codecConverter returns the converter of the codec option of a field tag.
+func codecConverter(codec string, t reflect.Type) (*converter, error) {
+	switch codec {
+	case "json":
+		return &converter{
+			column: reflect.TypeFor[string](),
+			append: func(field reflect.Value) (any, error) {
+				b, err := json.Marshal(field.Interface())
+				if err != nil {
+					return nil, err
+				}
+				return string(b), nil
+			},
+			scan: func(column, field reflect.Value) error {
+				value := reflect.New(t)
+				if column.String() == "" {
+					field.Set(value.Elem())
+					return nil
+				}
+				if err := json.Unmarshal([]byte(column.String()), value.Interface()); err != nil {
+					return err
+				}
+				field.Set(value.Elem())
+				return nil
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown codec %q", codec)
+}
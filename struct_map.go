@@ -3,92 +3,322 @@ package clickhouse
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
 )
 
 type structMap struct {
 	cache sync.Map
 }
 
-func (m *structMap) Map(op string, columns []string, s any, ptr bool) ([]any, error) {
+// structField is a struct field mapped to a column.
+type structField struct {
+	index     []int
+	converter *converter // converts the field to and from the column value, nil when the field is the column value
+	omit      bool       // tagged default, not appended so that the server fills the column DEFAULT
+}
+
+// Map returns the values of the fields of s mapped to columns, to append them.
+func (m *structMap) Map(op string, columns []string, s any) ([]any, error) {
+	v, index, err := m.value(op, s)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, 0, len(columns))
+	for _, name := range columns {
+		field, found := index[name]
+		if !found {
+			return nil, &OpError{
+				Op:  op,
+				Err: fmt.Errorf("missing destination name %q in %T", name, s),
+			}
+		}
+		if field.omit {
+			return nil, &OpError{
+				Op:  op,
+				Err: fmt.Errorf("column %q is tagged default in %T, leave it out of the INSERT columns", name, s),
+			}
+		}
+		value, err := field.value(v)
+		if err != nil {
+			return nil, &OpError{
+				Op:         op,
+				ColumnName: name,
+				Err:        err,
+			}
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// defaults returns the columns of columns mapped to fields of s tagged default.
+func (m *structMap) defaults(op string, columns []string, s any) ([]string, error) {
+	_, index, err := m.value(op, s)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range columns {
+		if field, found := index[name]; found && field.omit {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// omitDefaults drops the columns of block mapped to fields of s tagged
+// default, so that the server fills their DEFAULT. The columns of a block
+// can only change while it holds no rows.
+func (m *structMap) omitDefaults(op string, block *proto.Block, s any) error {
+	names, err := m.defaults(op, block.ColumnsNames(), s)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	if block.Rows() != 0 {
+		return &OpError{
+			Op:  op,
+			Err: fmt.Errorf("column %q is tagged default in %T but the batch already holds rows with it", names[0], s),
+		}
+	}
+	block.DropColumns(names...)
+	return nil
+}
+
+// Scan scans a row into the fields of dest mapped to columns.
+func (m *structMap) Scan(op string, columns []string, dest any, scan func(dest ...any) error) error {
+	v, index, err := m.value(op, dest)
+	if err != nil {
+		return err
+	}
+	var (
+		values    = make([]any, 0, len(columns))
+		converted []func() error
+	)
+	for _, name := range columns {
+		field, found := index[name]
+		if !found {
+			return &OpError{
+				Op:  op,
+				Err: fmt.Errorf("missing destination name %q in %T", name, dest),
+			}
+		}
+		value, convert := field.scanDest(v)
+		if convert != nil {
+			converted = append(converted, convert)
+		}
+		values = append(values, value)
+	}
+	if err := scan(values...); err != nil {
+		return err
+	}
+	for _, convert := range converted {
+		if err := convert(); err != nil {
+			return &OpError{
+				Op:  op,
+				Err: err,
+			}
+		}
+	}
+	return nil
+}
+
+// value checks that s is a pointer to a struct and returns it with its index.
+func (m *structMap) value(op string, s any) (reflect.Value, map[string]structField, error) {
 	v := reflect.ValueOf(s)
 	if v.Kind() != reflect.Ptr {
-		return nil, &OpError{
+		return reflect.Value{}, nil, &OpError{
 			Op:  op,
 			Err: fmt.Errorf("must pass a pointer, not a value, to %s destination", op),
 		}
 	}
 	if v.IsNil() {
-		return nil, &OpError{
+		return reflect.Value{}, nil, &OpError{
 			Op:  op,
 			Err: fmt.Errorf("nil pointer passed to %s destination", op),
 		}
 	}
-	t := reflect.TypeOf(s)
-	if v = reflect.Indirect(v); t.Kind() == reflect.Ptr {
-		t = t.Elem()
-	}
-	if v.Kind() != reflect.Struct {
-		return nil, &OpError{
+	if v = v.Elem(); v.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, &OpError{
 			Op:  op,
 			Err: fmt.Errorf("%s expects a struct dest", op),
 		}
 	}
+	index, err := m.index(v.Type())
+	if err != nil {
+		return reflect.Value{}, nil, &OpError{
+			Op:  op,
+			Err: err,
+		}
+	}
+	return v, index, nil
+}
 
-	var (
-		index  map[string][]int
-		values = make([]any, 0, len(columns))
-	)
+type structIndex struct {
+	fields map[string]structField
+	err    error
+}
 
-	switch idx, found := m.cache.Load(t); {
-	case found:
-		index = idx.(map[string][]int)
-	default:
-		index = structIdx(t)
-		m.cache.Store(t, index)
+// index returns the cached field index of struct type t by column name.
+func (m *structMap) index(t reflect.Type) (map[string]structField, error) {
+	if idx, found := m.cache.Load(t); found {
+		return idx.(structIndex).fields, idx.(structIndex).err
 	}
-	for _, name := range columns {
-		idx, found := index[name]
-		if !found {
-			return nil, &OpError{
-				Op:  op,
-				Err: fmt.Errorf("missing destination name %q in %T", name, s),
+	fields, err := structIdx(t)
+	m.cache.Store(t, structIndex{fields: fields, err: err})
+	return fields, err
+}
+
+// value returns the value to append for the field of struct v.
+func (f *structField) value(v reflect.Value) (any, error) {
+	field := fieldByIndex(v, f.index, false)
+	if !field.IsValid() {
+		// in a nil embedded pointer
+		field = reflect.Zero(v.Type().FieldByIndex(f.index).Type)
+	}
+	if f.converter != nil {
+		return f.converter.append(field)
+	}
+	return field.Interface(), nil
+}
+
+// scanDest returns the scan destination of the field of struct v and, for
+// converted fields, the conversion to run once the row is scanned.
+func (f *structField) scanDest(v reflect.Value) (any, func() error) {
+	field := fieldByIndex(v, f.index, true)
+	if f.converter == nil {
+		return field.Addr().Interface(), nil
+	}
+	dest := reflect.New(f.converter.column)
+	return dest.Interface(), func() error {
+		return f.converter.scan(dest.Elem(), field)
+	}
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, it allocates the nil
+// embedded pointers on the way when alloc, otherwise it returns the zero
+// Value for fields in a nil pointer.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
 			}
+			v = v.Elem()
 		}
-		switch field := v.FieldByIndex(idx); {
-		case ptr:
-			values = append(values, field.Addr().Interface())
+		v = v.Field(x)
+	}
+	return v
+}
+
+// structTag is a parsed `ch:"name,option,..."` field tag. Options:
+//
+//	inline     the fields of the struct are mapped as if it was embedded
+//	flatten    the fields of the struct are mapped with the name as prefix,
+//	           e.g. `ch:"nested.,flatten"` for the columns of a Nested column
+//	codec=json the field is appended as JSON text and scanned from it
+//	default    the field is not appended, the server fills the column DEFAULT
+type structTag struct {
+	name    string
+	inline  bool
+	flatten bool
+	codec   string
+	omit    bool
+}
+
+func parseStructTag(tag string) (structTag, error) {
+	name, options, _ := strings.Cut(tag, ",")
+	st := structTag{name: name}
+	for options != "" {
+		var option string
+		option, options, _ = strings.Cut(options, ",")
+		switch key, value, _ := strings.Cut(option, "="); key {
+		case "inline":
+			st.inline = true
+		case "flatten":
+			st.flatten = true
+		case "codec":
+			st.codec = value
+		case "default":
+			st.omit = true
 		default:
-			values = append(values, field.Interface())
+			return st, fmt.Errorf("unknown option %q in struct tag %q", option, tag)
 		}
 	}
-	return values, nil
+	return st, nil
 }
 
-func structIdx(t reflect.Type) map[string][]int {
-	fields := make(map[string][]int)
+// structIdx returns the fields of struct type t by column name. Like Go
+// selectors, the shallowest field of a name wins.
+func structIdx(t reflect.Type) (map[string]structField, error) {
+	var (
+		fields = make(map[string]structField)
+		depths = make(map[string]int)
+	)
+	if err := addStructFields(fields, depths, t, nil, "", 0); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func addStructFields(fields map[string]structField, depths map[string]int, t reflect.Type, index []int, prefix string, depth int) error {
 	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, err := parseStructTag(f.Tag.Get("ch"))
+		if err != nil {
+			return fmt.Errorf("field %s of %s: %w", f.Name, t, err)
+		}
+		if tag == (structTag{name: "-"}) {
+			continue
+		}
 		var (
-			f    = t.Field(i)
-			name = f.Name
+			ft       = f.Type
+			embedded = f.Anonymous
 		)
-		if tn := f.Tag.Get("ch"); len(tn) != 0 {
-			name = tn
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
 		}
 		switch {
-		case name == "-", len(f.PkgPath) != 0 && !f.Anonymous:
+		case !f.IsExported() && !(f.Anonymous && f.Type.Kind() == reflect.Struct):
+			// unexported embedded pointers can't be allocated to scan into
 			continue
-		}
-		switch {
-		case f.Anonymous:
-			if f.Type.Kind() != reflect.Ptr {
-				for k, idx := range structIdx(f.Type) {
-					fields[k] = append(f.Index, idx...)
+		case ft.Kind() == reflect.Struct && tag.codec == "" && (tag.inline || tag.flatten || embedded && lookupConverter(f.Type) == nil):
+			fieldPrefix := prefix
+			if tag.flatten {
+				name := tag.name
+				if name == "" {
+					name = f.Name + "."
 				}
+				fieldPrefix += name
 			}
-		default:
-			fields[name] = f.Index
+			if err := addStructFields(fields, depths, ft, append(index[:len(index):len(index)], i), fieldPrefix, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		name := tag.name
+		if name == "" {
+			name = f.Name
+		}
+		name = prefix + name
+		field := structField{
+			index:     append(index[:len(index):len(index)], i),
+			converter: lookupConverter(f.Type),
+			omit:      tag.omit,
+		}
+		if tag.codec != "" {
+			if field.converter, err = codecConverter(tag.codec, f.Type); err != nil {
+				return fmt.Errorf("field %s of %s: %w", f.Name, t, err)
+			}
+		}
+		if d, found := depths[name]; !found || depth < d {
+			fields[name], depths[name] = field, depth
 		}
 	}
-	return fields
+	return nil
 }
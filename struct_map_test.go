@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
 )
 
 func TestStructIdx(t *testing.T) {
@@ -23,16 +26,25 @@ func TestStructIdx(t *testing.T) {
 		Embed
 		*Embed2
 	}
-	index := structIdx(reflect.TypeOf(Example{
+	index, err := structIdx(reflect.TypeOf(Example{
 		Col1: "X",
 	}))
+	require.NoError(t, err)
 	assert.Equal(t, map[string][]int{
 		"Col1":   {0},
 		"Col2":   {1},
 		"ColPtr": {2},
 		"named":  {3, 0},
-		"Col6":   {3, 1, 0},
-	}, index)
+		"Col6":   {4, 0}, // the shallowest Col6, in the embedded pointer
+	}, fieldIndexes(index))
+}
+
+func fieldIndexes(index map[string]structField) map[string][]int {
+	indexes := make(map[string][]int, len(index))
+	for name, field := range index {
+		indexes[name] = field.index
+	}
+	return indexes
 }
 
 func TestMapper(t *testing.T) {
@@ -56,7 +68,7 @@ func TestMapper(t *testing.T) {
 		Embed: Embed{
 			Col4: "Named value",
 		},
-	}, false)
+	})
 
 	t.Log(values, err)
 }
@@ -88,8 +100,138 @@ func BenchmarkStructMap(b *testing.B) {
 	b.ResetTimer()
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		if _, err := mapper.Map("", []string{"Col1", "named"}, data, false); err != nil {
+		if _, err := mapper.Map("", []string{"Col1", "named"}, data); err != nil {
 			b.Fatal(err)
 		}
 	}
 }
+
+func TestStructTagOptions(t *testing.T) {
+	type Address struct {
+		Street string `ch:"street"`
+		City   string `ch:"city"`
+	}
+	type Attributes struct {
+		Tags []string `json:"tags"`
+	}
+	type Item struct {
+		Values []uint64 `ch:"value"`
+	}
+	type Example struct {
+		ID         uint64     `ch:"id"`
+		Address    Address    `ch:",inline"`
+		Items      *Item      `ch:"items.,flatten"`
+		Attributes Attributes `ch:"attributes,codec=json"`
+		Created    time.Time  `ch:"created,default"`
+		Ignored    string     `ch:"-"`
+	}
+	index, err := structIdx(reflect.TypeFor[Example]())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int{
+		"id":          {0},
+		"street":      {1, 0},
+		"city":        {1, 1},
+		"items.value": {2, 0},
+		"attributes":  {3},
+		"created":     {4},
+	}, fieldIndexes(index))
+
+	mapper := structMap{}
+	src := Example{
+		ID:         1,
+		Address:    Address{Street: "Main St", City: "Amsterdam"},
+		Attributes: Attributes{Tags: []string{"a"}},
+	}
+	values, err := mapper.Map("AppendStruct", []string{"id", "city", "items.value", "attributes"}, &src)
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint64(1), "Amsterdam", []uint64(nil), `{"tags":["a"]}`}, values)
+
+	block := proto.NewBlock()
+	require.NoError(t, block.AddColumn("id", "UInt64"))
+	require.NoError(t, block.AddColumn("created", "DateTime"))
+	require.NoError(t, mapper.omitDefaults("AppendStruct", block, &src))
+	assert.Equal(t, []string{"id"}, block.ColumnsNames(), "default columns are left out of the block")
+	values, err = mapper.Map("AppendStruct", block.ColumnsNames(), &src)
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint64(1)}, values)
+
+	block = proto.NewBlock()
+	require.NoError(t, block.AddColumn("id", "UInt64"))
+	require.NoError(t, block.AddColumn("created", "DateTime"))
+	require.NoError(t, block.Append(uint64(1), time.Now()))
+	assert.ErrorContains(t, mapper.omitDefaults("AppendStruct", block, &src), `column "created" is tagged default`,
+		"the columns of a block holding rows are kept")
+
+	var dest Example
+	err = mapper.Scan("ScanStruct", []string{"items.value", "attributes", "created"}, &dest, func(dest ...any) error {
+		*dest[0].(*[]uint64) = []uint64{1, 2}
+		*dest[1].(*string) = `{"tags":["b"]}`
+		*dest[2].(*time.Time) = time.Unix(1, 0)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dest.Items, "the flattened pointer is allocated")
+	assert.Equal(t, []uint64{1, 2}, dest.Items.Values)
+	assert.Equal(t, []string{"b"}, dest.Attributes.Tags)
+	assert.Equal(t, time.Unix(1, 0), dest.Created)
+
+	type Invalid struct {
+		Col1 string `ch:"col1,unknown"`
+	}
+	_, err = structIdx(reflect.TypeFor[Invalid]())
+	assert.ErrorContains(t, err, `unknown option "unknown"`)
+	type InvalidCodec struct {
+		Col1 string `ch:"col1,codec=xml"`
+	}
+	_, err = mapper.Map("AppendStruct", []string{"col1"}, &InvalidCodec{})
+	assert.ErrorContains(t, err, `unknown codec "xml"`)
+}
+
+func TestStructPointerEmbedded(t *testing.T) {
+	type Embed struct {
+		Col2 string
+	}
+	type Example struct {
+		Col1 uint8
+		*Embed
+	}
+	mapper := structMap{}
+	values, err := mapper.Map("AppendStruct", []string{"Col1", "Col2"}, &Example{Col1: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint8(1), ""}, values, "a nil embedded pointer appends zero values")
+
+	var dest Example
+	err = mapper.Scan("ScanStruct", []string{"Col1", "Col2"}, &dest, func(dest ...any) error {
+		*dest[0].(*uint8) = 2
+		*dest[1].(*string) = "value"
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, dest.Embed)
+	assert.Equal(t, "value", dest.Col2)
+}
+
+type testCents int64
+
+func TestRegisterConverter(t *testing.T) {
+	RegisterConverter(
+		func(c testCents) (float64, error) { return float64(c) / 100, nil },
+		func(f float64) (testCents, error) { return testCents(f * 100), nil },
+	)
+	defer converters.Delete(reflect.TypeFor[testCents]())
+	type Example struct {
+		Price testCents `ch:"price"`
+	}
+	mapper := structMap{}
+	values, err := mapper.Map("AppendStruct", []string{"price"}, &Example{Price: 1250})
+	require.NoError(t, err)
+	assert.Equal(t, []any{12.5}, values)
+
+	var dest Example
+	err = mapper.Scan("ScanStruct", []string{"price"}, &dest, func(dest ...any) error {
+		*dest[0].(*float64) = 3.5
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testCents(350), dest.Price)
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestAggregateFunction(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		ctx := context.Background()
+		require.NoError(t, err)
+		const ddl = `
+		CREATE TABLE test_aggregate_function (
+			  Col1 UInt64
+			, Col2 AggregateFunction(count)
+			, Col3 AggregateFunction(sum, UInt32)
+			, Col4 AggregateFunction(avg, Float64)
+			, Col5 AggregateFunction(max, String)
+			, Col6 AggregateFunction(uniqExact, UInt64)
+			, Col7 AggregateFunction(groupBitmap, UInt32)
+			, Col8 AggregateFunction(uniq, UInt64)
+			, Col9 AggregateFunction(quantiles(0.5, 0.9), Float64)
+		) Engine AggregatingMergeTree() ORDER BY Col1
+		`
+		defer func() {
+			conn.Exec(ctx, "DROP TABLE IF EXISTS test_aggregate_function")
+		}()
+		require.NoError(t, conn.Exec(ctx, ddl))
+		require.NoError(t, conn.Exec(ctx, `
+			INSERT INTO test_aggregate_function
+			SELECT
+				  number % 2
+				, countState()
+				, sumState(toUInt32(number))
+				, avgState(toFloat64(number))
+				, maxState(toString(number))
+				, uniqExactState(number)
+				, groupBitmapState(toUInt32(number * 1000))
+				, uniqState(number)
+				, quantilesState(0.5, 0.9)(toFloat64(number))
+			FROM numbers(100)
+			GROUP BY number % 2
+		`))
+
+		rows, err := conn.Query(ctx, "SELECT * FROM test_aggregate_function ORDER BY Col1")
+		require.NoError(t, err)
+		var states [][]any
+		for rows.Next() {
+			var (
+				col1   uint64
+				row    = make([]any, 8)
+				values = make([][]byte, 8)
+			)
+			for i := range values {
+				row[i] = &values[i]
+			}
+			require.NoError(t, rows.Scan(append([]any{&col1}, row...)...))
+			state := make([]any, 0, len(values)+1)
+			state = append(state, col1)
+			for _, v := range values {
+				require.NotEmpty(t, v)
+				state = append(state, v)
+			}
+			states = append(states, state)
+		}
+		require.NoError(t, rows.Err())
+		require.NoError(t, rows.Close())
+		require.Len(t, states, 2)
+
+		var result struct {
+			Count    uint64   `ch:"Col2"`
+			Sum      uint64   `ch:"Col3"`
+			Avg      float64  `ch:"Col4"`
+			Max      string   `ch:"Col5"`
+			Uniq     uint64   `ch:"Col6"`
+			Bitmap   []uint64 `ch:"Col7"`
+			Approx   []byte   `ch:"Col8"`
+			Quantile []byte   `ch:"Col9"`
+		}
+		require.NoError(t, conn.QueryRow(ctx, "SELECT Col2, Col3, Col4, Col5, Col6, Col7, Col8, Col9 FROM test_aggregate_function WHERE Col1 = 1").ScanStruct(&result))
+		assert.Equal(t, uint64(50), result.Count)
+		assert.Equal(t, uint64(2500), result.Sum)
+		assert.Equal(t, float64(50), result.Avg)
+		assert.Equal(t, "99", result.Max)
+		assert.Equal(t, uint64(50), result.Uniq)
+		assert.Len(t, result.Bitmap, 50)
+		assert.Equal(t, uint64(1000), result.Bitmap[0])
+
+		// states read from the server can be inserted back unchanged
+		batch, err := conn.PrepareBatch(ctx, "INSERT INTO test_aggregate_function")
+		require.NoError(t, err)
+		for _, state := range states {
+			require.NoError(t, batch.Append(state...))
+		}
+		require.NoError(t, batch.Send())
+
+		var (
+			count  uint64
+			sum    uint64
+			uniq   uint64
+			median []float64
+		)
+		require.NoError(t, conn.QueryRow(ctx, `
+			SELECT countMerge(Col2), sumMerge(Col3), uniqExactMerge(Col6), quantilesMerge(0.5, 0.9)(Col9)
+			FROM test_aggregate_function
+		`).Scan(&count, &sum, &uniq, &median))
+		assert.Equal(t, uint64(200), count)
+		assert.Equal(t, uint64(9900), sum)
+		assert.Equal(t, uint64(100), uniq)
+		assert.Len(t, median, 2)
+	})
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestAsyncInserter(t *testing.T) {
+	conn, err := GetNativeConnection(t, clickhouse.Native, nil, nil, &clickhouse.Compression{
+		Method: clickhouse.CompressionLZ4,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	table := fmt.Sprintf("test_async_inserter_%s", RandAsciiString(8))
+	require.NoError(t, conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s (
+		id UInt64, name String, ts DateTime
+	) Engine MergeTree() ORDER BY id`, table)))
+	t.Cleanup(func() {
+		conn.Exec(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+	})
+
+	var (
+		mu      sync.Mutex
+		flushed int
+	)
+	inserter, err := clickhouse.NewAsyncInserter(conn, clickhouse.AsyncInserterOptions{
+		MaxRows:       100,
+		FlushInterval: 100 * time.Millisecond,
+		Wait:          true,
+		OnFlush: func(f clickhouse.AsyncInsertFlush) {
+			assert.NoError(t, f.Err)
+			mu.Lock()
+			flushed += f.Rows
+			mu.Unlock()
+		},
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for producer := range 4 {
+		wg.Go(func() {
+			for i := range 250 {
+				id := uint64(producer*1000 + i)
+				if i%2 == 0 {
+					assert.NoError(t, inserter.Append(ctx, table, id, "append", time.Now()))
+					continue
+				}
+				assert.NoError(t, inserter.AppendStruct(ctx, table, &struct {
+					ID   uint64    `ch:"id"`
+					Name string    `ch:"name"`
+					TS   time.Time `ch:"ts"`
+				}{ID: id, Name: "struct", TS: time.Now()}))
+			}
+		})
+	}
+	wg.Wait()
+	require.NoError(t, inserter.Close(ctx))
+
+	var count uint64
+	require.NoError(t, conn.QueryRow(ctx, fmt.Sprintf("SELECT count() FROM %s", table)).Scan(&count))
+	assert.Equal(t, uint64(1000), count)
+	assert.Equal(t, 1000, flushed)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+func TestColumnarRead(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		ctx := clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+			"max_block_size": 1000,
+		}))
+		require.NoError(t, err)
+		rows, err := conn.Query(ctx, `
+			SELECT
+				  number
+				, toString(number)
+				, toDateTime(number, 'UTC')
+				, range(number % 3)
+				, if(number % 2 = 0, NULL, number)
+			FROM system.numbers LIMIT 10000
+		`)
+		require.NoError(t, err)
+		defer rows.Close()
+		var (
+			total  int
+			sum    uint64
+			blocks int
+		)
+		for {
+			block, ok := rows.(driver.BlockRows).NextBlock()
+			if !ok {
+				break
+			}
+			blocks++
+			columns := block.Columns()
+			numbers := columns[0].(*column.UInt64).Data()
+			strings := columns[1].(*column.String).Data()
+			times := columns[2].(*column.DateTime).Data()
+			ranges := columns[3].(*column.Array)
+			nulls := columns[4].(*column.Nullable).Nulls()
+			require.Len(t, numbers, block.Rows())
+			for i, n := range numbers {
+				sum += n
+				assert.Equal(t, time.Unix(int64(n), 0).UTC(), times[i].UTC())
+				assert.Equal(t, uint8(1-n%2), nulls[i])
+			}
+			assert.Len(t, strings, block.Rows())
+			assert.Len(t, ranges.Offsets()[0], block.Rows())
+			total += block.Rows()
+		}
+		require.NoError(t, rows.Err())
+		assert.Equal(t, 10000, total)
+		assert.Equal(t, uint64(10000*9999/2), sum)
+		assert.GreaterOrEqual(t, blocks, 10)
+	})
+}
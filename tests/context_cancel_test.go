@@ -448,3 +448,23 @@ func TestContextCancellationNoConnectionSlotLeak(t *testing.T) {
 		})
 	})
 }
+
+func TestContextCancellationDrain(t *testing.T) {
+	conn, err := GetConnectionTCPWithOptions(testSet, nil, nil, nil, func(o *clickhouse.Options) {
+		o.MaxOpenConns = 1
+		o.CancelDrainTimeout = 10 * time.Second
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err = conn.Exec(ctx, "SELECT sleep(3)")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, conn.Stats().Open, "the drained connection returns to the pool")
+	assert.Equal(t, 1, conn.Stats().Idle)
+
+	var value uint8
+	require.NoError(t, conn.QueryRow(context.Background(), "SELECT 1").Scan(&value))
+	assert.Equal(t, uint8(1), value)
+}
@@ -148,17 +148,67 @@ func TestFormatInsertStripsFormatClause(t *testing.T) {
 	verifyFormatTestTable(t, conn, table)
 }
 
-// TestFormatNativeProtocolUnsupported verifies the sentinel error over the
-// native protocol and that the pool stays healthy after the rejected calls.
+// TestFormatNativeRoundTrip streams a table out in each client-side format
+// over the native protocol and feeds the bytes back into a second table. The
+// driver does both conversions.
+func TestFormatNativeRoundTrip(t *testing.T) {
+	for _, format := range []string{"CSV", "TSVWithNames", "JSONEachRow", "JSONCompactEachRow", "RowBinary"} {
+		t.Run(format, func(t *testing.T) {
+			conn, err := GetNativeConnection(t, clickhouse.Native, nil, nil, &clickhouse.Compression{
+				Method: clickhouse.CompressionLZ4,
+			})
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			source := createFormatTestTable(t, conn, true)
+			dest := createFormatTestTable(t, conn, false)
+
+			stream, err := conn.QueryFormat(ctx, format,
+				fmt.Sprintf("SELECT id, name, score, ok, created_at, comment FROM %s ORDER BY id", source))
+			require.NoError(t, err)
+			payload, err := io.ReadAll(stream)
+			require.NoError(t, err)
+			require.NoError(t, stream.Close())
+			require.NotEmpty(t, payload)
+
+			require.NoError(t, conn.InsertFormat(ctx, format,
+				fmt.Sprintf("INSERT INTO %s", dest), bytes.NewReader(payload)))
+			verifyFormatTestTable(t, conn, dest)
+		})
+	}
+}
+
+// TestFormatNativeCSVContent pins the driver-rendered CSV bytes to the server's.
+func TestFormatNativeCSVContent(t *testing.T) {
+	expected := "1,\"alice\",3.5,true,\"2026-07-06 10:30:00\",\"first\"\n" +
+		"2,\"bob\",-0.25,false,\"2026-01-01 00:00:00\",\\N\n" +
+		"3,\"carol, \"\"quoted\"\"\",100,true,\"2026-07-06 23:59:59\",\"\\N looks like null\"\n"
+
+	conn, err := GetNativeConnection(t, clickhouse.Native, nil, nil, nil)
+	require.NoError(t, err)
+	table := createFormatTestTable(t, conn, true)
+
+	stream, err := conn.QueryFormat(context.Background(), "CSV",
+		fmt.Sprintf("SELECT id, name, score, ok, created_at, comment FROM %s ORDER BY id", table))
+	require.NoError(t, err)
+	defer stream.Close()
+	payload, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(payload))
+}
+
+// TestFormatNativeProtocolUnsupported verifies the sentinel error for formats
+// the driver cannot convert itself and that the pool stays healthy after the
+// rejected calls.
 func TestFormatNativeProtocolUnsupported(t *testing.T) {
 	conn, err := GetNativeConnection(t, clickhouse.Native, nil, nil, nil)
 	require.NoError(t, err)
 	ctx := context.Background()
 
-	_, err = conn.QueryFormat(ctx, "CSV", "SELECT 1")
+	_, err = conn.QueryFormat(ctx, "Parquet", "SELECT 1")
 	require.ErrorIs(t, err, clickhouse.ErrFormatNativeUnsupported)
 
-	err = conn.InsertFormat(ctx, "CSV", "INSERT INTO t", strings.NewReader(""))
+	err = conn.InsertFormat(ctx, "Parquet", "INSERT INTO t", strings.NewReader(""))
 	require.ErrorIs(t, err, clickhouse.ErrFormatNativeUnsupported)
 
 	require.NoError(t, conn.Exec(ctx, "SELECT 1"))
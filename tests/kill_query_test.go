@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+func TestKillQuery(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		queryID := uuid.NewString()
+		ctx := clickhouse.Context(context.Background(), clickhouse.WithQueryID(queryID))
+		done := make(chan error, 1)
+		go func() {
+			done <- conn.Exec(ctx, "SELECT sleep(3) FROM numbers(10) SETTINGS max_block_size = 1")
+		}()
+
+		require.Eventually(t, func() bool {
+			queries, err := conn.RunningQueries(context.Background(), driver.RunningQueriesFilter{QueryID: queryID, Own: true})
+			return err == nil && len(queries) == 1
+		}, 10*time.Second, 50*time.Millisecond, "the query is running")
+
+		require.NoError(t, conn.KillQuery(context.Background(), queryID, driver.WithKillMode(driver.KillSync)))
+		assert.Error(t, <-done, "the query was cancelled")
+
+		queries, err := conn.RunningQueries(context.Background(), driver.RunningQueriesFilter{QueryID: queryID})
+		require.NoError(t, err)
+		assert.Empty(t, queries)
+	})
+}
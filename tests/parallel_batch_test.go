@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestParallelBatch(t *testing.T) {
+	conn, err := GetNativeConnection(t, clickhouse.Native, nil, nil, &clickhouse.Compression{
+		Method: clickhouse.CompressionLZ4,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	table := fmt.Sprintf("test_parallel_batch_%s", RandAsciiString(8))
+	require.NoError(t, conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s (
+		id UInt64, name String, ts DateTime
+	) Engine MergeTree() ORDER BY id`, table)))
+	t.Cleanup(func() {
+		conn.Exec(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+	})
+
+	batch, err := clickhouse.PrepareParallelBatch(ctx, conn, fmt.Sprintf("INSERT INTO %s", table), clickhouse.ParallelBatchOptions{
+		Parallelism: 3,
+		BlockSize:   1000,
+	})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for producer := range 4 {
+		wg.Go(func() {
+			for i := range 2500 {
+				assert.NoError(t, batch.Append(uint64(producer*10_000+i), "name", time.Now()))
+			}
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, 10_000, batch.Rows())
+	require.NoError(t, batch.Send())
+
+	var count, ids uint64
+	require.NoError(t, conn.QueryRow(ctx, fmt.Sprintf("SELECT count(), uniqExact(id) FROM %s", table)).Scan(&count, &ids))
+	assert.Equal(t, uint64(10_000), count)
+	assert.Equal(t, uint64(10_000), ids)
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+func TestGeneratedQueryID(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		ctx := context.Background()
+
+		rows, err := conn.Query(ctx, "SELECT 1")
+		require.NoError(t, err)
+		assert.NotEmpty(t, rows.(driver.QueryIDProvider).QueryID())
+		require.NoError(t, rows.Close())
+
+		var exception *clickhouse.Exception
+		require.ErrorAs(t, conn.Exec(ctx, "SELECT * FROM table_that_does_not_exist"), &exception)
+		assert.NotEmpty(t, exception.QueryID)
+
+		require.NoError(t, conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS test_query_id (id UInt64) Engine = Memory"))
+		defer conn.Exec(ctx, "DROP TABLE IF EXISTS test_query_id")
+		batch, err := conn.PrepareBatch(clickhouse.Context(ctx, clickhouse.WithQueryID("insert-1")), "INSERT INTO test_query_id")
+		require.NoError(t, err)
+		assert.Equal(t, "insert-1", batch.(driver.QueryIDProvider).QueryID())
+		require.NoError(t, batch.Append(uint64(1)))
+		require.NoError(t, batch.Send())
+	})
+}
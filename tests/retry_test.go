@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// TestRetryBatchDeduplicationToken checks the insert_deduplication_token set for
+// batch retries doesn't deduplicate distinct INSERTs of the same batch.
+func TestRetryBatchDeduplicationToken(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		require.NoError(t, err)
+		ctx := clickhouse.Context(context.Background(), clickhouse.WithRetryPolicy(&clickhouse.RetryPolicy{
+			MaxRetries:     3,
+			InitialBackoff: 10 * time.Millisecond,
+			RetryInserts:   true,
+		}))
+		table := fmt.Sprintf("test_retry_%s", RandAsciiString(8))
+		require.NoError(t, conn.Exec(ctx, fmt.Sprintf(`
+			CREATE TABLE %s (id UInt64) Engine MergeTree() ORDER BY id
+			SETTINGS non_replicated_deduplication_window = 100
+		`, table)))
+		t.Cleanup(func() {
+			conn.Exec(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+		})
+
+		var opts []driver.PrepareBatchOption
+		if protocol == clickhouse.Native {
+			opts = append(opts, driver.WithCloseOnFlush())
+		}
+		batch, err := conn.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s", table), opts...)
+		require.NoError(t, err)
+		require.NoError(t, batch.Append(uint64(1)))
+		if protocol == clickhouse.Native {
+			// a separate INSERT, which must not share the token of the next one
+			require.NoError(t, batch.Flush())
+		}
+		require.NoError(t, batch.Append(uint64(2)))
+		require.NoError(t, batch.Send())
+
+		// the same rows again in a new batch are a new insert
+		batch, err = conn.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s", table))
+		require.NoError(t, err)
+		require.NoError(t, batch.Append(uint64(2)))
+		require.NoError(t, batch.Send())
+
+		var count uint64
+		require.NoError(t, conn.QueryRow(ctx, fmt.Sprintf("SELECT count() FROM %s", table)).Scan(&count))
+		assert.Equal(t, uint64(3), count)
+	})
+}
+
+// TestRetryRejectedQuery checks a rejected statement is attempted again and
+// its error returned once the retries are exhausted.
+func TestRetryRejectedQuery(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, clickhouse.Settings{
+			"allow_custom_error_code_in_throwif": 1,
+		}, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		require.NoError(t, err)
+		var attempts int
+		ctx := clickhouse.Context(context.Background(), clickhouse.WithRetryPolicy(&clickhouse.RetryPolicy{
+			MaxRetries:     2,
+			InitialBackoff: 10 * time.Millisecond,
+			Retryable: func(err error, idempotent bool) bool {
+				attempts++
+				return clickhouse.IsRetryable(err, idempotent)
+			},
+		}))
+		err = conn.Exec(ctx, "SELECT throwIf(1, 'busy', 202)")
+		var exception *clickhouse.Exception
+		require.ErrorAs(t, err, &exception)
+		assert.Equal(t, int32(202), exception.Code)
+		assert.Equal(t, 2, attempts)
+	})
+}
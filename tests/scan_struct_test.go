@@ -189,3 +189,38 @@ func TestArrayTupleNonNull(t *testing.T) {
 		require.NoError(t, rows.Err())
 	})
 }
+
+func TestQueryIter(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		ctx := context.Background()
+		require.NoError(t, err)
+		type result struct {
+			Col1 uint64 `ch:"number"`
+			Col2 string `ch:"col1"`
+		}
+		var i uint64
+		for res, err := range clickhouse.QueryIter[result](ctx, conn, "SELECT number, 'ABC_' || CAST(number AS String) AS col1 FROM system.numbers LIMIT 100000") {
+			require.NoError(t, err)
+			assert.Equal(t, i, res.Col1)
+			assert.Equal(t, fmt.Sprintf("ABC_%d", i), res.Col2)
+			if i++; i == 10 {
+				break
+			}
+		}
+		assert.Equal(t, uint64(10), i)
+		// breaking out of the loop must release the connection
+		assert.Eventually(t, func() bool {
+			return conn.Stats().Open == 0
+		}, time.Second, 10*time.Millisecond)
+
+		var total uint64
+		for number, err := range clickhouse.QueryIter[uint64](ctx, conn, "SELECT number FROM system.numbers LIMIT 5") {
+			require.NoError(t, err)
+			total += number
+		}
+		assert.Equal(t, uint64(10), total)
+	})
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+func TestSessionTemporaryTable(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		session, err := conn.Session(ctx, driver.SessionOptions{Timeout: time.Minute})
+		require.NoError(t, err)
+		defer session.Close()
+		if protocol == clickhouse.HTTP {
+			assert.NotEmpty(t, session.ID())
+		}
+
+		require.NoError(t, session.Exec(ctx, "CREATE TEMPORARY TABLE test_session (id UInt64)"))
+		batch, err := session.PrepareBatch(ctx, "INSERT INTO test_session")
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, batch.Append(uint64(i)))
+		}
+		require.NoError(t, batch.Send())
+
+		// concurrent statements are serialised instead of failing with SESSION_IS_LOCKED
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, session.Exec(ctx, "INSERT INTO test_session VALUES (100)"))
+			}()
+		}
+		wg.Wait()
+
+		var count uint64
+		require.NoError(t, session.QueryRow(ctx, "SELECT count() FROM test_session").Scan(&count))
+		assert.Equal(t, uint64(18), count)
+
+		var ids []uint64
+		require.NoError(t, session.Select(ctx, &ids, "SELECT id FROM test_session WHERE id < 3 ORDER BY id"))
+		assert.Equal(t, []uint64{0, 1, 2}, ids)
+
+		require.NoError(t, session.Close())
+		assert.ErrorIs(t, session.Ping(ctx), clickhouse.ErrSessionClosed)
+	})
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// TestShardedBatchMatchesServer checks the client-side sharding keys against
+// the server: both shards insert into the same table, so the rows each one
+// received are compared with the shards the server computes for them.
+func TestShardedBatchMatchesServer(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		require.NoError(t, err)
+		ctx := context.Background()
+		table := fmt.Sprintf("test_sharded_batch_%s", RandAsciiString(8))
+		require.NoError(t, conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s (
+			id Int32, name String, day Date
+		) Engine MergeTree() ORDER BY id`, table)))
+		t.Cleanup(func() {
+			conn.Exec(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+		})
+
+		// a signed key is used as the unsigned integer of the same width
+		keys := map[string]string{
+			"id":                        "toUInt32(id)",
+			"intHash64(id)":             "intHash64(id)",
+			"cityHash64(id)":            "cityHash64(id)",
+			"cityHash64(name)":          "cityHash64(name)",
+			"cityHash64(id, name, day)": "cityHash64(id, name, day)",
+		}
+		for expr, serverExpr := range keys {
+			t.Run(expr, func(t *testing.T) {
+				key, err := clickhouse.ParseShardingKey(expr)
+				require.NoError(t, err)
+				batch, err := clickhouse.PrepareShardedBatch(ctx, "INSERT INTO "+table, clickhouse.ShardedBatchOptions{
+					Shards: []clickhouse.Shard{{Num: 1, Weight: 1, Conn: conn}, {Num: 2, Weight: 2, Conn: conn}},
+					Key:    key,
+				})
+				require.NoError(t, err)
+				rows, err := conn.Query(ctx, "SELECT toInt32(number) - 500, toString(number * 7), toDate('2024-01-01') + number FROM numbers(1000)")
+				require.NoError(t, err)
+				for rows.Next() {
+					var (
+						id   int32
+						name string
+						day  time.Time
+					)
+					require.NoError(t, rows.Scan(&id, &name, &day))
+					require.NoError(t, batch.Append(id, name, day))
+				}
+				require.NoError(t, rows.Err())
+				shardRows := batch.ShardRows()
+				require.NoError(t, batch.Send())
+
+				var first uint64
+				require.NoError(t, conn.QueryRow(ctx, fmt.Sprintf("SELECT countIf(%s %% 3 = 0) FROM %s", serverExpr, table)).Scan(&first))
+				assert.Equal(t, int(first), shardRows[1])
+				assert.Equal(t, 1000-int(first), shardRows[2])
+				require.NoError(t, conn.Exec(ctx, "TRUNCATE TABLE "+table))
+			})
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestSparseSerialization(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		ctx := context.Background()
+		require.NoError(t, err)
+		if !CheckMinServerServerVersion(conn, 22, 1, 0) {
+			t.Skip("sparse serialization is not supported by this server version")
+			return
+		}
+		const ddl = `
+		CREATE TABLE test_sparse_serialization (
+			  Col1 UInt64
+			, Col2 UInt64
+			, Col3 String
+			, Col4 Tuple(a UInt32, b String)
+		) Engine MergeTree() ORDER BY Col1
+		SETTINGS ratio_of_defaults_for_sparse_serialization = 0.5
+		`
+		defer func() {
+			conn.Exec(ctx, "DROP TABLE IF EXISTS test_sparse_serialization")
+		}()
+		require.NoError(t, conn.Exec(ctx, ddl))
+		require.NoError(t, conn.Exec(ctx, `
+			INSERT INTO test_sparse_serialization
+			SELECT
+				  number
+				, if(number % 100 = 0, number, 0)
+				, if(number % 100 = 0, toString(number), '')
+				, tuple(if(number % 50 = 0, toUInt32(number), 0), '')
+			FROM numbers(10000)
+		`))
+
+		rows, err := conn.Query(ctx, "SELECT * FROM test_sparse_serialization ORDER BY Col1")
+		require.NoError(t, err)
+		var count uint64
+		for rows.Next() {
+			var (
+				col1 uint64
+				col2 uint64
+				col3 string
+				col4 struct {
+					A uint32 `ch:"a"`
+					B string `ch:"b"`
+				}
+			)
+			require.NoError(t, rows.Scan(&col1, &col2, &col3, &col4))
+			require.Equal(t, count, col1)
+			if col1%100 == 0 {
+				assert.Equal(t, col1, col2)
+				assert.NotEmpty(t, col3)
+			} else {
+				assert.Zero(t, col2)
+				assert.Empty(t, col3)
+			}
+			if col1%50 == 0 {
+				assert.Equal(t, uint32(col1), col4.A)
+			} else {
+				assert.Zero(t, col4.A)
+			}
+			count++
+		}
+		require.NoError(t, rows.Err())
+		assert.Equal(t, uint64(10000), count)
+	})
+}
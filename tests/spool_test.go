@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestSpool(t *testing.T) {
+	conn, err := GetNativeConnection(t, clickhouse.Native, nil, nil, &clickhouse.Compression{
+		Method: clickhouse.CompressionLZ4,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	table := fmt.Sprintf("test_spool_%s", RandAsciiString(8))
+	require.NoError(t, conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s (
+		id UInt64, name LowCardinality(String), ts DateTime
+	) Engine MergeTree() ORDER BY id SETTINGS non_replicated_deduplication_window = 100`, table)))
+	t.Cleanup(func() {
+		conn.Exec(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+	})
+
+	spool, err := clickhouse.OpenSpool(conn, clickhouse.SpoolOptions{Dir: t.TempDir(), RetryInterval: time.Hour})
+	require.NoError(t, err)
+	defer spool.Close()
+
+	for i := range 3 {
+		batch, err := conn.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s", table))
+		require.NoError(t, err)
+		for j := range 100 {
+			require.NoError(t, batch.Append(uint64(i*100+j), fmt.Sprintf("name_%d", j%10), time.Now()))
+		}
+		require.NoError(t, spool.Defer(batch))
+	}
+	assert.Equal(t, 300, spool.Status().Rows)
+
+	require.NoError(t, spool.Replay(ctx))
+	status := spool.Status()
+	assert.Equal(t, 0, status.Entries)
+	assert.Equal(t, 3, status.Replayed)
+
+	var count uint64
+	require.NoError(t, conn.QueryRow(ctx, fmt.Sprintf("SELECT count() FROM %s", table)).Scan(&count))
+	assert.Equal(t, uint64(300), count)
+}
@@ -0,0 +1,49 @@
+package std
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestPreparedSelect(t *testing.T) {
+	dsns := map[string]clickhouse.Protocol{"Native": clickhouse.Native, "Http": clickhouse.HTTP}
+	for name, protocol := range dsns {
+		t.Run(fmt.Sprintf("%s Protocol", name), func(t *testing.T) {
+			conn, err := GetStdOpenDBConnection(protocol, nil, nil, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			if !CheckMinServerVersion(conn, 22, 8, 0) {
+				t.Skip("server-side query parameters require ClickHouse 22.8+")
+			}
+
+			stmt, err := conn.Prepare("SELECT number, {prefix:String} || toString(number) FROM numbers({limit:UInt64}) WHERE number >= {min:UInt64}")
+			require.NoError(t, err)
+			defer stmt.Close()
+			for limit := uint64(1); limit <= 3; limit++ {
+				rows, err := stmt.Query("n", limit, 0)
+				require.NoError(t, err)
+				var count uint64
+				for rows.Next() {
+					var (
+						number uint64
+						str    string
+					)
+					require.NoError(t, rows.Scan(&number, &str))
+					assert.Equal(t, fmt.Sprintf("n%d", number), str)
+					count++
+				}
+				require.NoError(t, rows.Close())
+				assert.Equal(t, limit, count)
+			}
+
+			var number uint64
+			require.NoError(t, stmt.QueryRow(clickhouse.Named("min", 4), clickhouse.Named("limit", 5), clickhouse.Named("prefix", "")).Scan(&number, new(string)))
+			assert.Equal(t, uint64(4), number)
+		})
+	}
+}
@@ -97,3 +97,53 @@ func TestAppendStruct(t *testing.T) {
 		}
 	})
 }
+
+func TestAppendStructTagOptions(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, nil)
+		require.NoError(t, err)
+		ctx := context.Background()
+		const ddl = `
+		CREATE TABLE test_append_struct_tags (
+			  id         UInt64
+			, street     String
+			, items      Nested(value UInt64, name String)
+			, attributes String
+			, created    UInt64 DEFAULT 42
+		) Engine MergeTree() ORDER BY id
+		`
+		defer func() {
+			conn.Exec(ctx, "DROP TABLE test_append_struct_tags")
+		}()
+		require.NoError(t, conn.Exec(ctx, ddl))
+		type address struct {
+			Street string `ch:"street"`
+		}
+		type items struct {
+			Values []uint64 `ch:"value"`
+			Names  []string `ch:"name"`
+		}
+		type data struct {
+			ID         uint64            `ch:"id"`
+			Address    *address          `ch:",inline"`
+			Items      items             `ch:"items.,flatten"`
+			Attributes map[string]string `ch:"attributes,codec=json"`
+			Created    uint64            `ch:"created,default"`
+		}
+		batch, err := conn.PrepareBatch(ctx, "INSERT INTO test_append_struct_tags")
+		require.NoError(t, err)
+		src := data{
+			ID:         1,
+			Address:    &address{Street: "Main St"},
+			Items:      items{Values: []uint64{1, 2}, Names: []string{"a", "b"}},
+			Attributes: map[string]string{"key": "value"},
+		}
+		require.NoError(t, batch.AppendStruct(&src))
+		require.NoError(t, batch.Send())
+
+		var result data
+		require.NoError(t, conn.QueryRow(ctx, "SELECT * FROM test_append_struct_tags").ScanStruct(&result))
+		src.Created = 42
+		assert.Equal(t, src, result)
+	})
+}
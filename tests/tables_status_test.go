@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestTablesStatus(t *testing.T) {
+	TestProtocols(t, func(t *testing.T, protocol clickhouse.Protocol) {
+		conn, err := GetNativeConnection(t, protocol, nil, nil, &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		})
+		require.NoError(t, err)
+		ctx := context.Background()
+		table := fmt.Sprintf("test_tables_status_%s", RandAsciiString(8))
+		require.NoError(t, conn.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (id UInt64) Engine MergeTree() ORDER BY id", table)))
+		t.Cleanup(func() {
+			conn.Exec(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+		})
+
+		statuses, err := conn.TablesStatus(ctx, table, "system.no_such_table")
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, table, statuses[0].Table)
+		assert.False(t, statuses[0].IsReplicated)
+		assert.Zero(t, statuses[0].AbsoluteDelay)
+
+		// a table that isn't replicated never lags
+		var one uint8
+		require.NoError(t, conn.QueryRow(clickhouse.Context(ctx, clickhouse.WithReplicaDelayCheck(table)), "SELECT 1").Scan(&one))
+		assert.Equal(t, uint8(1), one)
+	})
+}
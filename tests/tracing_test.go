@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/internal/tracetest"
+)
+
+func TestTracerProvider(t *testing.T) {
+	recorder := tracetest.NewRecorder()
+	conn, err := GetConnectionTCPWithOptions(testSet, nil, nil, nil, func(o *clickhouse.Options) {
+		o.TracerProvider = recorder
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	rows, err := conn.Query(context.Background(), "SELECT number FROM system.numbers LIMIT 10")
+	require.NoError(t, err)
+	var count int
+	for rows.Next() {
+		count++
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, 10, count)
+
+	var query *tracetest.Span
+	for _, span := range recorder.Ended() {
+		if span.Name() == "clickhouse.query" {
+			query = span
+		}
+	}
+	require.NotNil(t, query)
+	attrs := attribute.NewSet(query.Attributes()...)
+	operation, _ := attrs.Value("db.operation.name")
+	assert.Equal(t, "SELECT", operation.AsString())
+	readRows, ok := attrs.Value("clickhouse.read_rows")
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, readRows.AsInt64(), int64(10))
+}
+
+func TestTraceServerEvents(t *testing.T) {
+	recorder := tracetest.NewRecorder()
+	conn, err := GetConnectionTCPWithOptions(testSet, nil, nil, nil, func(o *clickhouse.Options) {
+		o.TracerProvider = recorder
+		o.TraceServerEvents = true
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+		"send_logs_level": "debug",
+	}))
+	var count uint64
+	require.NoError(t, conn.QueryRow(ctx, "SELECT count() FROM numbers(1000)").Scan(&count))
+	assert.Equal(t, uint64(1000), count)
+
+	var server *tracetest.Span
+	for _, span := range recorder.Ended() {
+		if span.Name() == "clickhouse.server" {
+			server = span
+		}
+	}
+	require.NotNil(t, server)
+	attrs := attribute.NewSet(server.Attributes()...)
+	_, ok := attrs.Value("clickhouse.profile_events.Query")
+	assert.True(t, ok)
+	assert.NotEmpty(t, server.Events(), "server logs")
+}
@@ -0,0 +1,345 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ClickHouse/clickhouse-go/v2/internal/otelattr"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const tracerName = "github.com/ClickHouse/clickhouse-go/v2"
+
+// tracer returns the tracer of Options.TracerProvider, nil without one.
+func (o *Options) tracer() trace.Tracer {
+	if o.TracerProvider == nil {
+		return nil
+	}
+	return o.TracerProvider.Tracer(tracerName, trace.WithInstrumentationVersion(
+		strconv.Itoa(ClientVersionMajor)+"."+strconv.Itoa(ClientVersionMinor)+"."+strconv.Itoa(ClientVersionPatch)))
+}
+
+// clientSpan is a client span of an operation. It is nil without a
+// TracerProvider, its methods do nothing then.
+type clientSpan struct {
+	span   trace.Span
+	tracer trace.Tracer
+	start  time.Time
+	server *serverEvents // nil without Options.TraceServerEvents
+
+	// progress of the query, updated by the goroutine reading the result
+	readRows, readBytes, writtenRows, writtenBytes atomic.Uint64
+
+	mu       sync.Mutex
+	returned bool  // the operation returned, its connection is released by the caller
+	failed   error // release error of the last attempt before the operation returned
+	ended    bool
+}
+
+// startSpan starts a client span for an operation running query (if any).
+// The returned context carries the span, which queries propagate to the server.
+func (ch *clickhouse) startSpan(ctx context.Context, name string, query string) (context.Context, *clientSpan) {
+	tracer := ch.opt.tracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+	attrs := []attribute.KeyValue{attribute.String("db.system.name", "clickhouse")}
+	if query != "" {
+		attrs = append(attrs, attribute.String("db.query.text", query))
+		if fields := strings.Fields(query); len(fields) != 0 {
+			attrs = append(attrs, attribute.String("db.operation.name", strings.ToUpper(fields[0])))
+		}
+	}
+	if queryID := queryOptions(ctx).queryID; queryID != "" {
+		attrs = append(attrs, attribute.String("clickhouse.query_id", queryID))
+	}
+	s := &clientSpan{
+		tracer: tracer,
+		start:  time.Now(),
+	}
+	if query != "" && ch.opt.TraceServerEvents {
+		s.server = &serverEvents{profileEvents: make(map[string]int64)}
+	}
+	ctx, s.span = tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...), trace.WithTimestamp(s.start))
+	if query != "" {
+		ctx = Context(ctx, func(o *QueryOptions) error {
+			progress := o.events.progress
+			o.events.progress = func(p *Progress) {
+				s.readRows.Add(p.Rows)
+				s.readBytes.Add(p.Bytes)
+				s.writtenRows.Add(p.WroteRows)
+				s.writtenBytes.Add(p.WroteBytes)
+				if s.server != nil {
+					s.server.progress(p)
+				}
+				if progress != nil {
+					progress(p)
+				}
+			}
+			if s.server != nil {
+				logs, profileEvents := o.events.logs, o.events.profileEvents
+				o.events.logs = func(l *Log) {
+					s.server.log(l)
+					if logs != nil {
+						logs(l)
+					}
+				}
+				o.events.profileEvents = func(events []ProfileEvent) {
+					s.server.profile(events)
+					if profileEvents != nil {
+						profileEvents(events)
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return ctx, s
+}
+
+// connected records the server of the connection an attempt runs on.
+func (s *clientSpan) connected(ch *clickhouse, conn nativeTransport) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed = nil
+	s.mu.Unlock()
+	if addr, ok := ch.hosts.Load(conn.connID()); ok {
+		s.span.SetAttributes(otelattr.Server(addr.(string))...)
+	}
+}
+
+// setQueryID records the query ID of a retry, which may differ from the one
+// of the first attempt.
+func (s *clientSpan) setQueryID(queryID string) {
+	if s == nil || queryID == "" {
+		return
+	}
+	s.span.SetAttributes(attribute.String("clickhouse.query_id", queryID))
+}
+
+// release wraps the release of the connection of a query, which ends the span
+// once the result is read. A failing attempt leaves it to the caller, which
+// may retry it.
+func (s *clientSpan) release(release nativeTransportRelease) nativeTransportRelease {
+	if s == nil {
+		return release
+	}
+	return func(conn nativeTransport, err error) {
+		s.mu.Lock()
+		if err != nil && !s.returned {
+			s.failed = err
+			s.mu.Unlock()
+		} else {
+			s.mu.Unlock()
+			s.end(err)
+		}
+		release(conn, err)
+	}
+}
+
+// done is called once the operation returned successfully, the span ends with
+// the release of its connection.
+func (s *clientSpan) done() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.returned = true
+	failed := s.failed
+	s.mu.Unlock()
+	if failed != nil {
+		s.end(failed)
+	}
+}
+
+func (s *clientSpan) end(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.mu.Unlock()
+	for _, v := range []struct {
+		key   string
+		value *atomic.Uint64
+	}{
+		{"clickhouse.read_rows", &s.readRows},
+		{"clickhouse.read_bytes", &s.readBytes},
+		{"clickhouse.written_rows", &s.writtenRows},
+		{"clickhouse.written_bytes", &s.writtenBytes},
+	} {
+		if n := v.value.Load(); n != 0 {
+			s.span.SetAttributes(attribute.Int64(v.key, int64(n)))
+		}
+	}
+	// os.ErrProcessDone releases the connection of an aborted batch
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		var exception *Exception
+		if errors.As(err, &exception) {
+			errorType := exception.CodeName
+			if errorType == "" {
+				errorType = strconv.Itoa(int(exception.Code))
+			}
+			s.span.SetAttributes(
+				attribute.String("db.response.status_code", strconv.Itoa(int(exception.Code))),
+				attribute.String("error.type", errorType),
+			)
+		}
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.server.end(s)
+	s.span.End()
+}
+
+// maxServerLogs bounds the server logs kept for the span of a query.
+const maxServerLogs = 1024
+
+// serverEvents collects what the server reports while processing a query.
+type serverEvents struct {
+	mu            sync.Mutex
+	elapsed       time.Duration // server elapsed time of the last progress
+	elapsedAt     time.Time     // when the last progress was received
+	profileEvents map[string]int64
+	hosts         []string
+	logs          []Log
+	droppedLogs   int
+}
+
+func (e *serverEvents) progress(p *Progress) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if p.Elapsed > e.elapsed {
+		e.elapsed, e.elapsedAt = p.Elapsed, time.Now()
+	}
+}
+
+// profile aggregates profile events: increments are summed, gauges keep
+// their highest value.
+func (e *serverEvents) profile(events []ProfileEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, event := range events {
+		if event.Hostname != "" && !slices.Contains(e.hosts, event.Hostname) {
+			e.hosts = append(e.hosts, event.Hostname)
+		}
+		switch event.Type {
+		case "gauge":
+			if v, ok := e.profileEvents[event.Name]; !ok || event.Value > v {
+				e.profileEvents[event.Name] = event.Value
+			}
+		default:
+			e.profileEvents[event.Name] += event.Value
+		}
+	}
+}
+
+func (e *serverEvents) log(l *Log) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.logs) == maxServerLogs {
+		e.droppedLogs++
+		return
+	}
+	e.logs = append(e.logs, *l)
+}
+
+// end records the "clickhouse.server" child span of s. The server clock may
+// differ from the client one, the span is placed by the client receive time
+// of the last progress and the server elapsed time.
+func (e *serverEvents) end(s *clientSpan) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.elapsed == 0 && len(e.profileEvents) == 0 && len(e.logs) == 0 {
+		return
+	}
+	start, end := s.start, time.Now()
+	if e.elapsed != 0 {
+		start, end = e.elapsedAt.Add(-e.elapsed), e.elapsedAt
+	}
+	attrs := make([]attribute.KeyValue, 0, len(e.profileEvents)+2)
+	if len(e.hosts) != 0 {
+		attrs = append(attrs, attribute.StringSlice("clickhouse.server.host_names", e.hosts))
+	}
+	if e.droppedLogs != 0 {
+		attrs = append(attrs, attribute.Int("clickhouse.server.dropped_logs", e.droppedLogs))
+	}
+	names := slices.Sorted(maps.Keys(e.profileEvents))
+	for _, name := range names {
+		attrs = append(attrs, attribute.Int64("clickhouse.profile_events."+name, e.profileEvents[name]))
+	}
+	ctx := trace.ContextWithSpan(context.Background(), s.span)
+	_, span := s.tracer.Start(ctx, "clickhouse.server", trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	for _, l := range e.logs {
+		span.AddEvent("clickhouse.server.log",
+			trace.WithTimestamp(l.Time.Add(time.Duration(l.TimeMicro)*time.Microsecond)),
+			trace.WithAttributes(
+				attribute.String("clickhouse.log.text", l.Text),
+				attribute.String("clickhouse.log.source", l.Source),
+				attribute.Int("clickhouse.log.priority", int(l.Priority)),
+				attribute.String("clickhouse.log.host_name", l.Hostname),
+				attribute.Int64("clickhouse.log.thread_id", int64(l.ThreadID)),
+			))
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// tracedBatch creates a client span for Send.
+type tracedBatch struct {
+	driver.Batch
+	ch    *clickhouse
+	ctx   context.Context
+	query string
+}
+
+func (b *tracedBatch) QueryID() string {
+	if q, ok := b.Batch.(driver.QueryIDProvider); ok {
+		return q.QueryID()
+	}
+	return ""
+}
+
+var _ driver.QueryIDProvider = (*tracedBatch)(nil)
+
+// sendContextSetter is implemented by the batches of the connections, the
+// context of their Send carries its span and reports the events of the
+// server to it.
+type sendContextSetter interface {
+	// setSendContext sets the context of Send and returns the connection the
+	// batch holds, nil once released.
+	setSendContext(ctx context.Context) nativeTransport
+}
+
+func (b *tracedBatch) Send() error {
+	ctx, span := b.ch.startSpan(b.ctx, "clickhouse.batch.send", b.query)
+	span.span.SetAttributes(attribute.Int("clickhouse.batch.rows", b.Rows()))
+	if batch, ok := b.Batch.(sendContextSetter); ok {
+		if conn := batch.setSendContext(ctx); conn != nil {
+			span.connected(b.ch, conn)
+		}
+	}
+	err := b.Batch.Send()
+	span.end(err)
+	return err
+}
@@ -0,0 +1,155 @@
+package clickhouse
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ClickHouse/clickhouse-go/v2/internal/tracetest"
+)
+
+func spanAttributes(span *tracetest.Span) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracingSpans(t *testing.T) {
+	recorder := tracetest.NewRecorder()
+	transport := &execErrTransport{mockTransport: newMockTransport(1)}
+	conn, err := Open(&Options{
+		TracerProvider: recorder,
+		DialStrategy: func(ctx context.Context, connID int, opt *Options, dial Dial) (DialResult, error) {
+			return DialResult{conn: transport}, nil
+		},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx := Context(context.Background(), WithQueryID("query-1"))
+
+	require.NoError(t, conn.Exec(ctx, "insert into events values (1)"))
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	dial, acquire, exec := spans[0], spans[1], spans[2]
+	assert.Equal(t, "clickhouse.dial", dial.Name())
+	assert.Equal(t, "clickhouse.acquire", acquire.Name())
+	assert.Equal(t, "clickhouse.exec", exec.Name())
+	assert.Equal(t, trace.SpanKindClient, exec.SpanKind())
+	assert.Equal(t, exec.SpanContext().SpanID(), acquire.Parent().SpanID())
+	assert.Equal(t, acquire.SpanContext().SpanID(), dial.Parent().SpanID())
+	attrs := spanAttributes(exec)
+	assert.Equal(t, "clickhouse", attrs["db.system.name"].AsString())
+	assert.Equal(t, "INSERT", attrs["db.operation.name"].AsString())
+	assert.Equal(t, "insert into events values (1)", attrs["db.query.text"].AsString())
+	assert.Equal(t, "query-1", attrs["clickhouse.query_id"].AsString())
+	assert.Equal(t, codes.Unset, exec.Status().Code)
+
+	transport.err = &Exception{Code: 60, CodeName: "UNKNOWN_TABLE", Message: "Table doesn't exist"}
+	require.Error(t, conn.Exec(ctx, "SELECT * FROM missing"))
+	spans = recorder.Ended()
+	exec = spans[len(spans)-1]
+	assert.Equal(t, "clickhouse.exec", exec.Name())
+	assert.Equal(t, codes.Error, exec.Status().Code)
+	attrs = spanAttributes(exec)
+	assert.Equal(t, "60", attrs["db.response.status_code"].AsString())
+	assert.Equal(t, "UNKNOWN_TABLE", attrs["error.type"].AsString())
+}
+
+func TestTracingPropagation(t *testing.T) {
+	ctx, span := tracetest.NewRecorder().Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+
+	options := queryOptions(ctx)
+	assert.Equal(t, span.SpanContext(), options.span, "the active span is sent to the server")
+
+	h := &httpConnect{
+		opt: &Options{},
+		url: &url.URL{Scheme: "http", Host: "127.0.0.1:8123"},
+	}
+	req, err := h.createRequest(ctx, h.url.String(), nil, &options, nil)
+	require.NoError(t, err)
+	sc := span.SpanContext()
+	assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", req.Header.Get("traceparent"))
+
+	options = queryOptions(context.Background())
+	req, err = h.createRequest(ctx, h.url.String(), nil, &options, nil)
+	require.NoError(t, err)
+	assert.Empty(t, req.Header.Get("traceparent"))
+}
+
+func TestTracingServerEvents(t *testing.T) {
+	recorder := tracetest.NewRecorder()
+	ch := &clickhouse{opt: &Options{
+		TracerProvider:    recorder,
+		TraceServerEvents: true,
+	}}
+	ctx, span := ch.startSpan(context.Background(), "clickhouse.query", "SELECT 1")
+	options := queryOptions(ctx)
+	events := options.onProcess()
+	events.progress(&Progress{Rows: 1, Elapsed: 20 * time.Millisecond})
+	events.profileEvents([]ProfileEvent{
+		{Hostname: "server-1", Type: "increment", Name: "SelectedRows", Value: 2},
+		{Hostname: "server-1", Type: "gauge", Name: "MemoryTrackerPeakUsage", Value: 4096},
+	})
+	events.profileEvents([]ProfileEvent{
+		{Hostname: "server-1", Type: "increment", Name: "SelectedRows", Value: 3},
+		{Hostname: "server-1", Type: "gauge", Name: "MemoryTrackerPeakUsage", Value: 1024},
+	})
+	logTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	events.logs([]Log{{Time: logTime, TimeMicro: 250, Hostname: "server-1", Priority: 6, Source: "executeQuery", Text: "Read 1 rows"}})
+	span.end(nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	server, query := spans[0], spans[1]
+	assert.Equal(t, "clickhouse.server", server.Name())
+	assert.Equal(t, query.SpanContext().SpanID(), server.Parent().SpanID())
+	assert.Equal(t, 20*time.Millisecond, server.EndTime().Sub(server.StartTime()))
+	attrs := spanAttributes(server)
+	assert.Equal(t, int64(5), attrs["clickhouse.profile_events.SelectedRows"].AsInt64())
+	assert.Equal(t, int64(4096), attrs["clickhouse.profile_events.MemoryTrackerPeakUsage"].AsInt64())
+	assert.Equal(t, []string{"server-1"}, attrs["clickhouse.server.host_names"].AsStringSlice())
+	require.Len(t, server.Events(), 1)
+	assert.Equal(t, logTime.Add(250*time.Microsecond), server.Events()[0].Time)
+	assert.Equal(t, int64(1), spanAttributes(query)["clickhouse.read_rows"].AsInt64())
+
+	ch.opt.TraceServerEvents = false
+	ctx, span = ch.startSpan(context.Background(), "clickhouse.query", "SELECT 1")
+	options = queryOptions(ctx)
+	assert.Nil(t, options.onProcess().profileEvents, "profile events are not scanned")
+	span.end(nil)
+	assert.Len(t, recorder.Ended(), 3)
+}
+
+func TestTracingBatchSend(t *testing.T) {
+	recorder := tracetest.NewRecorder()
+	ch := &clickhouse{opt: &Options{TracerProvider: recorder}}
+	b := spoolTestBatch(t, "INSERT INTO events", []any{uint64(1), "a", time.Now()})
+	var sendCtx context.Context
+	b.connAcquire = func(ctx context.Context) (*connect, error) {
+		sendCtx = ctx
+		b.onProcess.progress(&Progress{WroteRows: 1, WroteBytes: 8})
+		return nil, &Exception{Code: 60, CodeName: "UNKNOWN_TABLE"}
+	}
+	traced := &tracedBatch{Batch: b, ch: ch, ctx: context.Background(), query: b.query}
+	require.Error(t, traced.Send())
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	send := spans[0]
+	assert.Equal(t, "clickhouse.batch.send", send.Name())
+	assert.Equal(t, send.SpanContext(), trace.SpanContextFromContext(sendCtx), "the batch sends with the context of the span")
+	attrs := spanAttributes(send)
+	assert.Equal(t, int64(1), attrs["clickhouse.batch.rows"].AsInt64())
+	assert.Equal(t, int64(1), attrs["clickhouse.written_rows"].AsInt64(), "the progress of the send is recorded")
+	assert.Equal(t, codes.Error, send.Status().Code)
+}